@@ -0,0 +1,216 @@
+package readline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SetAsyncCompleter uses a function fetching candidates in the background, like
+// from a remote service, to produce completions without blocking the shell. It is
+// called once per line and cursor position completed, as with SetCompletions (the
+// position is a byte offset in the line, and candidates are for the whole word in
+// which the cursor is), and returns a channel on which it sends batches of
+// candidates, closing it once all have been sent.
+// Its context is cancelled as soon as the candidates are not needed anymore, at
+// which point the function should stop fetching them and close the channel.
+//
+// The completion menu is updated as batches arrive, with a spinner in its hint
+// (see StartSpinner for its options) until the channel is closed: the shell keeps
+// reading and running keys meanwhile. Candidates are neither directly inserted if
+// unique, nor reported as missing, until all have been received. Fetching stops
+// after the number of seconds of the completion-async-timeout option (0 to
+// disable), keeping the candidates received, and when the menu is closed or the
+// line completed changes: in all these cases, the channel is still drained so
+// that the function never blocks on sending, but the candidates are ignored, and
+// the context is cancelled. A nil channel is the same as no candidates.
+//
+// Candidates of the last line are kept until it changes, and completing the same
+// line again doesn't fetch them again. Like SetCompletions, this overwrites the
+// Completer field, and passing nil disables completions.
+func (rl *Shell) SetAsyncCompleter(complete func(ctx context.Context, line string, pos int) <-chan []string) {
+	if rl.async != nil {
+		rl.async.stop()
+		rl.async = nil
+	}
+
+	if complete == nil {
+		rl.Completer = nil
+		return
+	}
+
+	rl.Completer = func(line []rune, cursor int) Completions {
+		return rl.asyncCompletions(complete, string(line), len(string(line[:cursor])))
+	}
+}
+
+// asyncCompletion is a request of the async completer for a line and position.
+type asyncCompletion struct {
+	line string
+	pos  int
+
+	mutex    sync.Mutex
+	values   []string // All candidates received so far.
+	done     bool     // All candidates have been received, or fetching timed out.
+	timedOut bool     // Fetching has been stopped after the timeout.
+	frames   []rune   // The frames of the loading spinner.
+	frame    int      // The current frame of the loading spinner.
+
+	ctx    context.Context    // Cancelled when fetching must stop.
+	cancel context.CancelFunc // Stops fetching, once done or not needed anymore.
+}
+
+// asyncCompletions returns the candidates of the async request for the line,
+// starting it if the line and cursor are not those of the current one.
+func (rl *Shell) asyncCompletions(complete func(ctx context.Context, line string, pos int) <-chan []string, line string, pos int) Completions {
+	req := rl.async
+
+	if req == nil || req.line != line || req.pos != pos || (req.isStopped() && !req.isDone()) {
+		if req != nil {
+			req.stop()
+		}
+
+		req = &asyncCompletion{line: line, pos: pos, frames: rl.spinnerFrames()}
+		req.ctx, req.cancel = context.WithCancel(context.Background())
+		rl.async = req
+
+		timeout := time.Duration(rl.Config.GetInt("completion-async-timeout")) * time.Second
+
+		if batches := complete(req.ctx, line, pos); batches != nil {
+			go rl.fetchAsync(req, batches, rl.spinnerInterval(), timeout)
+		} else {
+			req.finish(false)
+			req.stop()
+		}
+	}
+
+	return req.completions()
+}
+
+// fetchAsync receives the batches of candidates of a request, and updates the
+// completions displayed with them, until all are received, or fetching stops.
+// At each spinner interval, it also checks that they are still displayed.
+// Once done, the context of the request is cancelled in all cases.
+func (rl *Shell) fetchAsync(req *asyncCompletion, batches <-chan []string, interval, timeout time.Duration) {
+	defer req.stop()
+
+	defer func() {
+		go func() {
+			for range batches {
+			}
+		}()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var expired <-chan time.Time
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		expired = timer.C
+	}
+
+	for {
+		select {
+		case <-req.ctx.Done():
+			return
+		case batch, open := <-batches:
+			if !open {
+				req.finish(false)
+				rl.updateAsync(req)
+
+				return
+			}
+
+			req.add(batch)
+		case <-expired:
+			req.finish(true)
+			rl.updateAsync(req)
+
+			return
+		case <-ticker.C:
+			req.tick()
+		}
+
+		rl.updateAsync(req)
+	}
+}
+
+// updateAsync regenerates and redisplays the completions of a request, if they
+// are still those being displayed, or stops the request if they are not anymore.
+func (rl *Shell) updateAsync(req *asyncCompletion) {
+	rl.mutex.Lock()
+	defer rl.unlock()
+
+	if !rl.reading.Load() || req.isStopped() {
+		return
+	}
+
+	if !rl.completer.IsActive() {
+		req.stop()
+		return
+	}
+
+	rl.completer.Update()
+	rl.line, rl.cursor, rl.selection = rl.completer.GetBuffer()
+
+	rl.Display.Refresh()
+}
+
+// completions returns the candidates received so far, with a loading
+// spinner, or the timeout message, in the completion hint.
+func (req *asyncCompletion) completions() Completions {
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	comps := CompleteValues(req.values...)
+
+	switch {
+	case !req.done:
+		comps.loading = true
+		comps.messages.Add(string(req.frames[req.frame%len(req.frames)]) + " loading completions")
+	case req.timedOut:
+		comps.messages.Add("completions timed out")
+	}
+
+	return comps
+}
+
+func (req *asyncCompletion) add(values []string) {
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	req.values = append(req.values, values...)
+}
+
+func (req *asyncCompletion) tick() {
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	req.frame++
+}
+
+func (req *asyncCompletion) finish(timedOut bool) {
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	req.done, req.timedOut = true, timedOut
+}
+
+func (req *asyncCompletion) isDone() bool {
+	req.mutex.Lock()
+	defer req.mutex.Unlock()
+
+	return req.done
+}
+
+func (req *asyncCompletion) stop() {
+	req.cancel()
+}
+
+func (req *asyncCompletion) isStopped() bool {
+	return req.ctx.Err() != nil
+}
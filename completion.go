@@ -23,6 +23,9 @@ func (rl *Shell) completionCommands() commands {
 		"accept-and-menu-complete": rl.acceptAndMenuComplete,
 		"vi-registers-complete":    rl.viRegistersComplete,
 		"menu-incremental-search":  rl.menuIncrementalSearch,
+		"menu-accept-line":         rl.menuAcceptLine,
+		"accept-and-complete":      rl.acceptAndComplete,
+		"complete-filename":        rl.completeFilename,
 	}
 }
 
@@ -31,8 +34,16 @@ func (rl *Shell) completionCommands() commands {
 //
 
 // Attempt completion on the current word.
-// Currently identitical to menu-complete.
+// Currently identitical to menu-complete, unless complete-common-prefix-first
+// is on: in this case, the longest common prefix of all candidates is inserted
+// first, and the completion menu is only used if nothing could be inserted.
 func (rl *Shell) completeWord() {
+	if !rl.completer.IsActive() && rl.Config.GetBool("complete-common-prefix-first") {
+		if rl.completer.InsertCommonPrefix(rl.commandCompletion) {
+			return
+		}
+	}
+
 	rl.History.SkipSave()
 
 	// This completion function should attempt to insert the first
@@ -168,6 +179,26 @@ func (rl *Shell) acceptAndMenuComplete() {
 	rl.completer.Select(1, 0)
 }
 
+// In a menu completion, insert the current candidate into the buffer and, if
+// this candidate ends with the continuation marker of its group (by default a
+// slash, like for directories), immediately complete again after it.
+func (rl *Shell) acceptAndComplete() {
+	rl.History.SkipSave()
+
+	if !rl.completer.IsActive() || !rl.completer.IsInserting() {
+		return
+	}
+
+	continues := rl.completer.Continues()
+
+	// Insert the candidate and drop the current menu.
+	rl.completer.Reset()
+
+	if continues {
+		rl.startMenuComplete(rl.commandCompletion)
+	}
+}
+
 // Open a completion menu (similar to menu-complete) with all currently populated Vim registers.
 func (rl *Shell) viRegistersComplete() {
 	rl.History.SkipSave()
@@ -190,6 +221,28 @@ func (rl *Shell) menuIncrementalSearch() {
 	rl.completer.IsearchStart("completions", false, false)
 }
 
+// In a menu completion with a candidate selected, either insert this candidate,
+// accept the line with it, or accept the line without it, depending on the value
+// of the menu-complete-accept-on-enter option (insert-only, insert-and-submit,
+// submit-ignoring-menu). In all other cases, this is identical to accept-line.
+func (rl *Shell) menuAcceptLine() {
+	if rl.Keymap.Local() != keymap.MenuSelect || !rl.completer.IsInserting() {
+		rl.acceptLine()
+		return
+	}
+
+	switch rl.Config.GetString("menu-complete-accept-on-enter") {
+	case "insert-only":
+		rl.completer.Reset()
+	case "submit-ignoring-menu":
+		rl.completer.Cancel(true, true)
+		rl.completer.ClearMenu(true)
+		rl.acceptLine()
+	default:
+		rl.acceptLine()
+	}
+}
+
 //
 // Utilities --------------------------------------------------------------------------
 //
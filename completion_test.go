@@ -0,0 +1,715 @@
+package readline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/keymap"
+)
+
+func TestShell_menuAcceptLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  string
+		input string
+		want  string
+	}{
+		{name: "Insert and submit (default)", mode: "", input: "al\t\r", want: "alpha"},
+		{name: "Insert and submit", mode: "insert-and-submit", input: "al\t\r", want: "alpha"},
+		{name: "Insert only", mode: "insert-only", input: "al\t\r!\r", want: "alpha!"},
+		{name: "Submit ignoring menu", mode: "submit-ignoring-menu", input: "al\t\r", want: "al"},
+		{name: "No candidate selected", mode: "insert-only", input: "al\r", want: "al"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Completer = func(line []rune, cursor int) Completions {
+				return CompleteValues("alpha", "alphabet")
+			}
+
+			if test.mode != "" {
+				rl.Config.Set("menu-complete-accept-on-enter", test.mode)
+			}
+
+			got, err := rl.Process(test.input)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Process() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_acceptAndComplete(t *testing.T) {
+	tests := []struct {
+		name      string
+		marker    *string
+		wantLine  string
+		wantComps int
+	}{
+		{name: "Continue after slash by default", wantLine: "cd dir1/", wantComps: 2},
+		{name: "Custom continuation marker", marker: ptr("1/"), wantLine: "cd dir1/", wantComps: 2},
+		{name: "Marker not matching", marker: ptr(":"), wantLine: "cd dir1/"},
+		{name: "Continuation disabled", marker: ptr(""), wantLine: "cd dir1/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Completer = func(line []rune, cursor int) Completions {
+				if strings.HasSuffix(string(line[:cursor]), "dir1/") {
+					return CompleteValues("dir1/a", "dir1/b")
+				}
+
+				comps := CompleteValues("dir1/", "dir2/")
+				if test.marker != nil {
+					comps = comps.ContinueOn(*test.marker)
+				}
+
+				return comps
+			}
+
+			runKeys(t, rl, "cd d\t")
+			rl.acceptAndComplete()
+
+			if got := string(*rl.line); got != test.wantLine {
+				t.Errorf("line = %q, want %q", got, test.wantLine)
+			}
+
+			if got := rl.completer.IsActive(); got != (test.wantComps > 0) {
+				t.Errorf("completing = %v, want %v", got, test.wantComps > 0)
+			}
+
+			if got := rl.completer.Matches(); got != test.wantComps {
+				t.Errorf("matches = %d, want %d", got, test.wantComps)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func TestShell_completionPrefixBoundary(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		cursor int
+		keys   []string
+		want   []string
+	}{
+		{
+			name: "Prefix at end of line", line: "git ch", cursor: 6,
+			keys: []string{"\t", "\t", "\t"},
+			want: []string{"git checkout", "git cherry-pick", "git checkout"},
+		},
+		{
+			name: "Cycle backward", line: "git ch", cursor: 6,
+			keys: []string{"\t", "\x1b[Z", "\x1b[Z"},
+			want: []string{"git checkout", "git cherry-pick", "git checkout"},
+		},
+		{
+			name: "Prefix in the middle of a word", line: "git chz --all", cursor: 6,
+			keys: []string{"\t", "\t"},
+			want: []string{"git checkout --all", "git cherry-pick --all"},
+		},
+		{
+			name: "Multibyte prefix", line: "x yy ééz", cursor: 7,
+			keys: []string{"\t", "\t", "\t"},
+			want: []string{"x yy ééa", "x yy ééb", "x yy ééa"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+			rl.Completer = func(line []rune, cursor int) Completions {
+				return CompleteValues("checkout", "cherry-pick", "commit", "ééa", "ééb", "alpha")
+			}
+
+			before := string([]rune(test.line)[:test.cursor-2])
+
+			for i, keys := range test.keys {
+				runKeys(t, rl, keys)
+
+				line, _ := rl.completer.Line()
+				if got := string(*line); got != test.want[i] {
+					t.Errorf("cycle %d: line = %q, want %q", i, got, test.want[i])
+				}
+
+				if !strings.HasPrefix(string(*line), before) {
+					t.Errorf("cycle %d: line %q does not start with %q", i, string(*line), before)
+				}
+			}
+		})
+	}
+}
+
+func TestShell_SetCompletions(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Unique candidate", input: "git chec\t\r", want: "git checkout"},
+		{name: "Cycle candidates", input: "git c\t\t\r", want: "git cherry-pick"},
+		{name: "No matching candidate", input: "git x\t\r", want: "git x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.SetCompletions(func(line string, pos int) []string {
+				if pos != len(line) {
+					t.Errorf("completer called with pos %d on line %q", pos, line)
+				}
+
+				return []string{"checkout", "cherry-pick", "commit"}
+			})
+
+			got, err := rl.Process(test.input)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Process() = %q, want %q", got, test.want)
+			}
+
+		})
+	}
+
+	// The position is a byte offset, even after multibyte characters.
+	rl := newTestShell(t, keymap.Emacs, "échö ch", 7)
+	rl.SetCompletions(func(line string, pos int) []string {
+		if word := line[strings.LastIndex(line[:pos], " ")+1 : pos]; word != "ch" {
+			t.Errorf("word before the cursor = %q, want %q", word, "ch")
+		}
+
+		return []string{"checkout"}
+	})
+
+	runKeys(t, rl, "\t")
+
+	if got := strings.TrimSpace(string(*rl.line)); got != "échö checkout" {
+		t.Errorf("line = %q, want %q", got, "échö checkout")
+	}
+
+	rl = newTestShell(t, keymap.Emacs, "", 0)
+	rl.SetCompletions(nil)
+
+	if rl.Completer != nil {
+		t.Errorf("SetCompletions(nil) should disable completions")
+	}
+}
+
+// testWordCompleter completes the options and branches of a git-like command,
+// and records the words it was called with.
+type testWordCompleter struct {
+	options   []string
+	arguments []string
+}
+
+func (c *testWordCompleter) CompleteOptions(word string) []Completion {
+	c.options = append(c.options, word)
+
+	return []Completion{
+		{Value: "--all", Description: "Include all branches"},
+		{Value: "--amend", Description: "Amend the last commit"},
+		{Value: "-v", Description: "Be verbose"},
+	}
+}
+
+func (c *testWordCompleter) CompleteArguments(word string) []Completion {
+	c.arguments = append(c.arguments, word)
+
+	return []Completion{{Value: "main"}, {Value: "develop"}}
+}
+
+func TestShell_SetWordCompleter(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		want          string
+		wantOptions   string
+		wantArguments string
+	}{
+		{name: "Option", input: "git commit --am\t\r", want: "git commit --amend", wantOptions: "--am"},
+		{name: "Short option", input: "git log -v\t\r", want: "git log -v", wantOptions: "-v"},
+		{name: "Argument", input: "git checkout dev\t\r", want: "git checkout develop", wantArguments: "dev"},
+		{name: "Argument after an option", input: "git checkout -f ma\t\r", want: "git checkout -f main", wantArguments: "ma"},
+		{name: "Empty word", input: "git checkout \t\r", want: "git checkout develop", wantArguments: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			completer := &testWordCompleter{}
+
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.SetWordCompleter(completer)
+
+			got, err := rl.Process(test.input)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Process() = %q, want %q", got, test.want)
+			}
+
+			if len(completer.options) > 0 && completer.options[0] != test.wantOptions {
+				t.Errorf("CompleteOptions() word = %q, want %q", completer.options[0], test.wantOptions)
+			}
+
+			if len(completer.arguments) > 0 && completer.arguments[0] != test.wantArguments {
+				t.Errorf("CompleteArguments() word = %q, want %q", completer.arguments[0], test.wantArguments)
+			}
+
+			if (len(completer.options) > 0) != (test.wantOptions != "") {
+				t.Errorf("CompleteOptions() called %d times", len(completer.options))
+			}
+		})
+	}
+
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.SetWordCompleter(nil)
+
+	if rl.Completer != nil {
+		t.Errorf("SetWordCompleter(nil) should disable completions")
+	}
+}
+
+func TestShell_CompletionState(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Completer = func(line []rune, cursor int) Completions {
+		return CompleteValues("alpha", "beta", "gamma").NoSort().DisplayList()
+	}
+
+	if state := rl.CompletionState(); state != nil {
+		t.Fatalf("CompletionState() = %+v before completing, want nil", state)
+	}
+
+	runKeys(t, rl, "\t")
+
+	state := rl.CompletionState()
+	if state == nil {
+		t.Fatal("CompletionState() = nil while completing")
+	}
+
+	if len(state.Groups) != 1 || len(state.Groups[0].Rows) != 3 || state.Groups[0].Columns != 1 {
+		t.Fatalf("CompletionState() groups = %+v, want 3 rows of 1 column", state.Groups)
+	}
+
+	if comp, _ := state.Selected(); comp.Value != "alpha" {
+		t.Errorf("Selected() = %q, want %q", comp.Value, "alpha")
+	}
+
+	rl.MoveCompletion(0, 1)
+
+	state = rl.CompletionState()
+	if comp, _ := state.Selected(); comp.Value != "beta" || state.Row != 1 {
+		t.Errorf("Selected() after moving = %q (row %d), want %q (row 1)", comp.Value, state.Row, "beta")
+	}
+
+	if line := string(*rl.line); line != "beta" {
+		t.Errorf("line = %q after moving, want %q", line, "beta")
+	}
+
+	rl.AcceptCompletion()
+
+	if line := string(*rl.line); line != "beta" {
+		t.Errorf("line = %q after accepting, want %q", line, "beta")
+	}
+
+	if state := rl.CompletionState(); state != nil {
+		t.Errorf("CompletionState() = %+v after accepting, want nil", state)
+	}
+}
+
+func TestShell_completeCommonPrefixFirst(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantLine string
+		wantMenu bool
+	}{
+		{name: "Insert common prefix", input: "git ch\t", wantLine: "git che"},
+		{name: "Menu on second tab", input: "git ch\t\t", wantLine: "git checkout", wantMenu: true},
+		{name: "Ambiguous prefix opens menu", input: "git c\t", wantLine: "git checkout", wantMenu: true},
+		{name: "Unique candidate adds a space", input: "git co\t", wantLine: "git commit "},
+		{name: "Unique candidate with no-space suffix", input: "cd d\t", wantLine: "cd dir/"},
+		{name: "No candidates", input: "git x\t", wantLine: "git x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Config.Set("complete-common-prefix-first", true)
+			rl.Completer = func(line []rune, cursor int) Completions {
+				if strings.HasPrefix(string(line), "cd") {
+					return CompleteValues("dir/").NoSpace('/')
+				}
+
+				return CompleteValues("checkout", "cherry-pick", "commit")
+			}
+
+			runKeys(t, rl, test.input)
+
+			line, _ := rl.completer.Line()
+			if got := string(*line); got != test.wantLine {
+				t.Errorf("line = %q, want %q", got, test.wantLine)
+			}
+
+			if got := rl.Keymap.Local() == keymap.MenuSelect; got != test.wantMenu {
+				t.Errorf("menu active = %v, want %v", got, test.wantMenu)
+			}
+		})
+	}
+}
+
+func TestShell_completionAppendSuffix(t *testing.T) {
+	tests := []struct {
+		name   string
+		append bool
+		prefix bool // complete-common-prefix-first
+		comps  Completions
+		input  string
+		want   string
+	}{
+		{name: "Disabled by default", comps: CompleteValues("checkout"), input: "git ch\t", want: "git checkout"},
+		{name: "Space after unique candidate", append: true, comps: CompleteValues("checkout"), input: "git ch\t", want: "git checkout "},
+		{name: "Space removed by next space", append: true, comps: CompleteValues("checkout"), input: "git ch\t ", want: "git checkout "},
+		{name: "Space kept by other keys", append: true, comps: CompleteValues("checkout"), input: "git ch\t-", want: "git checkout -"},
+		{name: "Slash after directories", append: true, comps: CompleteValues("dir").Tag("directories"), input: "cd d\t", want: "cd dir/"},
+		{name: "No-space suffix", append: true, comps: CompleteValues("dir/").NoSpace('/'), input: "cd d\t", want: "cd dir/"},
+		{name: "Group suffix", comps: CompleteValues("name").AppendSuffix("="), input: "set n\t", want: "set name="},
+		{name: "Group suffix disabled", append: true, comps: CompleteValues("name").AppendSuffix(""), input: "set n\t", want: "set name"},
+		{name: "Suffix not doubled", append: true, comps: CompleteValues("dir/").Tag("directories"), input: "cd d\t", want: "cd dir/"},
+		{name: "Unique common prefix", prefix: true, comps: CompleteValues("checkout"), input: "git ch\t", want: "git checkout "},
+		{name: "Unique common prefix directory", prefix: true, comps: CompleteValues("dir").Tag("directories"), input: "cd d\t", want: "cd dir/"},
+		{name: "Candidate already typed, common prefix only", prefix: true, comps: CompleteValues("checkout"), input: "git checkout\t", want: "git checkout"},
+		{name: "Candidate already typed, both options", append: true, prefix: true, comps: CompleteValues("checkout"), input: "git checkout\t", want: "git checkout "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Config.Set("completion-append-space", test.append)
+			rl.Config.Set("complete-common-prefix-first", test.prefix)
+			rl.Completer = func(line []rune, cursor int) Completions {
+				return test.comps
+			}
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_completionAutoremoveChars(t *testing.T) {
+	tests := []struct {
+		name  string
+		chars string
+		input string
+		want  string
+	}{
+		{name: "Space removes auto space", input: "ls\t ", want: "ls "},
+		{name: "Separator removes auto space", input: "ls\t;", want: "ls;"},
+		{name: "Pipe removes auto space", input: "ls\t|", want: "ls|"},
+		{name: "Other keys keep auto space", input: "ls\t/", want: "ls /"},
+		{name: "Custom set", chars: "/", input: "ls\t/", want: "ls/"},
+		{name: "Custom set quoted", chars: `"/ "`, input: "ls\t;", want: "ls ;"},
+		{name: "Empty set matches nothing", chars: `""`, input: "ls\t;", want: "ls ;"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Config.Set("completion-append-space", true)
+
+			if test.chars != "" {
+				rl.Config.Set("completion-autoremove-chars", test.chars)
+			}
+
+			rl.Completer = func(line []rune, cursor int) Completions {
+				return CompleteValues("ls")
+			}
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_completionNoMatchesHint(t *testing.T) {
+	tests := []struct {
+		name string
+		hint string
+		want string
+	}{
+		{name: "Default hint", want: "no matching completions"},
+		{name: "Custom hint", hint: "nothing to complete", want: "nothing to complete"},
+		{name: "Suppressed hint", hint: `""`, want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Config.Set("bell-style", "none")
+
+			if test.hint != "" {
+				rl.Config.Set("completion-no-matches-hint", test.hint)
+			}
+
+			rl.SetCompletions(func(line string, pos int) []string {
+				return []string{"checkout", "commit"}
+			})
+
+			runKeys(t, rl, "git x\t")
+
+			if got := color.Strip(rl.Hint.Text()); got != test.want {
+				t.Errorf("hint = %q, want %q", got, test.want)
+			}
+
+			if got := string(*rl.line); got != "git x" {
+				t.Errorf("line = %q, want %q", got, "git x")
+			}
+		})
+	}
+}
+
+func TestShell_completeInWord(t *testing.T) {
+	tests := []struct {
+		name       string
+		inWord     bool
+		line       string
+		cursor     int
+		want       string
+		wantCursor int
+	}{
+		{name: "Replace to end of word", inWord: true, line: "git chzz --all", cursor: 6, want: "git checkout --all", wantCursor: 12},
+		{name: "Unique candidate", inWord: true, line: "git comx", cursor: 7, want: "git commit", wantCursor: 10},
+		{name: "Cursor after the word", inWord: true, line: "git co --all", cursor: 6, want: "git commit --all", wantCursor: 10},
+		{name: "Whole word matches", inWord: false, line: "git chout", cursor: 6, want: "git chout", wantCursor: 6},
+		{name: "Whole word replaced", inWord: false, line: "git chec --all", cursor: 6, want: "git checkout --all", wantCursor: 12},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+			rl.Config.Set("complete-in-word", test.inWord)
+			rl.Completer = func(line []rune, cursor int) Completions {
+				return CompleteValues("checkout", "cherry-pick", "commit")
+			}
+
+			runKeys(t, rl, "\t\r")
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+}
+
+func TestShell_SetAsyncCompleter(t *testing.T) {
+	restore := discardTerminal()
+	defer restore()
+
+	requests := make(map[string]chan []string)
+	contexts := make(map[string]context.Context)
+
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.SetAsyncCompleter(func(ctx context.Context, line string, pos int) <-chan []string {
+		batches := make(chan []string)
+		requests[line], contexts[line] = batches, ctx
+
+		return batches
+	})
+
+	// Wait for the completions updated in the background, with the shell
+	// locked as when reading input, like the shell does while typing.
+	rl.mutex.Lock()
+	rl.reading.Store(true)
+
+	waitFor := func(what string, done func() bool) {
+		t.Helper()
+
+		for deadline := time.Now().Add(time.Second); !done(); {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+
+			rl.mutex.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			rl.mutex.Lock()
+		}
+	}
+
+	defer func() {
+		rl.reading.Store(false)
+		rl.mutex.Unlock()
+	}()
+
+	runKeys(t, rl, "git c\t")
+
+	if !rl.completer.IsActive() || rl.completer.Matches() != 0 {
+		t.Fatalf("completing = %v with %d matches, want an empty menu while loading", rl.completer.IsActive(), rl.completer.Matches())
+	}
+
+	if hint := rl.Hint.Text(); !strings.Contains(hint, "loading completions") {
+		t.Errorf("hint = %q, want a loading hint", hint)
+	}
+
+	// A unique candidate is not inserted while loading.
+	go func() { requests["git c"] <- []string{"checkout", "status"} }()
+	waitFor("the first batch", func() bool { return rl.completer.Matches() == 1 })
+
+	if line := string(*rl.line); line != "git c" {
+		t.Errorf("line = %q, want the unique candidate not inserted while loading", line)
+	}
+
+	go func() {
+		requests["git c"] <- []string{"cherry-pick", "commit"}
+		close(requests["git c"])
+	}()
+	waitFor("the last batch", func() bool { return !strings.Contains(rl.Hint.Text(), "loading") })
+
+	if matches := rl.completer.Matches(); matches != 3 {
+		t.Errorf("matches = %d, want 3", matches)
+	}
+
+	// Changing the line stops fetching the candidates of the
+	// previous one, without blocking the completer sending them.
+	runKeys(t, rl, "\x07")
+	runKeys(t, rl, "h\t")
+
+	if len(requests) != 2 || requests["git ch"] == nil {
+		t.Fatalf("requests = %v, want a new one for %q", requests, "git ch")
+	}
+
+	if err := contexts["git c"].Err(); err == nil {
+		t.Error("context of a completed request not cancelled")
+	}
+
+	if err := contexts["git ch"].Err(); err != nil {
+		t.Errorf("context of the current request cancelled: %v", err)
+	}
+
+	rl.SetAsyncCompleter(nil)
+
+	select {
+	case <-contexts["git ch"].Done():
+	case <-time.After(time.Second):
+		t.Error("context of a stopped request not cancelled")
+	}
+
+	select {
+	case requests["git ch"] <- []string{"checkout"}:
+	case <-time.After(time.Second):
+		t.Error("stopped request not drained")
+	}
+
+	if rl.Completer != nil {
+		t.Errorf("SetAsyncCompleter(nil) should disable completions")
+	}
+}
+
+func TestShell_SetCompletionBaseDir(t *testing.T) {
+	base, home, other := t.TempDir(), t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	for _, file := range []string{
+		filepath.Join(base, "src", "util", "strings.go"),
+		filepath.Join(base, "src", "main.go"),
+		filepath.Join(base, "README"),
+		filepath.Join(base, ".hidden"),
+		filepath.Join(base, "my notes.txt"),
+		filepath.Join(base, "my dir", "file"),
+		filepath.Join(home, "notes"),
+		filepath.Join(other, "file.txt"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(file, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	otherDir := filepath.ToSlash(other)
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Relative file", input: "cat src/m", want: "cat src/main.go"},
+		{name: "Directory", input: "cd sr", want: "cd src/"},
+		{name: "Nested directory", input: "cd src/u", want: "cd src/util/"},
+		{name: "Hidden file", input: "cat .h", want: "cat .hidden"},
+		{name: "Home directory", input: "cat ~/n", want: "cat ~/notes"},
+		{name: "Absolute path", input: "cat " + otherDir + "/f", want: "cat " + otherDir + "/file.txt"},
+		{name: "No matching file", input: "cat x", want: "cat x"},
+		{name: "Blank escaped in candidate", input: "cat my\\ n", want: `cat my\ notes.txt`},
+		{name: "Escaped directory", input: "cd my\\ d", want: `cd my\ dir/`},
+		{name: "Within escaped directory", input: "cat my\\ dir/f", want: `cat my\ dir/file`},
+		{name: "Double-quoted file", input: `cat "my n`, want: `cat "my notes.txt"`},
+		{name: "Single-quoted directory", input: `cd 'my d`, want: `cd 'my dir/`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Config.Bind(string(keymap.Emacs), "\x18f", "complete-filename", false)
+			rl.SetCompletionBaseDir(base)
+
+			got, err := rl.Process(test.input + "\x18f\r")
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Process() = %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	// Without a base directory, paths are relative to the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(other); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Config.Bind(string(keymap.Emacs), "\x18f", "complete-filename", false)
+
+	if got, _ := rl.Process("cat fi\x18f\r"); got != "cat file.txt" {
+		t.Errorf("Process() = %q, want %q", got, "cat file.txt")
+	}
+}
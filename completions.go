@@ -14,15 +14,18 @@ type Completion = completion.Candidate
 // Some of those additional settings will apply to all contained candidates,
 // except when these candidates have their own corresponding settings.
 type Completions struct {
-	values   completion.RawValues
-	messages completion.Messages
-	noSpace  completion.SuffixMatcher
-	usage    string
-	listLong map[string]bool
-	noSort   map[string]bool
-	listSep  map[string]string
-	pad      map[string]bool
-	escapes  map[string]bool
+	values    completion.RawValues
+	messages  completion.Messages
+	noSpace   completion.SuffixMatcher
+	usage     string
+	listLong  map[string]bool
+	noSort    map[string]bool
+	listSep   map[string]string
+	pad       map[string]bool
+	escapes   map[string]bool
+	continues map[string]string
+	appends   map[string]string
+	loading   bool
 
 	// Initially this will be set to the part of the current word
 	// from the beginning of the word up to the position of the cursor.
@@ -366,6 +369,50 @@ func (c Completions) PreserveEscapes(tags ...string) Completions {
 	return c
 }
 
+// ContinueOn sets the suffix of candidates after which completion should continue,
+// when they are inserted with the accept-and-complete command (eg. "/" for a path).
+// A series of tags can be passed to restrict this to these tags. If empty, will be
+// applied to all completions. If never set, completion continues after a slash.
+// An empty marker disables continuation for the corresponding completions.
+func (c Completions) ContinueOn(marker string, tags ...string) Completions {
+	if c.continues == nil {
+		c.continues = make(map[string]string)
+	}
+
+	if len(tags) == 0 {
+		c.continues["*"] = marker
+	}
+
+	for _, tag := range tags {
+		c.continues[tag] = marker
+	}
+
+	return c
+}
+
+// AppendSuffix sets the suffix automatically inserted after a unique candidate
+// is accepted, like a space after a command or a slash after a directory.
+// A series of tags can be passed to restrict this to these tags. If empty, will be
+// applied to all completions. An empty suffix disables it for these completions.
+// If never set, and when completion-append-space is on, a slash is appended to
+// candidates whose tag ends with "directories", and a space to all others.
+// The suffix is removed if the next key typed is a space, or one of the NoSpace runes.
+func (c Completions) AppendSuffix(suffix string, tags ...string) Completions {
+	if c.appends == nil {
+		c.appends = make(map[string]string)
+	}
+
+	if len(tags) == 0 {
+		c.appends["*"] = suffix
+	}
+
+	for _, tag := range tags {
+		c.appends[tag] = suffix
+	}
+
+	return c
+}
+
 // Merge merges Completions (existing values are overwritten)
 //
 //	a := CompleteValues("A", "B").Invoke(c)
@@ -408,6 +455,7 @@ func (c *Completions) merge(other Completions) {
 
 	c.noSpace.Merge(other.noSpace)
 	c.messages.Merge(other.messages)
+	c.loading = c.loading || other.loading
 
 	for tag := range other.listLong {
 		if _, found := c.listLong[tag]; !found {
@@ -432,6 +480,26 @@ func (c *Completions) merge(other Completions) {
 			c.pad[tag] = other.pad[tag]
 		}
 	}
+
+	for tag := range other.continues {
+		if c.continues == nil {
+			c.continues = make(map[string]string)
+		}
+
+		if _, found := c.continues[tag]; !found {
+			c.continues[tag] = other.continues[tag]
+		}
+	}
+
+	for tag := range other.appends {
+		if c.appends == nil {
+			c.appends = make(map[string]string)
+		}
+
+		if _, found := c.appends[tag]; !found {
+			c.appends[tag] = other.appends[tag]
+		}
+	}
 }
 
 func (c *Completions) convert() completion.Values {
@@ -445,6 +513,9 @@ func (c *Completions) convert() completion.Values {
 	comps.ListSep = c.listSep
 	comps.Pad = c.pad
 	comps.Escapes = c.escapes
+	comps.Continue = c.continues
+	comps.Append = c.appends
+	comps.Loading = c.loading
 
 	comps.PREFIX = c.PREFIX
 	comps.SUFFIX = c.SUFFIX
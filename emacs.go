@@ -3,15 +3,20 @@ package readline
 import (
 	"fmt"
 	"io"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/rivo/uniseg"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/color"
 	"github.com/alexj212/readline/internal/completion"
+	"github.com/alexj212/readline/internal/editor"
 	"github.com/alexj212/readline/internal/keymap"
 	"github.com/alexj212/readline/internal/strutil"
 	"github.com/alexj212/readline/internal/term"
@@ -49,6 +54,12 @@ func (rl *Shell) standardCommands() commands {
 		"clear-display":        rl.clearDisplay,
 		"redraw-current-line":  rl.Display.Refresh,
 
+		"goto-matching-indent-up":   rl.gotoMatchingIndentUp,
+		"goto-matching-indent-down": rl.gotoMatchingIndentDown,
+		"goto-position":             rl.gotoPosition,
+		"goto-line":                 rl.gotoLine,
+		"toggle-fold":               rl.toggleFold,
+
 		// Changing text
 		"end-of-file":                  rl.endOfFile,
 		"delete-char":                  rl.deleteChar,
@@ -67,11 +78,29 @@ func (rl *Shell) standardCommands() commands {
 		"overwrite-mode":               rl.overwriteMode,
 		"delete-horizontal-whitespace": rl.deleteHorizontalWhitespace,
 
-		"delete-word":      rl.deleteWord,
-		"quote-region":     rl.quoteRegion,
-		"quote-line":       rl.quoteLine,
-		"keyword-increase": rl.keywordIncrease,
-		"keyword-decrease": rl.keywordDecrease,
+		"delete-word":                 rl.deleteWord,
+		"quote-region":                rl.quoteRegion,
+		"quote-line":                  rl.quoteLine,
+		"cycle-quoting":               rl.cycleQuoting,
+		"transpose-char-with":         rl.transposeChars,
+		"dequote-word":                rl.dequoteWord,
+		"keyword-increase":            rl.keywordIncrease,
+		"keyword-decrease":            rl.keywordDecrease,
+		"rotate-word":                 rl.rotateWord,
+		"expand-multiline":            rl.expandMultiline,
+		"replace-in-line":             rl.replaceInLine,
+		"query-replace":               rl.queryReplace,
+		"increment-numbers-in-region": rl.incrementNumbersInRegion,
+		"block-insert":                rl.blockInsert,
+		"tabs-to-spaces":              rl.tabsToSpaces,
+		"spaces-to-tabs":              rl.spacesToTabs,
+		"toggle-prefix":               rl.togglePrefix,
+		"swap-case-region":            rl.swapCaseRegion,
+		"number-lines":                rl.numberLines,
+		"delete-blank-lines":          rl.deleteBlankLines,
+		"move-line-up":                rl.moveLineUp,
+		"move-line-down":              rl.moveLineDown,
+		"trim-region":                 rl.trimRegion,
 
 		// Killing & yanking
 		"kill-line":           rl.killLine,
@@ -87,14 +116,22 @@ func (rl *Shell) standardCommands() commands {
 		"copy-forward-word":   rl.copyForwardWord,
 		"yank":                rl.yank,
 		"yank-pop":            rl.yankPop,
+		"paste-register":      rl.pasteRegister,
 
 		"kill-buffer":              rl.killBuffer,
+		"backward-kill-buffer":     rl.backwardKillBuffer,
+		"kill-buffer-forward":      rl.killBufferForward,
 		"shell-kill-word":          rl.shellKillWord,
 		"shell-backward-kill-word": rl.shellBackwardKillWord,
 		"copy-prev-shell-word":     rl.copyPrevShellWord,
+		"zap-to-char":              rl.zapToChar,
+		"zap-up-to-char":           rl.zapUpToChar,
+		"copy-whole-line":          rl.copyWholeLine,
+		"export-selection":         rl.exportSelection,
 
 		// Numeric arguments
-		"digit-argument": rl.digitArgument,
+		"digit-argument":  rl.digitArgument,
+		"sticky-argument": rl.stickyArgument,
 
 		// Macros
 		"start-kbd-macro":      rl.startKeyboardMacro,
@@ -104,6 +141,7 @@ func (rl *Shell) standardCommands() commands {
 
 		"macro-toggle-record": rl.macroToggleRecord,
 		"macro-run":           rl.macroRun,
+		"name-last-kbd-macro": rl.nameLastKeyboardMacro,
 
 		// Miscellaneous
 		"re-read-init-file":         rl.reReadInitFile,
@@ -124,9 +162,22 @@ func (rl *Shell) standardCommands() commands {
 		"edit-and-execute-command":  rl.editAndExecuteCommand,
 		"edit-command-line":         rl.editCommandLine,
 
-		"redo":                rl.redo,
-		"select-keyword-next": rl.selectKeywordNext,
-		"select-keyword-prev": rl.selectKeywordPrev,
+		"redo":                  rl.redo,
+		"select-all":            rl.selectAll,
+		"select-keyword-next":   rl.selectKeywordNext,
+		"select-keyword-prev":   rl.selectKeywordPrev,
+		"select-forward-char":   rl.selectForwardChar,
+		"select-backward-char":  rl.selectBackwardChar,
+		"select-forward-word":   rl.selectForwardWord,
+		"select-backward-word":  rl.selectBackwardWord,
+		"select-up-line":        rl.selectUpLine,
+		"select-down-line":      rl.selectDownLine,
+		"insert-command-output": rl.insertCommandOutput,
+		"insert-datetime":       rl.insertDatetime,
+		"repeat-last-command":   rl.repeatLastCommand,
+
+		"select-to-beginning-of-line": rl.selectToBeginningOfLine,
+		"select-to-end-of-line":       rl.selectToEndOfLine,
 	}
 
 	return widgets
@@ -284,13 +335,76 @@ func (rl *Shell) downLine() {
 	rl.cursor.LineMove(lines)
 }
 
+// Move to the nearest line above with the same indentation as the current one.
+// With a numeric argument, skip that many lines with the same indentation.
+func (rl *Shell) gotoMatchingIndentUp() {
+	rl.History.SkipSave()
+	rl.gotoMatchingIndent(-1 * rl.Iterations.Get())
+}
+
+// Move to the nearest line below with the same indentation as the current one.
+// With a numeric argument, skip that many lines with the same indentation.
+func (rl *Shell) gotoMatchingIndentDown() {
+	rl.History.SkipSave()
+	rl.gotoMatchingIndent(rl.Iterations.Get())
+}
+
+// Move the cursor to the absolute position given by the numeric argument (the
+// first character being at 0), or to the beginning of the buffer without one.
+// Positions out of the buffer move the cursor to its beginning or end.
+func (rl *Shell) gotoPosition() {
+	rl.History.SkipSave()
+
+	pos := 0
+	if rl.Iterations.IsSet() {
+		pos = rl.Iterations.Get()
+	}
+
+	rl.cursor.Set(pos)
+}
+
+// Move the cursor to the beginning of the line given by the numeric argument
+// (the first line being 1) in a multiline buffer, or to the first line without
+// one. Lines out of the buffer move the cursor to its first or last line.
+func (rl *Shell) gotoLine() {
+	rl.History.SkipSave()
+
+	line := 1
+	if rl.Iterations.IsSet() {
+		line = rl.Iterations.Get()
+	}
+
+	pos := 0
+
+	for i, char := range *rl.line {
+		if line <= 1 {
+			break
+		}
+
+		if char == '\n' {
+			line--
+			pos = i + 1
+		}
+	}
+
+	rl.cursor.Set(pos)
+}
+
+// Fold or unfold the display of an input line too long for the terminal width:
+// when folded, the middle of the line is displayed as an ellipsis. The line
+// itself is unchanged, and the cursor still moves through all of it.
+func (rl *Shell) toggleFold() {
+	rl.History.SkipSave()
+	rl.Display.ToggleFold()
+}
+
 // Clear the current screen and redisplay the prompt and input line.
 // This does not clear the terminal's output buffer.
 func (rl *Shell) clearScreen() {
 	rl.History.SkipSave()
 
-	fmt.Print(term.CursorTopLeft)
-	fmt.Print(term.ClearScreen)
+	term.Print(term.CursorTopLeft)
+	term.Print(term.ClearScreen)
 
 	rl.Display.PrintPrimaryPrompt()
 }
@@ -300,8 +414,8 @@ func (rl *Shell) clearScreen() {
 func (rl *Shell) clearDisplay() {
 	rl.History.SkipSave()
 
-	fmt.Print(term.CursorTopLeft)
-	fmt.Print(term.ClearDisplay)
+	term.Print(term.CursorTopLeft)
+	term.Print(term.ClearDisplay)
 
 	rl.Display.PrintPrimaryPrompt()
 }
@@ -318,6 +432,7 @@ func (rl *Shell) clearDisplay() {
 func (rl *Shell) endOfFile() {
 	switch rl.line.Len() {
 	case 0:
+		rl.echoControlKey(rl.Keys.Caller()[0])
 		rl.Display.AcceptLine()
 		rl.History.Accept(false, false, io.EOF)
 	default:
@@ -363,7 +478,7 @@ func (rl *Shell) backwardDeleteChar() {
 	switch vii {
 	case 1:
 		// Handle removal of autopairs characters.
-		if rl.Config.GetBool("autopairs") {
+		if rl.autoPairing() {
 			completion.AutopairDelete(rl.line, rl.cursor)
 		}
 
@@ -426,8 +541,16 @@ func (rl *Shell) selfInsert() {
 	searching, _, _ := rl.completer.NonIncrementallySearching()
 	isearch := rl.Keymap.Local() == keymap.Isearch
 
-	if !searching && !isearch && rl.Config.GetBool("autopairs") {
-		if jump := completion.AutopairInsertOrJump(key[0], rl.line, rl.cursor); jump {
+	if !searching && !isearch {
+		if rl.autoPairing() && rl.Config.GetBool("auto-pair-wrap") && rl.wrapSelection(key[0]) {
+			return
+		}
+
+		if rl.Config.GetBool("replace-selection-on-type") || rl.placeholderSelected() {
+			rl.cutVisualSelection()
+		}
+
+		if rl.autoPairing() && completion.AutopairInsertOrJump(key[0], rl.line, rl.cursor) {
 			return
 		}
 	}
@@ -447,6 +570,44 @@ func (rl *Shell) selfInsert() {
 	rl.cursor.Move(length)
 }
 
+// wrapSelection surrounds the visual selection with the pair of the typed bracket
+// or quote, and places the cursor after the closing character. Returns false if
+// the key is not a bracket or a quote, or if no selection is highlighted.
+func (rl *Shell) wrapSelection(key rune) (wrapped bool) {
+	if !rl.selection.Active() || !rl.selection.IsVisual() || rl.selection.Len() == 0 {
+		return false
+	}
+
+	if surround, _ := strutil.SurroundType(key); !surround {
+		return false
+	}
+
+	rl.History.Save()
+
+	bchar, echar := strutil.MatchSurround(key)
+	_, epos := rl.selection.Pos()
+
+	rl.selection.Surround(bchar, echar)
+	rl.cursor.Set(epos + 2)
+
+	return true
+}
+
+// cutVisualSelection deletes the text of the visual selection, if any,
+// and places the cursor where it started, so that the inserted text
+// replaces it. The kill ring is not modified.
+func (rl *Shell) cutVisualSelection() {
+	if !rl.selection.Active() || !rl.selection.IsVisual() || rl.selection.Len() == 0 {
+		return
+	}
+
+	rl.History.Save()
+
+	bpos, _ := rl.selection.Pos()
+	rl.selection.Cut()
+	rl.cursor.Set(bpos)
+}
+
 func (rl *Shell) bracketedPasteBegin() {
 	// keys, _ := rl.Keys.PeekAllBytes()
 	// fmt.Println(string(keys))
@@ -455,27 +616,31 @@ func (rl *Shell) bracketedPasteBegin() {
 // Drag the character before point forward over the character
 // at point, moving point forward as well.  If point is at the
 // end of the line, then this transposes the two characters
-// before point.  Negative arguments have no effect.
+// before point.  With a numeric argument N, the character is
+// dragged over N characters, or backward if N is negative.
 func (rl *Shell) transposeChars() {
-	if rl.cursor.Pos() < 2 || rl.line.Len() < 2 {
+	vii := rl.Iterations.Get()
+
+	if rl.cursor.Pos() == 0 || rl.line.Len() < 2 {
 		rl.History.SkipSave()
 		return
 	}
 
 	rl.History.Save()
 
-	switch {
-	case rl.cursor.Pos() == rl.line.Len():
-		last := (*rl.line)[rl.cursor.Pos()-1]
-		blast := (*rl.line)[rl.cursor.Pos()-2]
-		(*rl.line)[rl.cursor.Pos()-2] = last
-		(*rl.line)[rl.cursor.Pos()-1] = blast
-	default:
-		last := rl.cursor.Char()
-		blast := (*rl.line)[rl.cursor.Pos()-1]
-		(*rl.line)[rl.cursor.Pos()-1] = last
-		rl.cursor.ReplaceWith(blast)
+	// At the end of the line, the two characters before point
+	// are transposed, regardless of the numeric argument.
+	if rl.cursor.Pos() == rl.line.Len() {
+		rl.cursor.Dec()
+		vii = 1
 	}
+
+	rl.cursor.Dec()
+	char := rl.cursor.Char()
+	rl.line.CutRune(rl.cursor.Pos())
+
+	rl.cursor.Move(vii)
+	rl.cursor.InsertAt(char)
 }
 
 // Drag the word before point past the word after point,
@@ -524,13 +689,10 @@ func (rl *Shell) transposeWords() {
 		transposeWith, toTranspose = toTranspose, transposeWith
 	}
 
-	// Assemble the newline
-	begin := string((*rl.line)[:wbpos])
-	newLine := append([]rune(begin), []rune(toTranspose)...)
-	newLine = append(newLine, (*rl.line)[wepos:tbpos]...)
-	newLine = append(newLine, []rune(transposeWith)...)
-	newLine = append(newLine, (*rl.line)[tepos:]...)
-	rl.line.Set(newLine...)
+	// Swap the words, the last one first so that
+	// the positions of the first one are still valid.
+	rl.line.Replace(tbpos, tepos, []rune(transposeWith)...)
+	rl.line.Replace(wbpos, wepos, []rune(toTranspose)...)
 
 	// And replace the cursor
 	rl.cursor.Set(tepos)
@@ -564,13 +726,10 @@ func (rl *Shell) shellTransposeWords() {
 		return
 	}
 
-	// Assemble the newline
-	begin := string((*rl.line)[:wbpos])
-	newLine := append([]rune(begin), []rune(toTranspose)...)
-	newLine = append(newLine, (*rl.line)[wepos:tbpos]...)
-	newLine = append(newLine, []rune(transposeWith)...)
-	newLine = append(newLine, (*rl.line)[tepos:]...)
-	rl.line.Set(newLine...)
+	// Swap the words, the last one first so that
+	// the positions of the first one are still valid.
+	rl.line.Replace(tbpos, tepos, []rune(transposeWith)...)
+	rl.line.Replace(wbpos, wepos, []rune(toTranspose)...)
 
 	// And replace cursor
 	rl.cursor.Set(tepos)
@@ -759,6 +918,60 @@ func (rl *Shell) quoteLine() {
 	rl.line.Insert(rl.line.Len(), '\'')
 }
 
+// Cycle the shell word under the cursor (or the active selection) between its
+// unquoted, single-quoted and double-quoted forms, escaping embedded quotes and
+// special characters as needed, so that its literal value is always the same.
+func (rl *Shell) cycleQuoting() {
+	word, bpos, epos, found := rl.shellWordUnderCursor()
+	if !found {
+		return
+	}
+
+	value, err := strutil.Unquote(word)
+	if err != nil {
+		return
+	}
+
+	rl.History.Save()
+
+	var quoted string
+
+	switch word {
+	case strutil.QuoteSingle(value):
+		quoted = strutil.QuoteDouble(value)
+	case strutil.QuoteDouble(value):
+		quoted = strutil.Escape(value)
+	}
+
+	// An empty value cannot be unquoted.
+	if quoted == "" {
+		quoted = strutil.QuoteSingle(value)
+	}
+
+	rl.line.Replace(bpos, epos, []rune(quoted)...)
+	rl.cursor.Set(bpos + len([]rune(quoted)))
+}
+
+// Replace the shell word under the cursor (or the active selection) with its
+// literal value, as a shell would pass it to a program: quotes are removed and
+// backslash-escapes resolved, including in mixed quoting (eg. "a b"'c'\ d).
+func (rl *Shell) dequoteWord() {
+	word, bpos, epos, found := rl.shellWordUnderCursor()
+	if !found {
+		return
+	}
+
+	value, err := strutil.Unquote(word)
+	if err != nil || value == word {
+		return
+	}
+
+	rl.History.Save()
+
+	rl.line.Replace(bpos, epos, []rune(value)...)
+	rl.cursor.Set(bpos + len([]rune(value)))
+}
+
 // Modifies the current word under the cursor, increasing it.
 // The following word types can be incremented/decremented:
 //
@@ -769,7 +982,7 @@ func (rl *Shell) quoteLine() {
 //	Integers.
 func (rl *Shell) keywordIncrease() {
 	rl.History.Save()
-	rl.keywordSwitch(true)
+	rl.keywordSwitch(true, rl.keywordSwitchers())
 }
 
 // Modifies the current word under the cursor, decreasing it.
@@ -782,222 +995,1023 @@ func (rl *Shell) keywordIncrease() {
 //	Integers.
 func (rl *Shell) keywordDecrease() {
 	rl.History.Save()
-	rl.keywordSwitch(false)
+	rl.keywordSwitch(false, rl.keywordSwitchers())
 }
 
-// Switches the current word under the cursor, increasing or decreasing it.
-func (rl *Shell) keywordSwitch(increase bool) {
-	cpos := strutil.AdjustNumberOperatorPos(rl.cursor.Pos(), *rl.line)
-
-	// Select in word and get the selection positions
-	bpos, epos := rl.line.SelectWord(cpos)
-	epos++
+// Replace the word under the cursor with the next one in the group of words
+// (registered with AddRotation) containing it. With a numeric argument, move
+// that many words forward in the group, or backward if negative.
+func (rl *Shell) rotateWord() {
+	rl.History.Save()
+	rl.keywordSwitch(true, []strutil.KeywordSwitcher{strutil.RotationSwitcher(rl.rotations)})
+}
 
-	// Move the cursor backward if needed/possible
-	if bpos != 0 && ((*rl.line)[bpos-1] == '+' || (*rl.line)[bpos-1] == '-') {
-		bpos--
+// Toggle the buffer between its multiline form and a single line, in which
+// newlines are replaced with the multiline-join-separator string ("; " by
+// default). A single line is expanded by replacing this separator with newlines.
+func (rl *Shell) expandMultiline() {
+	separator := strings.Trim(rl.Config.GetString("multiline-join-separator"), "\"")
+	if separator == "" {
+		separator = "; "
 	}
 
-	// Get the selection string
-	selection := string((*rl.line)[bpos:epos])
+	from, to := separator, "\n"
+	if strings.Contains(string(*rl.line), "\n") {
+		from, to = to, separator
+	}
 
-	// For each of the keyword handlers, run it, which returns
-	// false/none if didn't operate, then continue to next handler.
-	for _, switcher := range strutil.KeywordSwitchers() {
-		vii := rl.Iterations.Get()
+	if !strings.Contains(string(*rl.line), from) {
+		return
+	}
 
-		changed, word, obpos, oepos := switcher(selection, increase, vii)
-		if !changed {
-			continue
-		}
+	rl.History.Save()
 
-		// We are only interested in the end position after all runs
-		epos = bpos + oepos
-		bpos += obpos
+	// Keep the cursor on the same text.
+	before := strings.ReplaceAll(string((*rl.line)[:rl.cursor.Pos()]), from, to)
+	line := strings.ReplaceAll(string(*rl.line), from, to)
 
-		if cpos < bpos || cpos >= epos {
-			continue
-		}
+	rl.line.Set([]rune(line)...)
+	rl.cursor.Set(len([]rune(before)))
+}
 
-		// Update the line and the cursor, and return
-		// since we have a handler that has been ran.
-		begin := string((*rl.line)[:bpos])
-		end := string((*rl.line)[epos:])
+// Prompt for a string and its replacement in the hint section, and replace all
+// occurrences of the former in the line. A single s/old/new/ input can be used
+// instead (slashes escaped with a backslash), with a trailing i for the search
+// to ignore case. The cursor is kept on the same text, or moved at the end of
+// the replacement if it was within a replaced occurrence.
+func (rl *Shell) replaceInLine() {
+	rl.History.Save()
 
-		newLine := append([]rune(begin), []rune(word)...)
-		newLine = append(newLine, []rune(end)...)
-		rl.line.Set(newLine...)
-		rl.cursor.Set(bpos + len(word) - 1)
+	old, replacement, ignoreCase, ok := rl.readReplacement()
+	if !ok {
+		return
+	}
 
+	matches := findLiteral(*rl.line, old, ignoreCase)
+	if len(matches) == 0 {
+		rl.Hint.SetTemporary(color.FgRed + "No match for " + old)
 		return
 	}
-}
 
-//
-// Killing & Yanking ----------------------------------------------------------
-//
+	line, pos := *rl.line, rl.cursor.Pos()
+	with := []rune(replacement)
+	replaced := make([]rune, 0, len(line)+len(matches)*len(with))
+	cpos, last := pos, 0
 
-// Kill from the cursor to the end of the line. If already
-// on the end of the line, kill the newline character.
-func (rl *Shell) killLine() {
-	rl.Iterations.Reset()
-	rl.History.Save()
+	for _, match := range matches {
+		replaced = append(replaced, line[last:match[0]]...)
+		replaced = append(replaced, with...)
 
-	if rl.line.Len() == 0 {
-		return
-	}
+		switch {
+		case pos >= match[1]:
+			cpos += len(with) - (match[1] - match[0])
+		case pos > match[0]:
+			cpos = len(replaced)
+		}
 
-	cpos := rl.cursor.Pos()
-	rl.cursor.EndOfLineAppend()
+		last = match[1]
+	}
 
-	rl.selection.MarkRange(cpos, rl.cursor.Pos())
-	text := rl.selection.Cut()
+	replaced = append(replaced, line[last:]...)
 
-	rl.Buffers.Write([]rune(text)...)
+	rl.line.Set(replaced...)
 	rl.cursor.Set(cpos)
 }
 
-// Kill backward to the beginning of the line.
-func (rl *Shell) backwardKillLine() {
-	rl.Iterations.Reset()
+// Prompt for a string and its replacement like replace-in-line, and step through
+// each occurrence of the former from the cursor to the end of the line, asking
+// for confirmation: y or space replaces the highlighted occurrence, n or delete
+// skips it, ! replaces it and all the following ones, and q or enter stops.
+// When skipping, occurrences overlapping the skipped one are proposed next.
+// All replacements are undone at once.
+func (rl *Shell) queryReplace() {
 	rl.History.Save()
 
-	if rl.line.Len() == 0 {
+	old, replacement, ignoreCase, ok := rl.readReplacement()
+	if !ok {
 		return
 	}
 
-	cpos := rl.cursor.Pos()
-	rl.cursor.BeginningOfLine()
+	done := rl.Keymap.PendingCursor()
+	defer done()
 
-	rl.selection.MarkRange(rl.cursor.Pos(), cpos)
-	text := rl.selection.Cut()
+	with := []rune(replacement)
+	pos, replaced, all := rl.cursor.Pos(), 0, false
 
-	rl.Buffers.Write([]rune(text)...)
-}
+	defer func() {
+		rl.Hint.SetTemporary(color.Dim + fmt.Sprintf("Replaced %d occurrence(s)", replaced))
+	}()
 
-// Kill all characters on the current line, no matter where point is.
-func (rl *Shell) killWholeLine() {
-	rl.History.Save()
+	for {
+		matches := findLiteral((*rl.line)[pos:], old, ignoreCase)
+		if len(matches) == 0 {
+			break
+		}
 
-	if rl.line.Len() == 0 {
-		return
-	}
+		bpos, epos := pos+matches[0][0], pos+matches[0][1]
 
-	rl.Buffers.Write(*rl.line...)
-	rl.line.Cut(0, rl.line.Len())
-}
+		if !all {
+			rl.cursor.Set(bpos)
+			rl.selection.MarkRange(bpos, epos-1)
+			rl.selection.Visual(false)
+			rl.Hint.SetTemporary(color.Dim + "Replace " + old + " with " + replacement + "? (y/n/!/q) " + color.Reset)
+			rl.Display.Refresh()
 
-// Kill the entire buffer.
-func (rl *Shell) killBuffer() {
-	rl.History.Save()
+			key, isAbort := rl.Keys.ReadKey()
+			rl.selection.Reset()
 
-	if rl.line.Len() == 0 {
-		return
+			// When stopped, the cursor is left on the current occurrence.
+			if isAbort {
+				return
+			}
+
+			switch key {
+			case 'y', inputrc.Space:
+			case '!':
+				all = true
+			case 'n', inputrc.Backspace, inputrc.Delete:
+				pos = bpos + 1
+				continue
+			case 'q', inputrc.Return, inputrc.Newline:
+				return
+			default:
+				continue
+			}
+		}
+
+		rl.line.Replace(bpos, epos, with...)
+		pos = bpos + len(with)
+		replaced++
 	}
 
-	rl.Buffers.Write(*rl.line...)
-	rl.line.Cut(0, rl.line.Len())
+	rl.cursor.Set(pos)
 }
 
-// Kill the current word from the cursor point up to the end of it.
-func (rl *Shell) killWord() {
-	rl.History.Save()
+// readReplacement reads a string to search and its replacement in the hint
+// section, either with two prompts or a single s/old/new/ input, in which case
+// the search may also ignore case.
+func (rl *Shell) readReplacement() (old, replacement string, ignoreCase, ok bool) {
+	input, ok := rl.readHintInput("Replace: ")
+	if !ok || input == "" {
+		return "", "", false, false
+	}
 
-	bpos := rl.cursor.Pos()
+	if old, replacement, ignoreCase, ok = parseSubstitution(input); ok {
+		return old, replacement, ignoreCase, true
+	}
 
-	rl.cursor.ToFirstNonSpace(true)
-	forward := rl.line.Forward(rl.line.TokenizeSpace, rl.cursor.Pos())
-	rl.cursor.Move(forward - 1)
-	epos := rl.cursor.Pos()
+	replacement, ok = rl.readHintInput("Replace " + input + " with: ")
 
-	rl.selection.MarkRange(bpos, epos)
-	rl.Buffers.Write([]rune(rl.selection.Cut())...)
-	rl.cursor.Set(bpos)
+	return input, replacement, false, ok
 }
 
-// Kill the word behind point. Word boundaries
-// are the same as those used by backward-word.
-func (rl *Shell) backwardKillWord() {
-	rl.History.Save()
-	rl.History.SkipSave()
+// parseSubstitution parses a s/old/new/ or s/old/new/i input, in which slashes
+// can be escaped with a backslash. Returns false if the input is not one.
+func parseSubstitution(input string) (old, replacement string, ignoreCase, ok bool) {
+	if !strings.HasPrefix(input, "s/") {
+		return
+	}
 
-	rl.selection.Mark(rl.cursor.Pos())
-	adjust := rl.line.Backward(rl.line.Tokenize, rl.cursor.Pos())
-	rl.cursor.Move(adjust)
+	var parts []string
+	var part strings.Builder
 
-	rl.Buffers.Write([]rune(rl.selection.Cut())...)
-}
+	runes := []rune(input[2:])
 
-// Kill the text between the point and mark (saved cursor
-// position).  This text is referred to as the region.
-func (rl *Shell) killRegion() {
-	rl.History.Save()
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '/':
+			part.WriteRune('/')
+			i++
+		case runes[i] == '/':
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteRune(runes[i])
+		}
+	}
 
-	if !rl.selection.Active() {
+	flags := part.String()
+
+	if len(parts) != 2 || parts[0] == "" || (flags != "" && flags != "i") {
 		return
 	}
 
-	rl.Buffers.Write([]rune(rl.selection.Cut())...)
+	return parts[0], parts[1], flags == "i", true
 }
 
-// Copy the text in the region to the kill buffer.
-func (rl *Shell) copyRegionAsKill() {
-	rl.History.SkipSave()
+// findLiteral returns the rune positions (start and end) of all non-overlapping
+// occurrences of a string in the line, optionally ignoring case.
+func findLiteral(line []rune, search string, ignoreCase bool) [][2]int {
+	if search == "" {
+		return nil
+	}
+
+	expr := regexp.QuoteMeta(search)
+	if ignoreCase {
+		expr = "(?i)" + expr
+	}
+
+	str := string(line)
+	matches := regexp.MustCompile(expr).FindAllStringIndex(str, -1)
+	positions := make([][2]int, 0, len(matches))
+
+	// Convert byte offsets to rune ones while walking the line.
+	bytePos, runePos := 0, 0
+
+	for _, match := range matches {
+		runePos += utf8.RuneCountInString(str[bytePos:match[0]])
+		start := runePos
+		runePos += utf8.RuneCountInString(str[match[0]:match[1]])
+		bytePos = match[1]
+
+		positions = append(positions, [2]int{start, runePos})
+	}
 
+	return positions
+}
+
+// Add the numeric argument (1 by default) to all integers in the active region:
+// numbers with leading zeros keep their width (eg. 007 => 008). A minus sign
+// before a number is part of it, unless following a letter or a digit.
+func (rl *Shell) incrementNumbersInRegion() {
 	if !rl.selection.Active() {
+		rl.History.SkipSave()
 		return
 	}
 
-	rl.Buffers.Write([]rune(rl.selection.Text())...)
+	bpos, epos := rl.selection.Pos()
+	if bpos == -1 || epos == -1 {
+		rl.History.SkipSave()
+		return
+	}
+
+	region := string((*rl.line)[bpos:epos])
+	incremented := []rune(strutil.IncrementNumbers(region, rl.Iterations.Get()))
+
 	rl.selection.Reset()
-}
 
-// Copy the word before point to the kill buffer.
-// The word boundaries are the same as backward-word.
-func (rl *Shell) copyBackwardWord() {
+	if string(incremented) == region {
+		return
+	}
+
 	rl.History.Save()
 
-	rl.selection.Mark(rl.cursor.Pos())
-	adjust := rl.line.Backward(rl.line.Tokenize, rl.cursor.Pos())
-	rl.cursor.Move(adjust)
+	rl.line.Replace(bpos, epos, incremented...)
 
-	rl.Buffers.Write([]rune(rl.selection.Text())...)
-	rl.selection.Reset()
+	if rl.cursor.Pos() > bpos {
+		rl.cursor.Set(bpos + len(incremented))
+	}
 }
 
-// Copy the word following point to the kill buffer.
-// The word boundaries are the same as forward-word.
-func (rl *Shell) copyForwardWord() {
+// Prompt for a string in the hint section, and insert it at the same column on
+// each line of a block: the lines spanned by the active region, at the leftmost
+// column of its ends, or if no region is active, as many lines as the numeric
+// argument from the current one, at the cursor column. Lines shorter than the
+// column are skipped, or padded with spaces if block-insert-pad is on.
+func (rl *Shell) blockInsert() {
 	rl.History.Save()
 
-	rl.selection.Mark(rl.cursor.Pos())
-	adjust := rl.line.Forward(rl.line.Tokenize, rl.cursor.Pos())
-	rl.cursor.Move(adjust + 1)
+	start, end := rl.cursor.Pos(), rl.cursor.Pos()
+	bpos, epos := rl.selection.Pos()
+	region := bpos != -1
 
-	rl.Buffers.Write([]rune(rl.selection.Text())...)
-	rl.selection.Reset()
-}
+	if region {
+		start, end = bpos, epos
 
-// Yank the top of the kill ring into the buffer at point.
+		if rl.selection.IsVisual() && end > start {
+			end--
+		}
+	}
+
+	rl.selection.Reset()
+
+	insert, ok := rl.readHintInput("Insert: ")
+	if !ok || insert == "" {
+		return
+	}
+
+	first, scol := lineColumn(*rl.line, start)
+	last, ecol := lineColumn(*rl.line, end)
+
+	if !region {
+		last = first + max(rl.Iterations.Get(), 1) - 1
+	}
+
+	column := min(scol, ecol)
+	pad := rl.Config.GetBool("block-insert-pad")
+	lines := strings.Split(string(*rl.line), "\n")
+	cpos, lineStart := -1, 0
+
+	for i := 0; i < first && i < len(lines); i++ {
+		lineStart += len([]rune(lines[i])) + 1
+	}
+
+	for i := first; i <= last && i < len(lines); i++ {
+		line := []rune(lines[i])
+		begin := lineStart
+		lineStart += len(line) + 1
+
+		if len(line) < column && !pad {
+			continue
+		}
+
+		if len(line) < column {
+			line = append(line, []rune(strings.Repeat(" ", column-len(line)))...)
+		}
+
+		lines[i] = string(line[:column]) + insert + string(line[column:])
+
+		// Leave the cursor after the text inserted on the first line.
+		if cpos == -1 {
+			cpos = begin + column + len([]rune(insert))
+		}
+	}
+
+	if cpos == -1 {
+		return
+	}
+
+	rl.line.Set([]rune(strings.Join(lines, "\n"))...)
+	rl.cursor.Set(cpos)
+}
+
+// Prefix each line of the region with a number, incremented on each line and
+// starting at the numeric argument (1 by default), or with all lines in the
+// buffer if there is no region. The prefix is given by the number-lines-format
+// option, in which %d is replaced with the number ("%d. " by default): if it has
+// no %d, like "- ", the lines are prefixed with this bullet. The cursor stays on
+// the same text.
+func (rl *Shell) numberLines() {
+	format := strings.Trim(rl.Config.GetString("number-lines-format"), "\"")
+	if format == "" {
+		rl.History.SkipSave()
+		return
+	}
+
+	rl.History.Save()
+
+	number := rl.Iterations.Get()
+	lines := strings.Split(string(*rl.line), "\n")
+	first, last := 0, len(lines)-1
+
+	if bpos, epos := rl.selection.Pos(); bpos != -1 {
+		if rl.selection.IsVisual() && epos > bpos {
+			epos--
+		}
+
+		first, _ = lineColumn(*rl.line, bpos)
+		last, _ = lineColumn(*rl.line, epos)
+	}
+
+	rl.selection.Reset()
+
+	cline, _ := lineColumn(*rl.line, rl.cursor.Pos())
+	cpos := rl.cursor.Pos()
+
+	for i := first; i <= last; i++ {
+		prefix := strings.ReplaceAll(format, "%d", strconv.Itoa(number))
+		lines[i] = prefix + lines[i]
+		number++
+
+		if i <= cline {
+			cpos += len([]rune(prefix))
+		}
+	}
+
+	rl.line.Set([]rune(strings.Join(lines, "\n"))...)
+	rl.cursor.Set(cpos)
+}
+
+// Delete blank lines around the cursor, as in Emacs: on a blank line in a run of
+// blank lines, delete all of them but one, which is emptied; on an isolated blank
+// line, delete it; on a non-blank line, delete all blank lines following it.
+// Lines containing only spaces and tabs are blank.
+func (rl *Shell) deleteBlankLines() {
+	lines := strings.Split(string(*rl.line), "\n")
+	cline, _ := lineColumn(*rl.line, rl.cursor.Pos())
+
+	blank := func(i int) bool {
+		return i >= 0 && i < len(lines) && strings.TrimSpace(lines[i]) == ""
+	}
+
+	// The lines kept before and after the deleted ones,
+	// and the line to put the cursor on, if it moves.
+	var before, after []string
+	target := -1
+
+	switch {
+	case !blank(cline):
+		last := cline
+		for blank(last + 1) {
+			last++
+		}
+
+		before, after = lines[:cline+1], lines[last+1:]
+
+	case !blank(cline-1) && !blank(cline+1):
+		before, after = lines[:cline], lines[cline+1:]
+		target = cline
+
+	default:
+		first, last := cline, cline
+		for blank(first - 1) {
+			first--
+		}
+
+		for blank(last + 1) {
+			last++
+		}
+
+		// Keep a single, empty line.
+		before, after = append(lines[:first:first], ""), lines[last+1:]
+		target = first
+	}
+
+	result := append(append([]string{}, before...), after...)
+	if len(result) == 0 {
+		result = []string{""}
+	}
+
+	if strings.Join(result, "\n") == string(*rl.line) {
+		rl.History.SkipSave()
+		return
+	}
+
+	rl.History.Save()
+
+	rl.line.Set([]rune(strings.Join(result, "\n"))...)
+
+	if target == -1 {
+		return
+	}
+
+	// Go at the beginning of the target line, or at the end of the buffer.
+	cpos := 0
+	for i := 0; i < target && i < len(result); i++ {
+		cpos += len([]rune(result[i])) + 1
+	}
+
+	rl.cursor.Set(min(cpos, rl.line.Len()))
+}
+
+// Move the current line above the previous one, with the cursor on it, or
+// by as many lines as the numeric argument. Nothing is done on the first line.
+func (rl *Shell) moveLineUp() {
+	rl.moveLine(-rl.Iterations.Get())
+}
+
+// Move the current line below the next one, with the cursor on it, or by
+// as many lines as the numeric argument. Nothing is done on the last line.
+func (rl *Shell) moveLineDown() {
+	rl.moveLine(rl.Iterations.Get())
+}
+
+// moveLine moves the current line by some lines up (if negative) or down,
+// as far as possible, and keeps the cursor on the same text.
+func (rl *Shell) moveLine(offset int) {
+	lines := strings.Split(string(*rl.line), "\n")
+	cline, column := lineColumn(*rl.line, rl.cursor.Pos())
+	target := max(0, min(cline+offset, len(lines)-1))
+
+	if target == cline {
+		rl.History.SkipSave()
+		return
+	}
+
+	rl.History.Save()
+
+	moved := lines[cline]
+	lines = append(lines[:cline], lines[cline+1:]...)
+	lines = append(lines[:target], append([]string{moved}, lines[target:]...)...)
+
+	rl.line.Set([]rune(strings.Join(lines, "\n"))...)
+
+	cpos := column
+	for i := 0; i < target; i++ {
+		cpos += len([]rune(lines[i])) + 1
+	}
+
+	rl.cursor.Set(cpos)
+}
+
+// lineColumn returns the index of the line on which a position is in a buffer
+// (lines being separated by newlines), and the column of this position on it.
+func lineColumn(line []rune, pos int) (index, column int) {
+	for i := 0; i < pos && i < len(line); i++ {
+		column++
+
+		if line[i] == '\n' {
+			index++
+			column = 0
+		}
+	}
+
+	return index, column
+}
+
+// Replace tabs with spaces in the indentation of all lines in the buffer, with
+// tab stops every tab-width columns (8 by default). If the option
+// tab-convert-leading-only is off, all tabs in the buffer are replaced.
+func (rl *Shell) tabsToSpaces() {
+	rl.convertIndentation(strutil.ExpandTabs)
+}
+
+// Replace spaces with tabs in the indentation of all lines in the buffer,
+// wherever they reach a tab stop (every tab-width columns, 8 by default). If
+// the option tab-convert-leading-only is off, all spaces in the buffer
+// are converted, except single ones.
+func (rl *Shell) spacesToTabs() {
+	rl.convertIndentation(strutil.UnexpandSpaces)
+}
+
+// convertIndentation rebuilds the line converted from tabs to spaces or
+// spaces to tabs, keeping the cursor on the same text.
+func (rl *Shell) convertIndentation(convert func(line []rune, pos, width int, leading bool) ([]rune, int)) {
+	width := rl.Config.GetInt("tab-width")
+	if width <= 0 {
+		width = 8
+	}
+
+	leading := rl.Config.GetBool("tab-convert-leading-only")
+
+	line, pos := convert(*rl.line, rl.cursor.Pos(), width, leading)
+	if string(line) == string(*rl.line) {
+		rl.History.SkipSave()
+		return
+	}
+
+	rl.History.Save()
+
+	rl.line.Set(line...)
+	rl.cursor.Set(pos)
+}
+
+// Add the toggle-prefix string ("sudo " by default) at the beginning of the line,
+// or remove it if the line already starts with it. The cursor stays on the same
+// text. The prefix can also be set with SetTogglePrefix().
+func (rl *Shell) togglePrefix() {
+	prefix := []rune(strings.Trim(rl.Config.GetString("toggle-prefix"), "\""))
+	if len(prefix) == 0 {
+		rl.History.SkipSave()
+		return
+	}
+
+	rl.History.Save()
+
+	if strings.HasPrefix(string(*rl.line), string(prefix)) {
+		rl.line.Cut(0, len(prefix))
+		rl.cursor.Set(max(rl.cursor.Pos()-len(prefix), 0))
+
+		return
+	}
+
+	rl.line.Insert(0, prefix...)
+	rl.cursor.Set(rl.cursor.Pos() + len(prefix))
+}
+
+// Swap the case of each letter in the region: uppercase letters are lowercased,
+// and lowercase ones are uppercased. Nothing is done if there is no region, and
+// the region is deactivated even if empty.
+func (rl *Shell) swapCaseRegion() {
+	if !rl.selection.Active() {
+		rl.History.SkipSave()
+		return
+	}
+
+	if rl.selection.Len() == 0 {
+		rl.History.SkipSave()
+		rl.selection.Reset()
+
+		return
+	}
+
+	rl.History.Save()
+
+	rl.selection.ReplaceWith(func(char rune) rune {
+		if unicode.IsUpper(char) {
+			return unicode.ToLower(char)
+		}
+
+		return unicode.ToUpper(char)
+	})
+}
+
+// Collapse each run of blanks (spaces and tabs) in the region into a single space,
+// and delete those at the beginning and end of each of its lines, eg. to clean up
+// pasted text. Without a region, the whole line is trimmed.
+func (rl *Shell) trimRegion() {
+	rl.History.Save()
+
+	bpos, epos := 0, rl.line.Len()
+
+	if rl.selection.Active() {
+		_, bpos, epos, _ = rl.selection.Pop()
+	}
+
+	if bpos == -1 || epos == -1 {
+		return
+	}
+
+	lines := strings.Split(string((*rl.line)[bpos:epos]), "\n")
+	for i, line := range lines {
+		lines[i] = strings.Join(strings.Fields(line), " ")
+	}
+
+	trimmed := []rune(strings.Join(lines, "\n"))
+
+	line := append([]rune{}, (*rl.line)[:bpos]...)
+	line = append(line, trimmed...)
+	line = append(line, (*rl.line)[epos:]...)
+
+	// Keep the cursor on the same text, or at
+	// the end of the region if it was inside.
+	cpos := rl.cursor.Pos()
+
+	switch {
+	case cpos >= epos:
+		cpos += len(trimmed) - (epos - bpos)
+	case cpos > bpos:
+		cpos = bpos + len(trimmed)
+	}
+
+	rl.line.Set(line...)
+	rl.cursor.Set(cpos)
+}
+
+// Switches the current word under the cursor, increasing or decreasing it.
+func (rl *Shell) keywordSwitch(increase bool, switchers []strutil.KeywordSwitcher) {
+	cpos := strutil.AdjustNumberOperatorPos(rl.cursor.Pos(), *rl.line)
+
+	// Select in word and get the selection positions
+	bpos, epos := rl.line.SelectWord(cpos)
+	epos++
+
+	// Move the cursor backward if needed/possible
+	if bpos != 0 && ((*rl.line)[bpos-1] == '+' || (*rl.line)[bpos-1] == '-') {
+		bpos--
+	}
+
+	// Get the selection string
+	selection := string((*rl.line)[bpos:epos])
+
+	// For each of the keyword handlers, run it, which returns
+	// false/none if didn't operate, then continue to next handler.
+	for _, switcher := range switchers {
+		vii := rl.Iterations.Get()
+
+		changed, word, obpos, oepos := switcher(selection, increase, vii)
+		if !changed {
+			continue
+		}
+
+		// We are only interested in the end position after all runs
+		epos = bpos + oepos
+		bpos += obpos
+
+		if cpos < bpos || cpos >= epos {
+			continue
+		}
+
+		// Update the line and the cursor, and return
+		// since we have a handler that has been ran.
+		rl.line.Replace(bpos, epos, []rune(word)...)
+		rl.cursor.Set(bpos + len(word) - 1)
+
+		return
+	}
+}
+
+//
+// Killing & Yanking ----------------------------------------------------------
+//
+
+// Kill from the cursor to the end of the line. If already
+// on the end of the line, kill the newline character.
+func (rl *Shell) killLine() {
+	rl.Iterations.Reset()
+	rl.History.Save()
+
+	if rl.line.Len() == 0 {
+		return
+	}
+
+	cpos := rl.cursor.Pos()
+	rl.cursor.EndOfLineAppend()
+
+	rl.selection.MarkRange(cpos, rl.cursor.Pos())
+	text := rl.selection.Cut()
+
+	rl.Buffers.Kill(false, []rune(text)...)
+	rl.cursor.Set(cpos)
+}
+
+// Kill backward to the beginning of the line.
+func (rl *Shell) backwardKillLine() {
+	rl.Iterations.Reset()
+	rl.History.Save()
+
+	if rl.line.Len() == 0 {
+		return
+	}
+
+	cpos := rl.cursor.Pos()
+	rl.cursor.BeginningOfLine()
+
+	rl.selection.MarkRange(rl.cursor.Pos(), cpos)
+	text := rl.selection.Cut()
+
+	rl.Buffers.Kill(true, []rune(text)...)
+}
+
+// Kill all characters on the current line, no matter where point is.
+func (rl *Shell) killWholeLine() {
+	rl.History.Save()
+
+	if rl.line.Len() == 0 {
+		return
+	}
+
+	rl.Buffers.Kill(false, *rl.line...)
+	rl.line.Cut(0, rl.line.Len())
+}
+
+// Copy all characters on the current line to the kill buffer, no matter where
+// point is, without modifying the line. In a multiline buffer, a numeric argument
+// copies that many lines starting from the current one (newlines included).
+func (rl *Shell) copyWholeLine() {
+	rl.History.SkipSave()
+
+	lines := strings.Split(string(*rl.line), "\n")
+	current := rl.cursor.LinePos()
+	count := max(rl.Iterations.Get(), 1)
+
+	if rl.line.Len() == 0 || current < 0 || current >= len(lines) {
+		return
+	}
+
+	copied := strings.Join(lines[current:min(current+count, len(lines))], "\n")
+	rl.Buffers.Write([]rune(copied)...)
+}
+
+// Kill the entire buffer.
+func (rl *Shell) killBuffer() {
+	rl.History.Save()
+
+	if rl.line.Len() == 0 {
+		return
+	}
+
+	rl.Buffers.Kill(false, *rl.line...)
+	rl.line.Cut(0, rl.line.Len())
+}
+
+// Kill backward from the cursor to the beginning of the buffer, across all
+// lines of a multiline buffer (backward-kill-line stops at the current one).
+func (rl *Shell) backwardKillBuffer() {
+	rl.Iterations.Reset()
+	rl.History.Save()
+
+	cpos := rl.cursor.Pos()
+	if cpos == 0 {
+		return
+	}
+
+	rl.selection.MarkRange(0, cpos)
+	rl.Buffers.Kill(true, []rune(rl.selection.Cut())...)
+	rl.cursor.Set(0)
+}
+
+// Kill from the cursor to the end of the buffer, across all lines
+// of a multiline buffer (kill-line stops at the end of the current one).
+func (rl *Shell) killBufferForward() {
+	rl.Iterations.Reset()
+	rl.History.Save()
+
+	cpos := rl.cursor.Pos()
+	if cpos >= rl.line.Len() {
+		return
+	}
+
+	rl.selection.MarkRange(cpos, rl.line.Len())
+	rl.Buffers.Kill(false, []rune(rl.selection.Cut())...)
+	rl.cursor.Set(cpos)
+}
+
+// Kill the current word from the cursor point up to the end of it.
+// Words are blank-delimited, unless the kill-word-punctuation option
+// is on: word boundaries are then the same as forward-word.
+func (rl *Shell) killWord() {
+	rl.History.Save()
+
+	bpos := rl.cursor.Pos()
+
+	if rl.Config.GetBool("kill-word-punctuation") {
+		rl.killWordPunctuation(bpos)
+		return
+	}
+
+	rl.cursor.ToFirstNonSpace(true)
+	forward := rl.line.Forward(rl.line.TokenizeSpace, rl.cursor.Pos())
+	rl.cursor.Move(forward - 1)
+	epos := rl.cursor.Pos()
+
+	rl.selection.MarkRange(bpos, epos)
+	rl.Buffers.Kill(false, []rune(rl.selection.Cut())...)
+	rl.cursor.Set(bpos)
+}
+
+// killWordPunctuation kills from bpos up to the end of the word it is in, or
+// if it is on blanks or punctuation, up to the end of the next word: words are
+// delimited by blanks and punctuation, like with forward-word.
+func (rl *Shell) killWordPunctuation(bpos int) {
+	if bpos >= rl.line.Len() {
+		return
+	}
+
+	separator := func(char rune) bool {
+		return unicode.IsPunct(char) || unicode.IsSpace(char)
+	}
+
+	epos := bpos
+
+	for epos < rl.line.Len() && separator((*rl.line)[epos]) {
+		epos++
+	}
+
+	for epos < rl.line.Len() && !separator((*rl.line)[epos]) {
+		epos++
+	}
+
+	rl.selection.MarkRange(bpos, epos)
+	rl.Buffers.Kill(false, []rune(rl.selection.Cut())...)
+	rl.cursor.Set(bpos)
+}
+
+// Kill the word behind point. Word boundaries
+// are the same as those used by backward-word.
+func (rl *Shell) backwardKillWord() {
+	rl.History.Save()
+	rl.History.SkipSave()
+
+	rl.selection.Mark(rl.cursor.Pos())
+	adjust := rl.line.Backward(rl.line.Tokenize, rl.cursor.Pos())
+	rl.cursor.Move(adjust)
+
+	rl.Buffers.Kill(true, []rune(rl.selection.Cut())...)
+}
+
+// Kill the text between the point and mark (saved cursor
+// position).  This text is referred to as the region.
+func (rl *Shell) killRegion() {
+	rl.History.Save()
+
+	if !rl.selection.Active() {
+		return
+	}
+
+	rl.Buffers.Kill(false, []rune(rl.selection.Cut())...)
+}
+
+// Copy the text in the region to the kill buffer.
+func (rl *Shell) copyRegionAsKill() {
+	rl.History.SkipSave()
+
+	if !rl.selection.Active() {
+		return
+	}
+
+	rl.Buffers.Write([]rune(rl.selection.Text())...)
+	rl.selection.Reset()
+}
+
+// Send the text of the active region to the selection sink of the shell (see
+// SetSelectionSink), leaving the line, the region and the kill ring unchanged.
+// Nothing is done if no region is active, or if no sink is set.
+func (rl *Shell) exportSelection() {
+	rl.History.SkipSave()
+
+	if !rl.selection.Active() || rl.selectionSink == nil {
+		return
+	}
+
+	rl.selectionSink(rl.selection.Text())
+}
+
+// Copy the word before point to the kill buffer.
+// The word boundaries are the same as backward-word.
+func (rl *Shell) copyBackwardWord() {
+	rl.History.Save()
+
+	rl.selection.Mark(rl.cursor.Pos())
+	adjust := rl.line.Backward(rl.line.Tokenize, rl.cursor.Pos())
+	rl.cursor.Move(adjust)
+
+	rl.Buffers.Write([]rune(rl.selection.Text())...)
+	rl.selection.Reset()
+}
+
+// Copy the word following point to the kill buffer.
+// The word boundaries are the same as forward-word.
+func (rl *Shell) copyForwardWord() {
+	rl.History.Save()
+
+	rl.selection.Mark(rl.cursor.Pos())
+	adjust := rl.line.Forward(rl.line.Tokenize, rl.cursor.Pos())
+	rl.cursor.Move(adjust + 1)
+
+	rl.Buffers.Write([]rune(rl.selection.Text())...)
+	rl.selection.Reset()
+}
+
+// Yank the top of the kill ring into the buffer at point.
 func (rl *Shell) yank() {
 	buf := rl.Buffers.Active()
+	start := rl.cursor.Pos()
 
 	vii := rl.Iterations.Get()
 
 	for i := 1; i <= vii; i++ {
 		rl.cursor.InsertAt(buf...)
 	}
+
+	rl.yanked = append([]rune{}, (*rl.line)[start:rl.cursor.Pos()]...)
+	rl.yankedEnd = rl.cursor.Pos()
 }
 
-// Rotate the kill ring, and yank the new top.
-// Only works following yank or yank-pop.
+// Rotate the kill ring, and replace the text just yanked with the new top.
+// Only works following yank or yank-pop. With a numeric argument, rotate
+// that many times, or the other way with a negative one (back to the most
+// recent entries): the ring wraps around in both directions.
 func (rl *Shell) yankPop() {
-	vii := rl.Iterations.Get()
+	start := rl.yankedEnd - len(rl.yanked)
 
-	for i := 1; i <= vii; i++ {
-		buf := rl.Buffers.Pop()
-		rl.cursor.InsertAt(buf...)
+	// Keys not dispatched to commands (eg. tabstop jumps) may have changed
+	// the line since the last command, so the yanked text must still be there.
+	if !rl.lastCommandWas("yank", "yank-pop") || len(rl.yanked) == 0 || rl.cursor.Pos() != rl.yankedEnd ||
+		start < 0 || rl.yankedEnd > rl.line.Len() || string((*rl.line)[start:rl.yankedEnd]) != string(rl.yanked) {
+		rl.History.SkipSave()
+		rl.Hint.SetTemporary(color.FgRed + "Previous command was not a yank")
+
+		return
+	}
+
+	buf := rl.Buffers.Rotate(rl.Iterations.Get())
+
+	rl.line.Cut(start, rl.yankedEnd)
+	rl.cursor.Set(start)
+	rl.cursor.InsertAt(buf...)
+
+	rl.yanked = append([]rune{}, buf...)
+	rl.yankedEnd = rl.cursor.Pos()
+}
+
+// Insert the contents of a register at point, as many times as the numeric argument.
+// The register is the one selected by a preceding vi-set-buffer ("x in vi mode), or
+// the key read after a hint asking for it. A linewise register (ending with a newline,
+// as those yanked by vi line commands) is inserted on new lines below the current one.
+// Nothing is inserted if the register is empty.
+func (rl *Shell) pasteRegister() {
+	if _, selected := rl.Buffers.IsSelected(); !selected {
+		done := rl.Keymap.PendingCursor()
+		rl.Hint.SetTemporary(color.Dim + "Register: " + color.Reset)
+		rl.Display.Refresh()
+
+		register, isAbort := rl.Keys.ReadKey()
+
+		rl.Hint.Reset()
+		done()
+
+		if isAbort {
+			rl.History.SkipSave()
+			return
+		}
+
+		rl.Buffers.SetActive(register)
+	}
+
+	buf := string(rl.Buffers.Active())
+	if buf == "" {
+		rl.History.SkipSave()
+		return
 	}
+
+	rl.History.Save()
+
+	times := max(rl.Iterations.Get(), 1)
+
+	if !strings.HasSuffix(buf, "\n") {
+		rl.cursor.InsertAt([]rune(strings.Repeat(buf, times))...)
+		return
+	}
+
+	// Linewise: the lines go after the current one,
+	// and the cursor at the beginning of the first.
+	rl.cursor.EndOfLineAppend()
+	pos := rl.cursor.Pos()
+
+	lines := strings.Repeat("\n"+strings.TrimSuffix(buf, "\n"), times)
+	rl.line.Insert(pos, []rune(lines)...)
+	rl.cursor.Set(pos + 1)
 }
 
 // Kill the shell word behind point. Word boundaries
@@ -1011,7 +2025,7 @@ func (rl *Shell) shellKillWord() {
 
 	_, epos := rl.selection.Pos()
 
-	rl.Buffers.Write([]rune((*rl.line)[startPos:epos])...)
+	rl.Buffers.Kill(false, []rune((*rl.line)[startPos:epos])...)
 	rl.line.Cut(startPos, epos)
 	rl.cursor.Set(startPos)
 
@@ -1042,7 +2056,7 @@ func (rl *Shell) shellBackwardKillWord() {
 	rl.cursor.ToFirstNonSpace(true)
 	bpos = rl.cursor.Pos()
 
-	rl.Buffers.Write([]rune((*rl.line)[bpos:startPos])...)
+	rl.Buffers.Kill(true, []rune((*rl.line)[bpos:startPos])...)
 	rl.line.Cut(bpos, startPos)
 	rl.selection.Reset()
 }
@@ -1071,6 +2085,18 @@ func (rl *Shell) copyPrevShellWord() {
 	rl.cursor.Move(len(word))
 }
 
+// Kill from the cursor up to and including the next occurrence of a character
+// read from the keyboard. With a numeric argument N, kill up to the Nth one, or
+// backward (from the previous occurrence) if N is negative.
+func (rl *Shell) zapToChar() {
+	rl.zapChar(false)
+}
+
+// Like zap-to-char, but the character read is not killed.
+func (rl *Shell) zapUpToChar() {
+	rl.zapChar(true)
+}
+
 //
 // Numeric Arguments -----------------------------------------------------------
 //
@@ -1089,6 +2115,14 @@ func (rl *Shell) digitArgument() {
 	rl.Iterations.Add(string(keys))
 }
 
+// Toggle a sticky numeric argument: while on, the current (or next) numeric
+// argument is kept for all commands, instead of being used by the next one only.
+// Typing another argument replaces it, and toggling off or aborting drops it.
+func (rl *Shell) stickyArgument() {
+	rl.History.SkipSave()
+	rl.Iterations.SetSticky(!rl.Iterations.IsSticky())
+}
+
 //
 // Macros ----------------------------------------------------------------------
 //
@@ -1124,11 +2158,21 @@ func (rl *Shell) printLastKeyboardMacro() {
 // If the command is about to start recording a macro, it will read an
 // additional argument key (must be a letter), to be used as the macro
 // "name", just like macro recording and use work in Vim.
+// When recording stops, the macro keys are also written to the register
+// of the same name, so that they can be pasted, edited and yanked back.
 // This command thus works "Vim-style", and should probably be used only
 // when using Vim editing mode.
 func (rl *Shell) macroToggleRecord() {
 	if rl.Macros.Recording() {
-		rl.Macros.StopRecord()
+		// Nothing recorded: the register keeps its contents.
+		if !rl.Macros.StopRecord() {
+			return
+		}
+
+		if key := rl.Macros.CurrentKey(); key != 0 {
+			macro := inputrc.Unescape(rl.Macros.Last())
+			rl.Buffers.WriteTo(key, []rune(macro)...)
+		}
 
 		return
 	}
@@ -1148,8 +2192,10 @@ func (rl *Shell) macroToggleRecord() {
 }
 
 // Reads a key from the keyboard, and runs the macro stored for this key identitier.
-// This mimics the Vim-style or running macros. If no macro is recorded for this key,
-// or if the key is invalid, nothing happens.
+// This mimics the Vim-style or running macros: the contents of the register with
+// this name are replayed, or the macro recorded under it if the register is empty.
+// If the key is '@', the last macro/register ran is replayed again.
+// If no macro is recorded for this key, or if the key is invalid, nothing happens.
 func (rl *Shell) macroRun() {
 	done := rl.Keymap.PendingCursor()
 	defer done()
@@ -1162,7 +2208,43 @@ func (rl *Shell) macroRun() {
 		return
 	}
 
-	rl.Macros.RunMacro(key)
+	if key == '@' {
+		key = rl.Macros.LastRun()
+	}
+
+	if key == 0 {
+		return
+	}
+
+	register := rl.Buffers.Get(key)
+	vii := rl.Iterations.Get()
+
+	for i := 0; i < vii; i++ {
+		if len(register) > 0 {
+			rl.Macros.RunKeys(key, register...)
+		} else {
+			rl.Macros.RunMacro(key)
+		}
+	}
+}
+
+// Reads a name from the keyboard (until Enter is pressed) and saves the last
+// recorded keyboard macro under this name, so that it can later be bound to a
+// key sequence through the shell BindMacro() method.
+func (rl *Shell) nameLastKeyboardMacro() {
+	if rl.Macros.Last() == "" {
+		rl.Hint.SetTemporary(color.FgRed + "No keyboard macro recorded")
+		return
+	}
+
+	name, ok := rl.readHintInput("Macro name: ")
+	if !ok {
+		return
+	}
+
+	if rl.Macros.SaveNamed(name) {
+		rl.Hint.SetTemporary(color.Dim + "Saved macro " + color.Reset + name)
+	}
 }
 
 //
@@ -1174,26 +2256,14 @@ func (rl *Shell) macroRun() {
 func (rl *Shell) reReadInitFile() {
 	main := rl.Keymap.Main()
 
-	err := rl.Keymap.ReloadConfig(rl.Opts...)
-	if err != nil {
-		rl.Hint.SetTemporary(color.FgRed + "Inputrc reload error: " + err.Error())
-		return
-	}
-
-	defer rl.Keymap.UpdateCursor()
+	rl.Keymap.ReloadConfig(rl.Opts...)
+	rl.updateKeymap(main)
 
-	// Reload keymap settings and cursor
-	newMain := rl.Keymap.Main()
-
-	if main != newMain {
-		switch newMain {
-		case keymap.Emacs, keymap.EmacsStandard, keymap.EmacsMeta, keymap.EmacsCtrlX:
-			rl.emacsEditingMode()
-		case keymap.Vi, keymap.ViCommand, keymap.ViMove:
-			rl.viCommandMode()
-		case keymap.ViInsert:
-			rl.viInsertMode()
-		}
+	// Errors do not prevent the rest of the file to be loaded,
+	// so only show the first one: users need to fix it anyway.
+	if errs := rl.Keymap.Errors(); len(errs) > 0 {
+		rl.Hint.SetTemporary(color.FgRed + fmt.Sprintf("Inputrc reloaded with %d errors: %s", len(errs), errs[0]))
+		return
 	}
 
 	// Notify successfully reloaded
@@ -1204,7 +2274,8 @@ func (rl *Shell) reReadInitFile() {
 // If one of the completion or non/incremental-search modes
 // are active, only cancel them and nothing else.
 func (rl *Shell) abort() {
-	// Reset any visual selection and iterations.
+	// Reset any visual selection and iterations, even sticky ones.
+	rl.Iterations.SetSticky(false)
 	rl.Iterations.Reset()
 	rl.selection.Reset()
 
@@ -1230,19 +2301,24 @@ func (rl *Shell) abort() {
 		return
 	}
 
-	if rl.Config.GetBool("echo-control-characters") {
-		key := rl.Keys.Caller()
-		if key[0] == rune(inputrc.Unescape(`\C-C`)[0]) {
-			quoted, _ := strutil.Quote(key[0])
-			fmt.Print(string(quoted))
-		}
-	}
+	rl.echoControlKey(rl.Keys.Caller()[0])
 
 	// If no line was active,
 	rl.Display.AcceptLine()
 	rl.History.Accept(false, false, ErrInterrupt)
 }
 
+// echoControlKey prints a control key in caret notation (eg. ^C), like terminals
+// do for keys producing no visible output, if echo-control-characters is on.
+func (rl *Shell) echoControlKey(key rune) {
+	if !rl.Config.GetBool("echo-control-characters") || !inputrc.IsControl(key) {
+		return
+	}
+
+	quoted, _ := strutil.Quote(key)
+	term.Print(string(quoted))
+}
+
 // If the metafied character x is uppercase, run the command
 // that is bound to the corresponding metafied lowercase character.
 // The behavior is undefined if x is already lowercase.
@@ -1389,8 +2465,10 @@ func (rl *Shell) characterSearchBackward() {
 // comment-begin makes the current line a shell comment.
 // If a numeric argument causes the comment character to be
 // removed, the line will be executed by the shell.
+// The comment prefix can also be set with SetCommentPrefix(),
+// or depend on the line with SetCommentPrefixFunc().
 func (rl *Shell) insertComment() {
-	comment := strings.Trim(rl.Config.GetString("comment-begin"), "\"")
+	comment := rl.commentPrefix()
 
 	switch {
 	case !rl.Iterations.IsSet():
@@ -1410,7 +2488,7 @@ func (rl *Shell) insertComment() {
 
 		rl.cursor.Set(cpos)
 
-		commentFits := epos < rl.line.Len()
+		commentFits := epos <= rl.line.Len()
 
 		if commentFits && string((*rl.line)[bpos:epos]) == comment {
 			rl.line.Cut(bpos, epos)
@@ -1425,13 +2503,25 @@ func (rl *Shell) insertComment() {
 	rl.acceptLineWith(false, false)
 }
 
+// commentPrefix returns the comment prefix for the current line: the one returned
+// by the function set with SetCommentPrefixFunc(), if any, or comment-begin.
+func (rl *Shell) commentPrefix() string {
+	if rl.commentPrefixFunc != nil {
+		if prefix := rl.commentPrefixFunc(*rl.line); prefix != "" {
+			return prefix
+		}
+	}
+
+	return strings.Trim(rl.Config.GetString("comment-begin"), "\"")
+}
+
 // Print all of the functions and their key bindings to the
 // readline output stream.  If a numeric argument is
 // supplied, the output is formatted in such a way that it
 // can be made part of an inputrc file.
 func (rl *Shell) dumpFunctions() {
 	rl.Display.ClearHelpers()
-	fmt.Println()
+	term.Println()
 
 	defer func() {
 		rl.Prompt.PrimaryPrint()
@@ -1448,7 +2538,7 @@ func (rl *Shell) dumpFunctions() {
 // can be made part of an inputrc file.
 func (rl *Shell) dumpVariables() {
 	rl.Display.ClearHelpers()
-	fmt.Println()
+	term.Println()
 
 	defer func() {
 		rl.Prompt.PrimaryPrint()
@@ -1468,12 +2558,12 @@ func (rl *Shell) dumpVariables() {
 	if rl.Iterations.IsSet() {
 		for _, variable := range variables {
 			value := rl.Config.Vars[variable]
-			fmt.Printf("set %s %v\n", variable, value)
+			term.Printf("set %s %v\n", variable, value)
 		}
 	} else {
 		for _, variable := range variables {
 			value := rl.Config.Vars[variable]
-			fmt.Printf("%s is set to `%v'\n", variable, value)
+			term.Printf("%s is set to `%v'\n", variable, value)
 		}
 	}
 }
@@ -1484,7 +2574,7 @@ func (rl *Shell) dumpVariables() {
 // can be made part of an inputrc file.
 func (rl *Shell) dumpMacros() {
 	rl.Display.ClearHelpers()
-	fmt.Println()
+	term.Println()
 
 	defer func() {
 		rl.Prompt.PrimaryPrint()
@@ -1510,12 +2600,12 @@ func (rl *Shell) dumpMacros() {
 	if rl.Iterations.IsSet() {
 		for _, key := range macroBinds {
 			action := inputrc.Escape(binds[inputrc.Unescape(key)].Action)
-			fmt.Printf("\"%s\": \"%s\"\n", key, action)
+			term.Printf("\"%s\": \"%s\"\n", key, action)
 		}
 	} else {
 		for _, key := range macroBinds {
 			action := inputrc.Escape(binds[inputrc.Unescape(key)].Action)
-			fmt.Printf("%s outputs %s\n", key, action)
+			term.Printf("%s outputs %s\n", key, action)
 		}
 	}
 }
@@ -1574,6 +2664,58 @@ func (rl *Shell) redo() {
 	rl.History.Redo()
 }
 
+// Select the entire buffer as the active region, with the cursor at the end.
+// With replace-selection-on-type, the next character typed replaces it.
+func (rl *Shell) selectAll() {
+	rl.History.SkipSave()
+	rl.selectLine()
+}
+
+// Extend the active region one character forward, or start one at the cursor,
+// as with Shift-Right in most text editors: the region is highlighted, and
+// spans from where it started up to the cursor. With replace-selection-on-type,
+// the next character typed replaces it.
+func (rl *Shell) selectForwardChar() {
+	rl.extendSelection(rl.forwardChar)
+}
+
+// Extend the active region one character backward, or start one at the cursor.
+func (rl *Shell) selectBackwardChar() {
+	rl.extendSelection(rl.backwardChar)
+}
+
+// Extend the active region to the next word, or start one at the cursor.
+func (rl *Shell) selectForwardWord() {
+	rl.extendSelection(rl.forwardWord)
+}
+
+// Extend the active region to the previous word, or start one at the cursor.
+func (rl *Shell) selectBackwardWord() {
+	rl.extendSelection(rl.backwardWord)
+}
+
+// Extend the active region one line up, or start one at the cursor.
+func (rl *Shell) selectUpLine() {
+	rl.extendSelection(rl.upLine)
+}
+
+// Extend the active region one line down, or start one at the cursor.
+func (rl *Shell) selectDownLine() {
+	rl.extendSelection(rl.downLine)
+}
+
+// Extend the active region to the beginning of the current line,
+// or start one at the cursor.
+func (rl *Shell) selectToBeginningOfLine() {
+	rl.extendSelection(rl.cursor.BeginningOfLine)
+}
+
+// Extend the active region to the end of the current line,
+// or start one at the cursor.
+func (rl *Shell) selectToEndOfLine() {
+	rl.extendSelection(rl.cursor.EndOfLineAppend)
+}
+
 // Considers the blank word under cursor, and tries a series of regular expressions on it
 // to match various patterns: URL and their various subcomponents (host/path/params, etc).
 //
@@ -1619,3 +2761,306 @@ func (rl *Shell) selectKeywordPrev() {
 	rl.cursor.Set(epos)
 	rl.selection.Visual(false)
 }
+
+// Prompt for a shell command in the hint section, run it and insert its
+// standard output at the cursor position, minus a single trailing newline.
+// If the command fails, its error output is shown and nothing is inserted.
+func (rl *Shell) insertCommandOutput() {
+	rl.History.Save()
+
+	command, ok := rl.readHintInput("Command: ")
+	if !ok || strings.TrimSpace(command) == "" {
+		return
+	}
+
+	output, err := editor.Output(command)
+	if err != nil {
+		errStr := strings.ReplaceAll(err.Error(), "\n", " ")
+		rl.Hint.SetTemporary(color.FgRed + errStr)
+
+		return
+	}
+
+	output = strings.TrimSuffix(output, "\n")
+	rl.cursor.InsertAt([]rune(output)...)
+}
+
+// Insert the current date and time at point, formatted with the Go time layout
+// of the datetime-format option (RFC3339 by default). With a numeric argument of
+// 2, only the date is inserted (2006-01-02), and with 3, only the time (15:04:05).
+func (rl *Shell) insertDatetime() {
+	rl.History.Save()
+
+	layout := strings.Trim(rl.Config.GetString("datetime-format"), "\"")
+
+	switch rl.Iterations.Get() {
+	case 2:
+		layout = time.DateOnly
+	case 3:
+		layout = time.TimeOnly
+	}
+
+	if layout == "" {
+		layout = time.RFC3339
+	}
+
+	rl.cursor.InsertAt([]rune(time.Now().Format(layout))...)
+}
+
+// Run the last command again, with the same numeric argument unless another one is
+// given, and reusing the keys it read (eg. the character searched by character-search).
+// As long as the last key of the sequence calling this is pressed again, the command
+// is repeated again, so that C-x z z z runs it three more times.
+func (rl *Shell) repeatLastCommand() {
+	last := rl.lastCommand
+	if last == nil {
+		return
+	}
+
+	if caller := rl.Keys.Caller(); len(caller) > 0 {
+		rl.repeatKey = caller[len(caller)-1]
+	}
+
+	// The repetition neither uses nor leaves a pending numeric argument.
+	if !rl.Iterations.IsSet() && !rl.Iterations.IsSticky() && last.times != "" {
+		rl.Iterations.Reset()
+		rl.Iterations.Add(last.times)
+		defer rl.Iterations.Reset()
+	}
+
+	rl.Keys.Feed(true, last.args...)
+	last.command()
+}
+
+//
+// Utils -------------------------------------------------------------------
+//
+
+// updateKeymap switches to the main keymap set by a newly loaded
+// configuration, if it is different from the previous one, and
+// updates the cursor style accordingly.
+func (rl *Shell) updateKeymap(previous keymap.Mode) {
+	defer rl.Keymap.UpdateCursor()
+
+	main := rl.Keymap.Main()
+	if main == previous {
+		return
+	}
+
+	switch main {
+	case keymap.Emacs, keymap.EmacsStandard, keymap.EmacsMeta, keymap.EmacsCtrlX:
+		rl.emacsEditingMode()
+	case keymap.Vi, keymap.ViCommand, keymap.ViMove:
+		rl.viCommandMode()
+	case keymap.ViInsert:
+		rl.viInsertMode()
+	}
+}
+
+// shellWordUnderCursor returns the active selection, or the shell word (including its
+// quoted parts) under the cursor, or just before it if the cursor is right after it.
+// Returns false if there is no such word, eg. if the cursor is on a blank space.
+// The selection, if any, is reset.
+func (rl *Shell) shellWordUnderCursor() (word string, bpos, epos int, found bool) {
+	if rl.selection.Active() {
+		word, bpos, epos, _ = rl.selection.Pop()
+		return word, bpos, epos, bpos != epos
+	}
+
+	pos := rl.cursor.Pos()
+	if pos > 0 && (pos == rl.line.Len() || unicode.IsSpace((*rl.line)[pos])) {
+		pos--
+	}
+
+	bpos, epos = strutil.WordBounds(*rl.line, pos)
+	if bpos == -1 {
+		return "", -1, -1, false
+	}
+
+	word = string((*rl.line)[bpos:epos])
+
+	return word, bpos, epos, word != ""
+}
+
+// gotoMatchingIndent moves the cursor to the first non-blank character of the
+// nth line (above if count is negative) with the same indentation as the current
+// one. Blank lines are skipped, and if there are less than n matching lines in
+// that direction, the cursor goes to the farthest one.
+func (rl *Shell) gotoMatchingIndent(count int) {
+	lines := strings.Split(string(*rl.line), "\n")
+	current := rl.cursor.LinePos()
+
+	if len(lines) < 2 || count == 0 || current < 0 || current >= len(lines) {
+		return
+	}
+
+	direction := 1
+	if count < 0 {
+		direction, count = -1, -count
+	}
+
+	indent := indentWidth(lines[current])
+	target := current
+
+	for i := current + direction; i >= 0 && i < len(lines) && count > 0; i += direction {
+		if strings.TrimSpace(lines[i]) == "" || indentWidth(lines[i]) != indent {
+			continue
+		}
+
+		target = i
+		count--
+	}
+
+	if target == current {
+		return
+	}
+
+	pos := 0
+	for _, line := range lines[:target] {
+		pos += len([]rune(line)) + 1
+	}
+
+	blank := len([]rune(lines[target])) - len([]rune(strings.TrimLeft(lines[target], " \t")))
+	rl.cursor.Set(pos + blank)
+}
+
+// indentWidth returns the width of the leading whitespace
+// of a line, with tabs expanded to the next multiple of 8.
+func indentWidth(line string) (width int) {
+	for _, char := range line {
+		switch char {
+		case ' ':
+			width++
+		case '\t':
+			width += 8 - width%8
+		default:
+			return width
+		}
+	}
+
+	return width
+}
+
+// selectLine selects the whole input line as a visual region,
+// and moves the cursor to the end of the line.
+func (rl *Shell) selectLine() {
+	rl.cursor.Set(rl.line.Len())
+	rl.selection.MarkRange(0, rl.line.Len())
+	rl.selection.Visual(false)
+}
+
+// extendSelection moves the cursor with move, and selects the text between it
+// and the end of the active region it was not on (its beginning if none was
+// active), excluding the character under the cursor if after this anchor.
+func (rl *Shell) extendSelection(move func()) {
+	rl.History.SkipSave()
+
+	anchor := rl.cursor.Pos()
+
+	if bpos, epos := rl.selection.Pos(); bpos != -1 {
+		anchor = bpos
+		if rl.cursor.Pos() <= bpos {
+			anchor = epos
+		}
+	}
+
+	move()
+
+	rl.selection.Reset()
+
+	if cpos := rl.cursor.Pos(); cpos != anchor {
+		rl.selection.MarkRange(min(cpos, anchor), max(cpos, anchor)-1)
+		rl.selection.Visual(false)
+	}
+}
+
+// zapChar reads a character and kills the text from the cursor up to its nth
+// occurrence (depending on the numeric argument and its sign), including it
+// unless upTo is true. Nothing is killed if the character is not found.
+func (rl *Shell) zapChar(upTo bool) {
+	done := rl.Keymap.PendingCursor()
+	defer done()
+
+	char, esc := rl.Keys.ReadKey()
+	if esc {
+		rl.History.SkipSave()
+		return
+	}
+
+	times := rl.Iterations.Get()
+	forward := times > 0
+
+	if !forward {
+		times = -times
+	}
+
+	target, found := rl.findChar(char, times, forward, upTo)
+	if !found {
+		rl.History.SkipSave()
+		return
+	}
+
+	rl.History.Save()
+
+	bpos, epos := rl.cursor.Pos(), target+1
+	if !forward {
+		bpos, epos = target, rl.cursor.Pos()
+	}
+
+	killed := string((*rl.line)[bpos:epos])
+	rl.line.Cut(bpos, epos)
+	rl.cursor.Set(bpos)
+
+	rl.Buffers.Kill(!forward, []rune(killed)...)
+}
+
+// keywordSwitchers returns the switchers used by the keyword-increase/decrease
+// commands: the user word rotations, if any, and then the builtin ones.
+func (rl *Shell) keywordSwitchers() []strutil.KeywordSwitcher {
+	switchers := strutil.KeywordSwitchers()
+
+	if len(rl.rotations) == 0 {
+		return switchers
+	}
+
+	return append([]strutil.KeywordSwitcher{strutil.RotationSwitcher(rl.rotations)}, switchers...)
+}
+
+// autoPairing returns true if brackets and quotes typed are automatically paired
+// with their closing counterpart, with the auto-pair option (or its former name,
+// autopairs): closing characters typed over are not inserted again, and deleting
+// an opening character also deletes its closing one, if immediately following.
+func (rl *Shell) autoPairing() bool {
+	return rl.Config.GetBool("auto-pair") || rl.Config.GetBool("autopairs")
+}
+
+// readHintInput reads a string typed by the user in the hint section, prefixed
+// with the given prompt, until the user presses Enter (ok is true) or aborts.
+func (rl *Shell) readHintInput(prompt string) (input string, ok bool) {
+	done := rl.Keymap.PendingCursor()
+	defer done()
+
+	var buf []rune
+
+	for {
+		rl.Hint.SetTemporary(color.Dim + prompt + color.Reset + string(buf))
+		rl.Display.Refresh()
+
+		key, isAbort := rl.Keys.ReadKey()
+
+		switch {
+		case isAbort:
+			rl.Hint.Reset()
+			return "", false
+		case key == inputrc.Return || key == inputrc.Newline:
+			rl.Hint.Reset()
+			return string(buf), true
+		case key == inputrc.Backspace || key == inputrc.Delete:
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+			}
+		case unicode.IsPrint(key):
+			buf = append(buf, key)
+		}
+	}
+}
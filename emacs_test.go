@@ -0,0 +1,1274 @@
+package readline
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexj212/readline/internal/keymap"
+)
+
+func TestShell_macroRegisters(t *testing.T) {
+	rl := newTestShell(t, keymap.ViCommand, "abcdefgh", 0)
+
+	// Record a single deletion in the a register.
+	runKeys(t, rl, "qa")
+	runKeys(t, rl, "x")
+	runKeys(t, rl, "q")
+
+	if got := string(rl.Buffers.Get('a')); got != "x" {
+		t.Fatalf("register a = %q, want %q", got, "x")
+	}
+
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{name: "Replay register with a count", keys: "2@a", want: "defgh"},
+		{name: "Replay last register", keys: "@@", want: "efgh"},
+		{name: "Replay last register with a count", keys: "3@@", want: "h"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			runKeys(t, rl, test.keys)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	// An empty recording doesn't overwrite a register with the last macro.
+	runKeys(t, rl, "qbq")
+
+	if got := string(rl.Buffers.Get('b')); got != "" {
+		t.Errorf("register b = %q after an empty recording, want it empty", got)
+	}
+
+	if got := string(rl.Buffers.Get('a')); got != "x" {
+		t.Errorf("register a = %q after an empty recording, want %q", got, "x")
+	}
+}
+
+func TestShell_nameLastKeyboardMacro(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Config.Bind(string(keymap.Emacs), "\x18n", "name-last-kbd-macro", false)
+
+	// Nothing to name yet.
+	runKeys(t, rl, "\x18n")
+
+	if hint := rl.Hint.Text(); !strings.Contains(hint, "No keyboard macro") {
+		t.Errorf("hint = %q, want no macro recorded", hint)
+	}
+
+	runKeys(t, rl, "\x18(ab\x18)")
+
+	// An aborted or empty name saves nothing.
+	runKeys(t, rl, "\x18nfoo\x07")
+	runKeys(t, rl, "\x18n\r")
+
+	if err := rl.BindMacro("foo", "emacs", `\C-xm`); !errors.Is(err, ErrNoMacro) {
+		t.Errorf("BindMacro() after an aborted name: error = %v, want %v", err, ErrNoMacro)
+	}
+
+	runKeys(t, rl, "\x18nfoo\r")
+
+	if err := rl.BindMacro("foo", "emacs", `\C-xm`); err != nil {
+		t.Fatalf("BindMacro() error = %v", err)
+	}
+
+	rl.line.Set()
+	rl.cursor.Set(0)
+	runKeys(t, rl, "\x18m")
+
+	if got := string(*rl.line); got != "ab" {
+		t.Errorf("line = %q after replaying the named macro, want %q", got, "ab")
+	}
+}
+
+func TestShell_unixLineDiscard(t *testing.T) {
+	tests := []struct {
+		name     string
+		keymap   keymap.Mode
+		cursor   int
+		wantLine string
+		wantRing string
+		wantYank string
+	}{
+		{name: "Emacs", keymap: keymap.Emacs, cursor: 6, wantLine: "world", wantRing: "hello ", wantYank: "worldhello "},
+		{name: "Emacs at end of line", keymap: keymap.Emacs, cursor: 11, wantLine: "", wantRing: "hello world", wantYank: "hello world"},
+		{name: "Vim insert", keymap: keymap.ViInsert, cursor: 6, wantLine: "world", wantRing: "hello ", wantYank: "worldhello "},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, test.keymap, "hello world", test.cursor)
+
+			runKeys(t, rl, "\x15")
+
+			if got := string(*rl.line); got != test.wantLine {
+				t.Fatalf("line after kill = %q, want %q", got, test.wantLine)
+			}
+
+			if got := string(rl.Buffers.Active()); got != test.wantRing {
+				t.Errorf("kill ring = %q, want %q", got, test.wantRing)
+			}
+
+			rl.cursor.Set(rl.line.Len())
+			runKeys(t, rl, "\x19")
+
+			if got := string(*rl.line); got != test.wantYank {
+				t.Errorf("line after yank = %q, want %q", got, test.wantYank)
+			}
+		})
+	}
+}
+
+func TestShell_killWordPunctuation(t *testing.T) {
+	tests := []struct {
+		name        string
+		punctuation bool
+		line        string
+		cursor      int
+		keys        string
+		want        string
+		wantKilled  string
+	}{
+		{name: "Blank-delimited by default", line: "foo.bar.baz qux", keys: "\x1bd", want: " qux", wantKilled: "foo.bar.baz"},
+		{name: "Stop at punctuation", punctuation: true, line: "foo.bar.baz qux", keys: "\x1bd", want: ".bar.baz qux", wantKilled: "foo"},
+		{name: "Punctuation and next word", punctuation: true, line: "foo.bar.baz", keys: "\x1bd\x1bd", want: ".baz", wantKilled: "foo.bar"},
+		{name: "Spaces and next word", punctuation: true, line: "foo  bar.baz", cursor: 3, keys: "\x1bd", want: "foo.baz", wantKilled: "  bar"},
+		{name: "Trailing spaces", punctuation: true, line: "foo   ", cursor: 3, keys: "\x1bd", want: "foo", wantKilled: "   "},
+		{name: "End of word before punctuation", punctuation: true, line: "foo.bar", cursor: 2, keys: "\x1bd", want: "fo.bar", wantKilled: "o"},
+		{name: "End of word before a space", punctuation: true, line: "foo bar", cursor: 2, keys: "\x1bd", want: "fo bar", wantKilled: "o"},
+		{name: "Single-letter word", punctuation: true, line: "a.b", cursor: 0, keys: "\x1bd", want: ".b", wantKilled: "a"},
+		{name: "Punctuation before a word", punctuation: true, line: "foo.bar", cursor: 3, keys: "\x1bd", want: "foo", wantKilled: ".bar"},
+		{name: "Backward kill word", line: "foo.bar", cursor: 7, keys: "\x17", want: "foo.", wantKilled: "bar"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+			rl.Config.Set("kill-word-punctuation", test.punctuation)
+
+			runKeys(t, rl, test.keys)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := string(rl.Buffers.GetKill()); got != test.wantKilled {
+				t.Errorf("killed = %q, want %q", got, test.wantKilled)
+			}
+		})
+	}
+}
+
+func TestShell_consecutiveKills(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		cursor   int
+		keys     string
+		want     string
+		wantRing []string
+	}{
+		{name: "Forward kills append", line: "one two three", keys: "\x1bd\x1bd", want: " three", wantRing: []string{"one two"}},
+		{name: "Backward kills prepend", line: "one two three", cursor: 13, keys: "\x17\x17", want: "one ", wantRing: []string{"two three"}},
+		{name: "Mixed directions", line: "one two three", cursor: 4, keys: "\x1bd\x17", want: " three", wantRing: []string{"one two"}},
+		{name: "Numeric argument", line: "one two three", keys: "\x1bd\x1b2\x1bd", want: " three", wantRing: []string{"one two"}},
+		{name: "Broken by another command", line: "one two three", keys: "\x1bd\x06\x1bd", want: "  three", wantRing: []string{"two", "one"}},
+		{name: "Kill line after kill word", line: "one two three", keys: "\x1bd\x0b", want: "", wantRing: []string{"one two three"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+
+			runKeys(t, rl, test.keys)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.KillRing(); strings.Join(got, "|") != strings.Join(test.wantRing, "|") {
+				t.Errorf("KillRing() = %q, want %q", got, test.wantRing)
+			}
+		})
+	}
+
+	// The whole run of kills is yanked at once.
+	rl := newTestShell(t, keymap.Emacs, "one two three", 0)
+
+	runKeys(t, rl, "\x1bd\x1bd\x05 \x19")
+
+	if got := string(*rl.line); got != " three one two" {
+		t.Errorf("line = %q, want %q", got, " three one two")
+	}
+}
+
+func TestShell_killBufferParts(t *testing.T) {
+	tests := []struct {
+		name       string
+		widget     string
+		want       string
+		wantKilled string
+		wantCursor int
+	}{
+		{name: "Backward", widget: "backward-kill-buffer", want: "o\nthree", wantKilled: "one\ntw", wantCursor: 0},
+		{name: "Forward", widget: "kill-buffer-forward", want: "one\ntw", wantKilled: "o\nthree", wantCursor: 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "one\ntwo\nthree", 6)
+			rl.Config.Bind(string(keymap.Emacs), "\x18k", test.widget, false)
+
+			runKeys(t, rl, "\x18k")
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+
+			if got := string(rl.Buffers.GetKill()); got != test.wantKilled {
+				t.Errorf("killed = %q, want %q", got, test.wantKilled)
+			}
+
+			runKeys(t, rl, "\x1f")
+
+			if got := string(*rl.line); got != "one\ntwo\nthree" {
+				t.Errorf("line after undo = %q, want the buffer restored", got)
+			}
+		})
+	}
+}
+
+func TestShell_yankPop(t *testing.T) {
+	tests := []struct {
+		name       string
+		size       int
+		kills      []string
+		input      string
+		want       string
+		wantCursor int
+		wantHint   bool
+	}{
+		{name: "Yank", size: 60, kills: []string{"one", "two", "three"}, input: "\x19", want: "x three", wantCursor: 7},
+		{name: "Rotate", size: 60, kills: []string{"one", "two", "three"}, input: "\x19\x1by", want: "x two", wantCursor: 5},
+		{name: "Wrap around", size: 60, kills: []string{"one", "two", "three"}, input: "\x19\x1by\x1by\x1by", want: "x three", wantCursor: 7},
+		{name: "Oldest evicted", size: 2, kills: []string{"one", "two", "three"}, input: "\x19\x1by\x1by", want: "x three", wantCursor: 7},
+		{name: "Single slot", size: 0, kills: []string{"one", "two", "three"}, input: "\x19\x1by", want: "x three", wantCursor: 7},
+		{name: "Not after a yank", size: 60, kills: []string{"one", "two"}, input: "\x19\x02\x1by", want: "x two", wantCursor: 4, wantHint: true},
+		{name: "Without a yank", size: 60, kills: []string{"one", "two"}, input: "\x1by", want: "x ", wantCursor: 2, wantHint: true},
+		{name: "Rotate back", size: 60, kills: []string{"one", "two", "three"}, input: "\x19\x1b-\x1by", want: "x one", wantCursor: 5},
+		{name: "Rotate back past the end", size: 60, kills: []string{"one", "two", "three"}, input: "\x19\x1b-\x1by\x1b-\x1by\x1b-\x1by", want: "x three", wantCursor: 7},
+		{name: "Rotate both ways", size: 60, kills: []string{"one", "two", "three"}, input: "\x19\x1by\x1by\x1b-\x1by", want: "x two", wantCursor: 5},
+		{name: "Rotate count past the end", size: 60, kills: []string{"one", "two", "three"}, input: "\x19\x1b4\x1by", want: "x two", wantCursor: 5},
+		{name: "Rotate negative count past the end", size: 60, kills: []string{"one", "two", "three"}, input: "\x19\x1b-\x1b4\x1by", want: "x one", wantCursor: 5},
+		{name: "After a repeated yank", size: 60, kills: []string{"one", "two", "three"}, input: "\x19\x18z\x1by", want: "x threetwo", wantCursor: 10},
+		{name: "After a repeated other command", size: 60, kills: []string{"one", "two"}, input: "\x19\x02\x18z\x1by", want: "x two", wantCursor: 3, wantHint: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "x ", 2)
+			rl.SetKillRingSize(test.size)
+
+			for _, kill := range test.kills {
+				rl.Buffers.Write([]rune(kill)...)
+			}
+
+			if ring := rl.KillRing(); len(ring) != max(min(test.size, len(test.kills)), 1) {
+				t.Errorf("KillRing() = %q, want %d entries", ring, max(min(test.size, len(test.kills)), 1))
+			}
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+
+			if hint := rl.Hint.Text(); strings.Contains(hint, "not a yank") != test.wantHint {
+				t.Errorf("hint = %q, want a hint: %v", hint, test.wantHint)
+			}
+		})
+	}
+
+	// The line or the cursor changed since the yank, without running a command.
+	edits := map[string]func(rl *Shell){
+		"Line changed": func(rl *Shell) { rl.line.Set([]rune("x thrxx")...) },
+		"Cursor moved": func(rl *Shell) { rl.cursor.Set(2) },
+	}
+
+	for name, edit := range edits {
+		rl := newTestShell(t, keymap.Emacs, "x ", 2)
+		rl.Buffers.Write([]rune("one")...)
+		rl.Buffers.Write([]rune("three")...)
+
+		runKeys(t, rl, "\x19")
+		edit(rl)
+
+		line := string(*rl.line)
+		runKeys(t, rl, "\x1by")
+
+		if got := string(*rl.line); got != line || !strings.Contains(rl.Hint.Text(), "not a yank") {
+			t.Errorf("%s: line = %q, want %q unchanged with a hint", name, got, line)
+		}
+	}
+}
+
+func TestShell_pasteRegister(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       keymap.Mode
+		register   string
+		input      string
+		want       string
+		wantCursor int
+	}{
+		{name: "Charwise", mode: keymap.Emacs, register: "foo", input: "\x18pa", want: "onefoo\ntwo", wantCursor: 6},
+		{name: "Charwise with count", mode: keymap.Emacs, register: "foo", input: "\x1b3\x18pa", want: "onefoofoofoo\ntwo", wantCursor: 12},
+		{name: "Linewise with count", mode: keymap.Emacs, register: "line\n", input: "\x1b2\x18pa", want: "one\nline\nline\ntwo", wantCursor: 4},
+		{name: "Empty register", mode: keymap.Emacs, register: "foo", input: "\x18pb", want: "one\ntwo", wantCursor: 3},
+		{name: "Vi charwise with count", mode: keymap.ViCommand, register: "foo", input: "\"a3\x18p", want: "onfoofoofooe\ntwo", wantCursor: 11},
+		{name: "Vi linewise", mode: keymap.ViCommand, register: "line\n", input: "\"a\x18p", want: "one\nline\ntwo", wantCursor: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, test.mode, "one\ntwo", 3)
+			rl.Config.Bind(string(test.mode), "\x18p", "paste-register", false)
+			rl.Buffers.WriteTo('a', []rune(test.register)...)
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+}
+
+func TestShell_insertCommandOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantLine string
+		wantHint bool
+	}{
+		{name: "Insert output", input: "echo world\r", wantLine: "hello world!"},
+		{name: "Trim a single newline", input: "printf 'a\\n\\n'\r", wantLine: "hello a\n!"},
+		{name: "Failing command", input: "echo oops >&2; exit 1\r", wantLine: "hello !", wantHint: true},
+		{name: "Aborted", input: "echo world\x1b", wantLine: "hello !"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "hello !", 6)
+			rl.Config.Bind(string(keymap.Emacs), "\x18!", "insert-command-output", false)
+
+			runKeys(t, rl, "\x18!"+test.input)
+
+			if got := string(*rl.line); got != test.wantLine {
+				t.Errorf("line = %q, want %q", got, test.wantLine)
+			}
+
+			if hint := rl.Hint.Text(); strings.Contains(hint, "oops") != test.wantHint {
+				t.Errorf("hint = %q, want error output: %v", hint, test.wantHint)
+			}
+
+			runKeys(t, rl, "\x1f")
+
+			if got := string(*rl.line); got != "hello !" {
+				t.Errorf("line after undo = %q, want %q", got, "hello !")
+			}
+		})
+	}
+}
+
+func TestShell_cycleQuoting(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		cursor int
+		want   []string
+	}{
+		{
+			name:   "Word with embedded quotes and spaces",
+			line:   `echo "a b's"`,
+			cursor: 12,
+			want:   []string{`echo a\ b\'s`, `echo 'a b'\''s'`, `echo "a b's"`},
+		},
+		{
+			name:   "Plain word",
+			line:   "echo foo bar",
+			cursor: 6,
+			want:   []string{"echo 'foo' bar", `echo "foo" bar`, "echo foo bar"},
+		},
+		{
+			name:   "Double quotes and dollar",
+			line:   `echo '"$HOME"'`,
+			cursor: 7,
+			want:   []string{`echo "\"\$HOME\""`, `echo \"\$HOME\"`, `echo '"$HOME"'`},
+		},
+		{
+			name:   "No word at cursor",
+			line:   "echo  foo",
+			cursor: 5,
+			want:   []string{"echo  foo"},
+		},
+		{
+			name:   "Unterminated quote",
+			line:   "echo it's",
+			cursor: 9,
+			want:   []string{"echo it's"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+			rl.Config.Bind(string(keymap.Emacs), "\x18q", "cycle-quoting", false)
+
+			for i, want := range test.want {
+				runKeys(t, rl, "\x18q")
+
+				if got := string(*rl.line); got != want {
+					t.Fatalf("line after %d cycles = %q, want %q", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestShell_dequoteWord(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		cursor int
+		want   string
+	}{
+		{name: "Single quotes", line: "echo 'a b' c", cursor: 6, want: "echo a b c"},
+		{name: "Mixed quoting", line: `cat "a b"'c'\ d`, cursor: 15, want: "cat a bc d"},
+		{name: "Escapes in double quotes", line: `echo "\$x \"y\""`, cursor: 7, want: `echo $x "y"`},
+		{name: "No quotes", line: "echo foo", cursor: 6, want: "echo foo"},
+		{name: "Unterminated quote", line: "echo 'foo", cursor: 9, want: "echo 'foo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+			rl.Config.Bind(string(keymap.Emacs), "\x18d", "dequote-word", false)
+
+			runKeys(t, rl, "\x18d")
+
+			if got := string(*rl.line); got != test.want {
+				t.Fatalf("line = %q, want %q", got, test.want)
+			}
+
+			// Undo restores the quoted word.
+			runKeys(t, rl, "\x1f")
+
+			if got := string(*rl.line); got != test.line {
+				t.Errorf("line after undo = %q, want %q", got, test.line)
+			}
+		})
+	}
+}
+
+func TestShell_autoPair(t *testing.T) {
+	tests := []struct {
+		name       string
+		keymap     keymap.Mode
+		input      string
+		want       string
+		wantCursor int
+	}{
+		{name: "Insert bracket pair", keymap: keymap.Emacs, input: "f(", want: "f()", wantCursor: 2},
+		{name: "Insert nested pairs", keymap: keymap.Emacs, input: "{[", want: "{[]}", wantCursor: 2},
+		{name: "Insert quote pair", keymap: keymap.Emacs, input: `echo "`, want: `echo ""`, wantCursor: 6},
+		{name: "Type over closing bracket", keymap: keymap.Emacs, input: "f(x)", want: "f(x)", wantCursor: 4},
+		{name: "Type over closing quote", keymap: keymap.Emacs, input: `"a"`, want: `"a"`, wantCursor: 3},
+		{name: "Delete empty pair", keymap: keymap.Emacs, input: "f(\x7f", want: "f", wantCursor: 1},
+		{name: "Delete in non-empty pair", keymap: keymap.Emacs, input: "f(x\x7f\x7f", want: "f", wantCursor: 1},
+		{name: "Vim insert mode", keymap: keymap.ViInsert, input: "f(x)", want: "f(x)", wantCursor: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, test.keymap, "", 0)
+			rl.Config.Set("auto-pair", true)
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+}
+
+func TestShell_autoPairWrap(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		wrap       bool
+		want       string
+		wantCursor int
+	}{
+		{name: "Wrap in brackets", key: "(", wrap: true, want: "echo (foo) bar", wantCursor: 10},
+		{name: "Wrap with closing bracket", key: "]", wrap: true, want: "echo [foo] bar", wantCursor: 10},
+		{name: "Wrap in quotes", key: `"`, wrap: true, want: `echo "foo" bar`, wantCursor: 10},
+		{name: "Not a pair", key: "x", wrap: true, want: "echo foox bar", wantCursor: 9},
+		{name: "Wrapping disabled", key: "(", wrap: false, want: "echo foo() bar", wantCursor: 9},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "echo foo bar", 8)
+			rl.Config.Set("auto-pair", true)
+			rl.Config.Set("auto-pair-wrap", test.wrap)
+
+			rl.selection.MarkRange(5, 7)
+			rl.selection.Visual(false)
+
+			runKeys(t, rl, test.key)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+}
+
+func TestShell_gotoMatchingIndent(t *testing.T) {
+	line := "if true {\n  foo\n    bar\n\n  baz\n\tqux\n  end\n}"
+
+	tests := []struct {
+		name       string
+		cursor     int
+		input      string
+		wantCursor int
+	}{
+		{name: "Down to same indent", cursor: 12, input: "\x18j", wantCursor: 27},
+		{name: "Down skips deeper and blank lines", cursor: 12, input: "\x1b2\x18j", wantCursor: 38},
+		{name: "Down with numeric argument clamped", cursor: 12, input: "\x1b9\x18j", wantCursor: 38},
+		{name: "Up to same indent", cursor: 38, input: "\x18k", wantCursor: 27},
+		{name: "Up with numeric argument", cursor: 38, input: "\x1b2\x18k", wantCursor: 12},
+		{name: "Top level", cursor: 0, input: "\x18j", wantCursor: 42},
+		{name: "No matching line", cursor: 20, input: "\x18j", wantCursor: 20},
+		{name: "Top of buffer", cursor: 0, input: "\x18k", wantCursor: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, line, test.cursor)
+			rl.Config.Bind(string(keymap.Emacs), "\x18j", "goto-matching-indent-down", false)
+			rl.Config.Bind(string(keymap.Emacs), "\x18k", "goto-matching-indent-up", false)
+
+			runKeys(t, rl, test.input)
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+
+			if got := string(*rl.line); got != line {
+				t.Errorf("line = %q, want unchanged %q", got, line)
+			}
+		})
+	}
+}
+
+func TestShell_gotoPosition(t *testing.T) {
+	line := "echo one\necho two\necho three"
+
+	tests := []struct {
+		name       string
+		widget     string
+		cursor     int
+		input      string
+		wantCursor int
+	}{
+		{name: "Position", widget: "goto-position", cursor: 0, input: "\x1b7\x18g", wantCursor: 7},
+		{name: "Position without argument", widget: "goto-position", cursor: 12, input: "\x18g", wantCursor: 0},
+		{name: "Position beyond buffer", widget: "goto-position", cursor: 3, input: "\x1b9\x1b9\x18g", wantCursor: len(line)},
+		{name: "Negative position", widget: "goto-position", cursor: 12, input: "\x1b-\x1b3\x18g", wantCursor: 0},
+		{name: "Line", widget: "goto-line", cursor: 0, input: "\x1b2\x18g", wantCursor: 9},
+		{name: "Last line", widget: "goto-line", cursor: 0, input: "\x1b3\x18g", wantCursor: 18},
+		{name: "Line without argument", widget: "goto-line", cursor: 20, input: "\x18g", wantCursor: 0},
+		{name: "Line beyond buffer", widget: "goto-line", cursor: 0, input: "\x1b9\x18g", wantCursor: 18},
+		{name: "Negative line", widget: "goto-line", cursor: 20, input: "\x1b-\x1b2\x18g", wantCursor: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, line, test.cursor)
+			rl.Config.Bind(string(keymap.Emacs), "\x18g", test.widget, false)
+
+			runKeys(t, rl, test.input)
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+
+			if got := string(*rl.line); got != line {
+				t.Errorf("line = %q, want unchanged %q", got, line)
+			}
+		})
+	}
+}
+
+func TestShell_copyWholeLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		cursor     int
+		input      string
+		wantCopied string
+	}{
+		{name: "Single line", line: "echo hello", cursor: 3, input: "\x18c", wantCopied: "echo hello"},
+		{name: "Current line", line: "one\ntwo\nthree", cursor: 5, input: "\x18c", wantCopied: "two"},
+		{name: "Cursor at end of line", line: "one\ntwo\nthree", cursor: 3, input: "\x18c", wantCopied: "one"},
+		{name: "Several lines", line: "one\ntwo\nthree", cursor: 5, input: "\x1b2\x18c", wantCopied: "two\nthree"},
+		{name: "Lines beyond buffer", line: "one\ntwo\nthree", cursor: 0, input: "\x1b9\x18c", wantCopied: "one\ntwo\nthree"},
+		{name: "Empty line", line: "", cursor: 0, input: "\x18c", wantCopied: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+			rl.Config.Bind(string(keymap.Emacs), "\x18c", "copy-whole-line", false)
+
+			runKeys(t, rl, test.input)
+
+			if got := string(rl.Buffers.GetKill()); got != test.wantCopied {
+				t.Errorf("copied = %q, want %q", got, test.wantCopied)
+			}
+
+			if got := string(*rl.line); got != test.line || rl.cursor.Pos() != test.cursor {
+				t.Errorf("line = %q (cursor %d), want unchanged", got, rl.cursor.Pos())
+			}
+		})
+	}
+}
+
+func TestShell_exportSelection(t *testing.T) {
+	tests := []struct {
+		name   string
+		mark   int
+		cursor int
+		want   []string
+	}{
+		{name: "Selected text", mark: 5, cursor: 10, want: []string{"hello"}},
+		{name: "Mark after cursor", mark: 10, cursor: 5, want: []string{"hello"}},
+		{name: "No selection", mark: -1, cursor: 5, want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "echo hello world", test.cursor)
+			rl.Config.Bind(string(keymap.Emacs), "\x18e", "export-selection", false)
+			rl.Buffers.Write([]rune("killed")...)
+
+			var got []string
+
+			rl.SetSelectionSink(func(text string) { got = append(got, text) })
+
+			if test.mark != -1 {
+				rl.selection.Mark(test.mark)
+			}
+
+			runKeys(t, rl, "\x18e")
+
+			if strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Errorf("sink received %q, want %q", got, test.want)
+			}
+
+			if line := string(*rl.line); line != "echo hello world" || rl.cursor.Pos() != test.cursor {
+				t.Errorf("line = %q (cursor %d), want unchanged", line, rl.cursor.Pos())
+			}
+
+			if ring := rl.KillRing(); len(ring) != 1 || ring[0] != "killed" {
+				t.Errorf("KillRing() = %q, want unchanged", ring)
+			}
+		})
+	}
+
+	// No sink, nothing done.
+	rl := newTestShell(t, keymap.Emacs, "echo hello", 4)
+	rl.Config.Bind(string(keymap.Emacs), "\x18e", "export-selection", false)
+	rl.selection.Mark(0)
+
+	runKeys(t, rl, "\x18e")
+
+	if line := string(*rl.line); line != "echo hello" || !rl.selection.Active() {
+		t.Errorf("line = %q, want unchanged with the region kept", line)
+	}
+}
+
+func TestShell_expandMultiline(t *testing.T) {
+	runWidgetTests(t, "expand-multiline", []widgetTest{
+		{name: "Join lines", line: "one\ntwo\nthree", cursor: 5, want: "one; two; three", wantCursor: 6},
+		{name: "Split line", line: "one; two; three", cursor: 6, want: "one\ntwo\nthree", wantCursor: 5},
+		{name: "Custom separator", options: map[string]interface{}{"multiline-join-separator": "\" && \""}, line: "one\ntwo", cursor: 7, want: "one && two", wantCursor: 10},
+		{name: "Empty separator", options: map[string]interface{}{"multiline-join-separator": "\"\""}, line: "a\nb", cursor: 3, want: "a; b", wantCursor: 4},
+		{name: "Separator in a line", line: "a; b\nc", cursor: 6, want: "a; b; c", wantCursor: 7},
+		{name: "Trailing newline", line: "one\n", cursor: 4, want: "one; ", wantCursor: 5},
+		{name: "Nothing to expand", line: "echo hello", cursor: 3, want: "echo hello", wantCursor: 3},
+	})
+}
+
+func TestShell_replaceInLine(t *testing.T) {
+	runWidgetTests(t, "replace-in-line", []widgetTest{
+		{name: "Two prompts", line: "cat foo foo.txt", cursor: 15, input: "\x18wfoo\rbar\r", want: "cat bar bar.txt", wantCursor: 15},
+		{name: "Substitution", line: "cat foo foo.txt", cursor: 0, input: "\x18ws/foo/ba/\r", want: "cat ba ba.txt", wantCursor: 0},
+		{name: "Cursor after matches", line: "a-b-c", cursor: 4, input: "\x18ws/-/ - /\r", want: "a - b - c", wantCursor: 8},
+		{name: "Cursor in a match", line: "echo hello", cursor: 7, input: "\x18ws/hello/bye/\r", want: "echo bye", wantCursor: 8},
+		{name: "Ignore case", line: "Foo foo FOO", cursor: 0, input: "\x18ws/foo/x/i\r", want: "x x x", wantCursor: 0},
+		{name: "Case sensitive", line: "Foo foo FOO", cursor: 0, input: "\x18ws/foo/x/\r", want: "Foo x FOO", wantCursor: 0},
+		{name: "Escaped slash", line: "/usr/bin", cursor: 0, input: "\x18ws/\\/usr/\\/opt/\r", want: "/opt/bin", wantCursor: 0},
+		{name: "Delete occurrences", line: "a, b, c", cursor: 0, input: "\x18ws/, //\r", want: "abc", wantCursor: 0},
+		{name: "Empty replacement prompt", line: "a-b", cursor: 3, input: "\x18w-\r\r", want: "ab", wantCursor: 2},
+		{name: "Unknown flag searched literally", line: "s/a/b/g x", cursor: 0, input: "\x18ws/a/b/g\rX\r", want: "X x", wantCursor: 0},
+		{name: "Wide characters", line: "été été", cursor: 7, input: "\x18ws/é/ei/\r", want: "eitei eitei", wantCursor: 11},
+		{name: "No match", line: "echo hello", cursor: 3, input: "\x18ws/bye/hello/\r", want: "echo hello", wantCursor: 3},
+		{name: "Empty search", line: "echo hello", cursor: 3, input: "\x18w\r", want: "echo hello", wantCursor: 3},
+		{name: "Aborted", line: "echo hello", cursor: 3, input: "\x18whello\x07", want: "echo hello", wantCursor: 3},
+	})
+}
+
+func TestShell_queryReplace(t *testing.T) {
+	runWidgetTests(t, "query-replace", []widgetTest{
+		{name: "Confirm all", line: "a.b.c", cursor: 0, input: "\x18ws/./-/\ryy", want: "a-b-c", wantCursor: 4},
+		{name: "Skip one", line: "a.b.c", cursor: 0, input: "\x18ws/./-/\rny", want: "a.b-c", wantCursor: 4},
+		{name: "Replace all remaining", line: "a.b.c.d", cursor: 0, input: "\x18ws/./-/\rn!", want: "a.b-c-d", wantCursor: 6},
+		{name: "Quit", line: "a.b.c", cursor: 0, input: "\x18ws/./-/\ryq", want: "a-b.c", wantCursor: 3},
+		{name: "Enter quits", line: "a.b.c", cursor: 0, input: "\x18ws/./-/\ry\r", want: "a-b.c", wantCursor: 3},
+		{name: "Abort keeps replacements", line: "a.b.c", cursor: 0, input: "\x18ws/./-/\ry\x1b", want: "a-b.c", wantCursor: 3},
+		{name: "From the cursor", line: "a.b.c", cursor: 2, input: "\x18ws/./-/\ry", want: "a.b-c", wantCursor: 4},
+		{name: "No match after the cursor", line: "a.b", cursor: 2, input: "\x18ws/./-/\r", want: "a.b", wantCursor: 2},
+		{name: "Overlapping matches", line: "aaaa", cursor: 0, input: "\x18ws/aa/b/\rny", want: "aba", wantCursor: 2},
+		{name: "Replacement containing the search", line: "foo foo", cursor: 0, input: "\x18ws/foo/foofoo/\r!", want: "foofoo foofoo", wantCursor: 13},
+		{name: "Empty replacement", line: "a.b.c", cursor: 0, input: "\x18ws/.//\ryy", want: "abc", wantCursor: 2},
+		{name: "Ignore case", line: "Foo foo", cursor: 0, input: "\x18ws/foo/x/i\ryy", want: "x x", wantCursor: 3},
+		{name: "Unknown keys ignored", line: "a.b", cursor: 0, input: "\x18ws/./-/\rxzy", want: "a-b", wantCursor: 2},
+		{name: "Two prompts", line: "a.b", cursor: 0, input: "\x18w.\r+\ry", want: "a+b", wantCursor: 2},
+	})
+}
+
+func TestShell_swapCaseRegion(t *testing.T) {
+	runWidgetTests(t, "swap-case-region", []widgetTest{
+		{name: "Mixed case", line: "Hello World 42!", setup: markAt(0), cursor: 15, want: "hELLO wORLD 42!", wantCursor: 15},
+		{name: "Only in region", line: "echo ABC def", setup: markAt(5), cursor: 8, want: "echo abc def", wantCursor: 8},
+		{name: "Reversed region", line: "echo ABC def", setup: markAt(8), cursor: 5, want: "echo abc def", wantCursor: 5},
+		{name: "Several lines", line: "ab\ncd", setup: markAt(1), cursor: 4, want: "aB\nCd", wantCursor: 4},
+		{name: "Unicode letters", line: "Ça Été", setup: markAt(0), cursor: 6, want: "çA éTÉ", wantCursor: 6},
+		{name: "Letter without a single-rune case", line: "Straße", setup: markAt(0), cursor: 6, want: "sTRAßE", wantCursor: 6},
+		{name: "Empty region", line: "echo ABC", setup: markAt(5), cursor: 5, want: "echo ABC", wantCursor: 5},
+		{name: "No region", line: "echo ABC", cursor: 8, want: "echo ABC", wantCursor: 8},
+	})
+}
+
+func TestShell_trimRegion(t *testing.T) {
+	runWidgetTests(t, "trim-region", []widgetTest{
+		{name: "Multiple spaces", line: "echo  a   b", setup: markAt(0), cursor: 11, want: "echo a b", wantCursor: 8},
+		{name: "Tabs", line: "echo\ta\t\tb", setup: markAt(0), cursor: 9, want: "echo a b", wantCursor: 8},
+		{name: "Leading and trailing blanks", line: "x   foo  bar   y", setup: markAt(1), cursor: 15, want: "xfoo bary", wantCursor: 8},
+		{name: "Only in region", line: "a  b  c  d", setup: markAt(3), cursor: 7, want: "a  b c  d", wantCursor: 6},
+		{name: "Each line of the region", line: "  one  \n\ttwo   three ", setup: markAt(21), cursor: 0, want: "one\ntwo three", wantCursor: 0},
+		{name: "Blank region", line: "a   b", setup: markAt(1), cursor: 4, want: "ab", wantCursor: 1},
+		{name: "Blank lines kept", line: "a\n\n  \nb", cursor: 7, want: "a\n\n\nb", wantCursor: 5},
+		{name: "Whole line without region", line: "  ls   -l ", cursor: 10, want: "ls -l", wantCursor: 5},
+		{name: "Empty line", line: "", cursor: 0, want: "", wantCursor: 0},
+	})
+}
+
+func TestShell_numberLines(t *testing.T) {
+	runWidgetTests(t, "number-lines", []widgetTest{
+		{name: "Whole buffer", line: "one\ntwo\nthree", cursor: 5, want: "1. one\n2. two\n3. three", wantCursor: 11},
+		{name: "Region lines", line: "one\ntwo\nthree\nfour", setup: markAt(5), cursor: 9, want: "one\n1. two\n2. three\nfour", wantCursor: 15},
+		{name: "Starting number", line: "a\nb", cursor: 0, input: "\x1b9\x18w", want: "9. a\n10. b", wantCursor: 3},
+		{name: "Negative starting number", line: "a\nb", cursor: 3, input: "\x1b-\x1b1\x18w", want: "-1. a\n0. b", wantCursor: 10},
+		{name: "Blank lines", line: "a\n\nb", cursor: 0, want: "1. a\n2. \n3. b", wantCursor: 3},
+		{name: "Bullets", line: "a\nb\nc", cursor: 5, options: map[string]interface{}{"number-lines-format": "- "}, want: "- a\n- b\n- c", wantCursor: 11},
+		{name: "Custom format", line: "a\nb", cursor: 3, options: map[string]interface{}{"number-lines-format": "(%d) "}, want: "(1) a\n(2) b", wantCursor: 11},
+		{name: "Empty format", line: "a\nb", cursor: 3, options: map[string]interface{}{"number-lines-format": "\"\""}, want: "a\nb", wantCursor: 3},
+		{name: "Single line", line: "todo", cursor: 4, want: "1. todo", wantCursor: 7},
+	})
+}
+
+func TestShell_deleteBlankLines(t *testing.T) {
+	runWidgetTests(t, "delete-blank-lines", []widgetTest{
+		{name: "Run of blank lines", line: "one\n\n  \n\ntwo", cursor: 5, want: "one\n\ntwo", wantCursor: 4},
+		{name: "Run at the start", line: "\n\n\none", cursor: 1, want: "\none", wantCursor: 0},
+		{name: "Run at the end", line: "one\n\n\n", cursor: 6, want: "one\n", wantCursor: 4},
+		{name: "Single blank line", line: "one\n\ntwo", cursor: 4, want: "one\ntwo", wantCursor: 4},
+		{name: "Single blank last line", line: "one\n  ", cursor: 6, want: "one", wantCursor: 3},
+		{name: "Tabs and spaces", line: "a\n\t\n \t\nb", cursor: 2, want: "a\n\nb", wantCursor: 2},
+		{name: "Blank buffer", line: "   ", cursor: 1, want: "", wantCursor: 0},
+		{name: "Non-blank line", line: "one\n\n\ntwo", cursor: 1, want: "one\ntwo", wantCursor: 1},
+		{name: "Non-blank line without blanks after", line: "\n\none\ntwo", cursor: 3, want: "\n\none\ntwo", wantCursor: 3},
+		{name: "Single line", line: "one two", cursor: 2, want: "one two", wantCursor: 2},
+	})
+}
+
+func TestShell_moveLine(t *testing.T) {
+	runWidgetTests(t, "move-line-up", []widgetTest{
+		{name: "Up", line: "one\ntwo\nthree", cursor: 5, want: "two\none\nthree", wantCursor: 1},
+		{name: "Down", widget: "move-line-down", line: "one\ntwo\nthree", cursor: 5, want: "one\nthree\ntwo", wantCursor: 11},
+		{name: "Numeric argument", line: "a\nb\nc\nd", cursor: 7, input: "\x1b2\x18w", want: "a\nd\nb\nc", wantCursor: 3},
+		{name: "Negative argument", line: "a\nb\nc\nd", cursor: 0, input: "\x1b-\x1b2\x18w", want: "b\nc\na\nd", wantCursor: 4},
+		{name: "Past the edge", line: "a\nb\nc", cursor: 4, input: "\x1b5\x18w", want: "c\na\nb", wantCursor: 0},
+		{name: "Empty line", line: "a\n\nb", cursor: 2, want: "\na\nb", wantCursor: 0},
+		{name: "Cursor at end of line", line: "ab\ncd", cursor: 5, want: "cd\nab", wantCursor: 2},
+		{name: "Onto a shorter line", widget: "move-line-down", line: "abcd\nx", cursor: 3, want: "x\nabcd", wantCursor: 5},
+		{name: "First line up", line: "one\ntwo", cursor: 1, want: "one\ntwo", wantCursor: 1},
+		{name: "Last line down", widget: "move-line-down", line: "one\ntwo", cursor: 6, want: "one\ntwo", wantCursor: 6},
+		{name: "Single line", widget: "move-line-down", line: "one", cursor: 1, want: "one", wantCursor: 1},
+	})
+}
+
+func TestShell_incrementNumbersInRegion(t *testing.T) {
+	runWidgetTests(t, "increment-numbers-in-region", []widgetTest{
+		{name: "Mixed text and numbers", line: "set x=1 y=20 z=-3", setup: markAt(0), cursor: 17, want: "set x=2 y=21 z=-2", wantCursor: 17},
+		{name: "Numeric argument", line: "a1 b2 c3", setup: markAt(0), cursor: 8, input: "\x1b1\x1b0\x18w", want: "a11 b12 c13", wantCursor: 11},
+		{name: "Negative argument", line: "10 20", setup: markAt(0), cursor: 5, input: "\x1b-\x1b5\x18w", want: "5 15", wantCursor: 4},
+		{name: "Down to negative", line: "x 1", setup: markAt(0), cursor: 3, input: "\x1b-\x1b2\x18w", want: "x -1", wantCursor: 4},
+		{name: "Padded numbers", line: "file007 file099 file-009", setup: markAt(0), cursor: 24, want: "file008 file100 file-010", wantCursor: 24},
+		{name: "Padded negative", line: "x -02", setup: markAt(0), cursor: 5, input: "\x1b-\x1b5\x18w", want: "x -07", wantCursor: 5},
+		{name: "Only in region", line: "1 2 3", setup: markAt(2), cursor: 3, want: "1 3 3", wantCursor: 3},
+		{name: "Reversed region", line: "1 2 3", setup: markAt(3), cursor: 2, want: "1 3 3", wantCursor: 2},
+		{name: "Number cut by the region", line: "x=129", setup: markAt(0), cursor: 4, want: "x=139", wantCursor: 4},
+		{name: "No numbers", line: "echo hello", setup: markAt(0), cursor: 10, want: "echo hello", wantCursor: 10},
+		{name: "No region", line: "echo 1", cursor: 6, want: "echo 1", wantCursor: 6},
+	})
+}
+
+func TestShell_blockInsert(t *testing.T) {
+	pad := map[string]interface{}{"block-insert-pad": true}
+
+	runWidgetTests(t, "block-insert", []widgetTest{
+		{name: "Region lines", line: "aaa\nbbb\nccc", setup: markAt(1), cursor: 9, input: "\x18w--\r", want: "a--aa\nb--bb\nc--cc", wantCursor: 3},
+		{name: "Leftmost column", line: "aaa\nbbb", setup: markAt(2), cursor: 5, input: "\x18w|\r", want: "a|aa\nb|bb", wantCursor: 2},
+		{name: "Part of the lines", line: "aaa\nbbb\nccc", setup: markAt(4), cursor: 8, input: "\x18w#\r", want: "aaa\n#bbb\n#ccc", wantCursor: 5},
+		{name: "Skip short lines", line: "aaa\nb\nccc", setup: markAt(2), cursor: 10, input: "\x18w|\r", want: "aa|a\nb\ncc|c", wantCursor: 3},
+		{name: "Pad short lines", line: "aaa\nb\nccc", setup: markAt(2), cursor: 10, options: pad, input: "\x18w|\r", want: "aa|a\nb |\ncc|c", wantCursor: 3},
+		{name: "Wide characters", line: "éa\néb", setup: markAt(1), cursor: 4, input: "\x18w|\r", want: "é|a\né|b", wantCursor: 2},
+		{name: "Numeric argument", line: "aaa\nbbb\nccc", cursor: 0, input: "\x1b2\x18w> \r", want: "> aaa\n> bbb\nccc", wantCursor: 2},
+		{name: "Numeric argument past the end", line: "a\nb", cursor: 0, input: "\x1b5\x18w> \r", want: "> a\n> b", wantCursor: 2},
+		{name: "Current line", line: "echo\nls", cursor: 5, input: "\x18wsudo \r", want: "echo\nsudo ls", wantCursor: 10},
+		{name: "Empty input", line: "aaa\nbbb", setup: markAt(0), cursor: 4, input: "\x18w\r", want: "aaa\nbbb", wantCursor: 4},
+		{name: "Aborted", line: "aaa\nbbb", setup: markAt(0), cursor: 4, input: "\x18wx\x1b", want: "aaa\nbbb", wantCursor: 4},
+	})
+}
+
+func TestShell_convertIndentation(t *testing.T) {
+	width := func(width int) map[string]interface{} {
+		return map[string]interface{}{"tab-width": width}
+	}
+
+	all := map[string]interface{}{"tab-width": 4, "tab-convert-leading-only": false}
+
+	runWidgetTests(t, "tabs-to-spaces", []widgetTest{
+		{name: "Tabs to spaces", line: "if x\n\tcall\n\t\treturn", cursor: 7, want: "if x\n        call\n                return", wantCursor: 14},
+		{name: "Tab width", options: width(4), line: "\tcall", cursor: 0, want: "    call", wantCursor: 0},
+		{name: "Mixed indentation to spaces", options: width(4), line: "  \t x\ty", cursor: 5, want: "     x\ty", wantCursor: 6},
+		{name: "All tabs to spaces", options: all, line: "\tab\tc", cursor: 4, want: "    ab  c", wantCursor: 8},
+		{name: "Cursor in indentation", options: width(2), line: "\t\tx", cursor: 1, want: "    x", wantCursor: 4},
+		{name: "Tabs after text kept", options: width(4), line: "a\tb", cursor: 1, want: "a\tb", wantCursor: 1},
+		{name: "Spaces to tabs", widget: "spaces-to-tabs", options: width(4), line: "if x\n    call\n         return", cursor: 10, want: "if x\n\tcall\n\t\t return", wantCursor: 7},
+		{name: "Mixed indentation to tabs", widget: "spaces-to-tabs", options: width(4), line: "  \t  x", cursor: 5, want: "\t  x", wantCursor: 3},
+		{name: "Short indentation kept", widget: "spaces-to-tabs", options: width(4), line: "   x", cursor: 3, want: "   x", wantCursor: 3},
+		{name: "All spaces to tabs", widget: "spaces-to-tabs", options: all, line: "a   b c", cursor: 7, want: "a\tb c", wantCursor: 5},
+		{name: "No indentation", widget: "spaces-to-tabs", line: "echo hi", cursor: 2, want: "echo hi", wantCursor: 2},
+	})
+}
+
+func TestShell_togglePrefix(t *testing.T) {
+	prefix := func(prefix string) func(rl *Shell) {
+		return func(rl *Shell) { rl.SetTogglePrefix(prefix) }
+	}
+
+	runWidgetTests(t, "toggle-prefix", []widgetTest{
+		{name: "Add default prefix", line: "apt update", cursor: 4, want: "sudo apt update", wantCursor: 9},
+		{name: "Remove default prefix", line: "sudo apt update", cursor: 9, want: "apt update", wantCursor: 4},
+		{name: "Cursor in prefix", line: "sudo apt update", cursor: 2, want: "apt update", wantCursor: 0},
+		{name: "Prefix only", line: "sudo ", cursor: 5, want: "", wantCursor: 0},
+		{name: "Partial prefix", line: "sud ls", cursor: 0, want: "sudo sud ls", wantCursor: 5},
+		{name: "Custom prefix", setup: prefix("time "), line: "make", cursor: 4, want: "time make", wantCursor: 9},
+		{name: "Other prefix kept", setup: prefix("time "), line: "sudo make", cursor: 0, want: "time sudo make", wantCursor: 5},
+		{name: "Empty prefix", setup: prefix(""), line: "make", cursor: 2, want: "make", wantCursor: 2},
+		{name: "Empty line", line: "", cursor: 0, want: "sudo ", wantCursor: 5},
+	})
+
+	// Toggling again goes back to the original line.
+	rl := newTestShell(t, keymap.Emacs, "apt update", 4)
+	rl.Config.Bind(string(keymap.Emacs), "\x18w", "toggle-prefix", false)
+
+	runKeys(t, rl, "\x18w\x18w")
+
+	if got := string(*rl.line); got != "apt update" || rl.cursor.Pos() != 4 {
+		t.Errorf("toggled twice: line = %q (cursor %d), want unchanged", got, rl.cursor.Pos())
+	}
+}
+
+func TestShell_selectAll(t *testing.T) {
+	tests := []struct {
+		name       string
+		replace    bool
+		autoPair   bool
+		input      string
+		want       string
+		wantCursor int
+	}{
+		{name: "Select only", input: "\x18a", want: "echo foo", wantCursor: 8},
+		{name: "Type without replace", input: "\x18ax", want: "echo foox", wantCursor: 9},
+		{name: "Type replaces selection", replace: true, input: "\x18abar", want: "bar", wantCursor: 3},
+		{name: "Replace with auto-pair", replace: true, autoPair: true, input: "\x18a(", want: "(echo foo)", wantCursor: 10},
+		{name: "Undo replacement", replace: true, input: "\x18ax\x1f", want: "echo foo", wantCursor: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "echo foo", 3)
+			rl.Config.Bind(string(keymap.Emacs), "\x18a", "select-all", false)
+			rl.Config.Set("replace-selection-on-type", test.replace)
+			rl.Config.Set("auto-pair", test.autoPair)
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+}
+
+func TestShell_selectArrows(t *testing.T) {
+	tests := []struct {
+		name         string
+		line         string
+		cursor       int
+		marked       bool // A region is active from the beginning of the line.
+		input        string
+		wantSelected string
+		wantCursor   int
+	}{
+		{name: "Shift-Right", cursor: 0, input: "\x1b[1;2C\x1b[1;2C", wantSelected: "ec", wantCursor: 2},
+		{name: "Shift-Left", cursor: 8, input: "\x1b[1;2D\x1b[1;2D", wantSelected: "oo", wantCursor: 6},
+		{name: "Shift back over anchor", cursor: 4, input: "\x1b[1;2C\x1b[1;2D\x1b[1;2D", wantSelected: "o", wantCursor: 3},
+		{name: "Shift back to anchor", cursor: 4, input: "\x1b[1;2C\x1b[1;2D", wantSelected: "", wantCursor: 4},
+		{name: "Control-Shift-Right", cursor: 0, input: "\x1b[1;6C", wantSelected: "echo", wantCursor: 4},
+		{name: "Control-Shift-Left", cursor: 8, input: "\x1b[1;6D", wantSelected: "foo", wantCursor: 5},
+		{name: "Shift-Down", line: "echo foo\nbar", cursor: 2, input: "\x1b[1;2B", wantSelected: "ho foo\nba", wantCursor: 11},
+		{name: "Shrink", cursor: 0, input: "\x1b[1;2C\x1b[1;2C\x1b[1;2C\x1b[1;2D", wantSelected: "ec", wantCursor: 2},
+		{name: "Shrink by word", cursor: 0, input: "\x1b[1;6C\x1b[1;6C\x1b[1;6D", wantSelected: "echo ", wantCursor: 5},
+		{name: "Shift-End", cursor: 2, input: "\x1b[1;2F", wantSelected: "ho foo", wantCursor: 8},
+		{name: "Shift-Home", cursor: 6, input: "\x1b[1;2H", wantSelected: "echo f", wantCursor: 0},
+		{name: "Shift-Home after Shift-End", cursor: 5, input: "\x1b[1;2F\x1b[1;2H", wantSelected: "echo ", wantCursor: 0},
+		{name: "Extend a marked region", cursor: 2, marked: true, input: "\x1b[1;2C", wantSelected: "ech", wantCursor: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := test.line
+			if line == "" {
+				line = "echo foo"
+			}
+
+			rl := newTestShell(t, keymap.Emacs, line, test.cursor)
+			if test.marked {
+				rl.selection.Mark(0)
+			}
+
+			runKeys(t, rl, test.input)
+
+			if got := rl.selection.Text(); got != test.wantSelected {
+				t.Errorf("selected = %q, want %q", got, test.wantSelected)
+			}
+
+			if test.wantSelected != "" && !rl.selection.IsVisual() {
+				t.Errorf("selection should be highlighted")
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+
+	// Typing replaces the selection with replace-selection-on-type.
+	rl := newTestShell(t, keymap.Emacs, "echo foo", 8)
+	rl.Config.Set("replace-selection-on-type", true)
+
+	runKeys(t, rl, "\x1b[1;6Dbar")
+
+	if got := string(*rl.line); got != "echo bar" {
+		t.Errorf("line = %q, want %q", got, "echo bar")
+	}
+}
+
+func TestShell_transposeChars(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		cursor     int
+		input      string
+		want       string
+		wantCursor int
+	}{
+		{name: "Middle of line", line: "abcd", cursor: 2, input: "\x14", want: "acbd", wantCursor: 3},
+		{name: "Repeated drag", line: "abcd", cursor: 1, input: "\x14\x14", want: "bcad", wantCursor: 3},
+		{name: "End of line", line: "abcd", cursor: 4, input: "\x14", want: "abdc", wantCursor: 4},
+		{name: "Beginning of line", line: "abcd", cursor: 0, input: "\x14", want: "abcd", wantCursor: 0},
+		{name: "Numeric argument", line: "abcdef", cursor: 1, input: "\x1b3\x14", want: "bcdaef", wantCursor: 4},
+		{name: "Numeric argument clamped", line: "abcd", cursor: 1, input: "\x1b9\x14", want: "bcda", wantCursor: 4},
+		{name: "Numeric argument at end of line", line: "abcd", cursor: 4, input: "\x1b3\x14", want: "abdc", wantCursor: 4},
+		{name: "Negative argument", line: "abcdef", cursor: 4, input: "\x1b-\x1b2\x14", want: "adbcef", wantCursor: 2},
+		{name: "Negative argument clamped", line: "abcd", cursor: 3, input: "\x1b-\x1b9\x14", want: "cabd", wantCursor: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+}
+
+func TestShell_insertDatetime(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		input  string
+		layout string
+	}{
+		{name: "Default format", input: "\x18d", layout: time.RFC3339},
+		{name: "Custom format", format: "2006/01/02 15h04", input: "\x18d", layout: "2006/01/02 15h04"},
+		{name: "Date only", input: "\x1b2\x18d", layout: time.DateOnly},
+		{name: "Time only", input: "\x1b3\x18d", layout: time.TimeOnly},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "note: ", 6)
+			rl.Config.Bind(string(keymap.Emacs), "\x18d", "insert-datetime", false)
+
+			if test.format != "" {
+				rl.Config.Set("datetime-format", test.format)
+			}
+
+			before := time.Now()
+			runKeys(t, rl, test.input)
+
+			inserted := strings.TrimPrefix(string(*rl.line), "note: ")
+
+			if _, err := time.Parse(test.layout, inserted); err != nil {
+				t.Fatalf("inserted %q does not match layout %q: %v", inserted, test.layout, err)
+			}
+
+			if want := len("note: ") + len(before.Format(test.layout)); rl.cursor.Pos() != want {
+				t.Errorf("cursor = %d, want %d", rl.cursor.Pos(), want)
+			}
+		})
+	}
+}
+
+func TestShell_rotateWord(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		cursor     int
+		input      string
+		want       string
+		wantCursor int
+	}{
+		{name: "Rotate forward", line: "curl -X GET /", cursor: 8, input: "\x18r", want: "curl -X POST /", wantCursor: 11},
+		{name: "Rotate twice", line: "curl -X GET /", cursor: 8, input: "\x18r\x18r", want: "curl -X PUT /", wantCursor: 10},
+		{name: "Wrap to first", line: "curl -X DELETE /", cursor: 8, input: "\x18r", want: "curl -X GET /", wantCursor: 10},
+		{name: "Numeric argument", line: "curl -X GET /", cursor: 8, input: "\x1b3\x18r", want: "curl -X DELETE /", wantCursor: 13},
+		{name: "Negative argument", line: "curl -X GET /", cursor: 8, input: "\x1b-\x18r", want: "curl -X DELETE /", wantCursor: 13},
+		{name: "Other group", line: "level=debug", cursor: 8, input: "\x18r", want: "level=info", wantCursor: 9},
+		{name: "Unmatched word", line: "curl -X HEAD /", cursor: 8, input: "\x18r", want: "curl -X HEAD /", wantCursor: 8},
+		{name: "Keyword increase", line: "curl -X GET /", cursor: 8, input: "\x18+", want: "curl -X POST /", wantCursor: 11},
+		{name: "Keyword decrease", line: "curl -X GET /", cursor: 8, input: "\x18-", want: "curl -X DELETE /", wantCursor: 13},
+		{name: "Builtin switchers still used", line: "set on", cursor: 4, input: "\x18+", want: "set off", wantCursor: 6},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+			rl.Config.Bind(string(keymap.Emacs), "\x18r", "rotate-word", false)
+			rl.Config.Bind(string(keymap.Emacs), "\x18+", "keyword-increase", false)
+			rl.Config.Bind(string(keymap.Emacs), "\x18-", "keyword-decrease", false)
+
+			rl.AddRotation([]string{"GET", "POST", "PUT", "DELETE"})
+			rl.AddRotation([]string{"debug", "info", "warn", "error"})
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+}
+
+func TestShell_zapToChar(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		cursor     int
+		input      string
+		want       string
+		wantKilled string
+		wantCursor int
+	}{
+		{name: "Forward", line: "echo foo/bar/baz", cursor: 5, input: "\x18z/", want: "echo bar/baz", wantKilled: "foo/", wantCursor: 5},
+		{name: "Nth occurrence", line: "echo foo/bar/baz", cursor: 5, input: "\x1b2\x18z/", want: "echo baz", wantKilled: "foo/bar/", wantCursor: 5},
+		{name: "Backward", line: "echo foo/bar/baz", cursor: 16, input: "\x1b-\x18z/", want: "echo foo/bar", wantKilled: "/baz", wantCursor: 12},
+		{name: "Backward Nth occurrence", line: "echo foo/bar/baz", cursor: 16, input: "\x1b-\x1b2\x18z/", want: "echo foo", wantKilled: "/bar/baz", wantCursor: 8},
+		{name: "Character not found", line: "echo foo", cursor: 0, input: "\x18z/", want: "echo foo", wantCursor: 0},
+
+		{name: "Up to forward", line: "echo foo/bar/baz", cursor: 5, input: "\x18t/", want: "echo /bar/baz", wantKilled: "foo", wantCursor: 5},
+		{name: "Up to Nth occurrence", line: "echo foo/bar/baz", cursor: 5, input: "\x1b2\x18t/", want: "echo /baz", wantKilled: "foo/bar", wantCursor: 5},
+		{name: "Up to backward", line: "echo foo/bar/baz", cursor: 16, input: "\x1b-\x18t/", want: "echo foo/bar/", wantKilled: "baz", wantCursor: 13},
+		{name: "Up to backward Nth occurrence", line: "echo foo/bar/baz", cursor: 16, input: "\x1b-\x1b2\x18t/", want: "echo foo/", wantKilled: "bar/baz", wantCursor: 9},
+		{name: "Up to next character", line: "a/b", cursor: 0, input: "\x18t/", want: "/b", wantKilled: "a", wantCursor: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+			rl.Config.Bind(string(keymap.Emacs), "\x18z", "zap-to-char", false)
+			rl.Config.Bind(string(keymap.Emacs), "\x18t", "zap-up-to-char", false)
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+
+			if got := string(rl.Buffers.GetKill()); test.wantKilled != "" && got != test.wantKilled {
+				t.Errorf("killed = %q, want %q", got, test.wantKilled)
+			}
+		})
+	}
+}
+
+func TestShell_repeatLastCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		line       string
+		cursor     int
+		input      string
+		want       string
+		wantCursor int
+	}{
+		{name: "Kill word", line: "one two three four", input: "\x1bd\x18z", want: " three four", wantCursor: 0},
+		{name: "Forward char with count", line: "abcdefghij", input: "\x1b3\x06\x18z", want: "abcdefghij", wantCursor: 6},
+		{name: "Repeated again", line: "abcdefghij", input: "\x1b2\x06\x18zzz", want: "abcdefghij", wantCursor: 8},
+		{name: "Other count", line: "abcdefghij", input: "\x1b3\x06\x1b1\x18z", want: "abcdefghij", wantCursor: 4},
+		{name: "Other key stops", line: "abcdef", input: "\x06\x18zaz", want: "abazcdef", wantCursor: 4},
+		{name: "Character read reused", line: "a-b-c-d", input: "\x1d-\x18zz", want: "a-b-c-d", wantCursor: 5},
+		{name: "Nothing to repeat", line: "abc", cursor: 1, input: "\x18z", want: "abc", wantCursor: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+}
+
+func TestShell_stickyArgument(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantCursor int
+	}{
+		{name: "Several movements", input: "\x1b3\x18a\x06\x06\x06", wantCursor: 9},
+		{name: "Toggled on before the argument", input: "\x18a\x1b2\x06\x06", wantCursor: 4},
+		{name: "Other argument replaces it", input: "\x1b2\x18a\x06\x1b3\x06\x06", wantCursor: 8},
+		{name: "Kept by resetting commands", input: "\x1b2\x18a\x06\x0b\x02", wantCursor: 0},
+		{name: "Toggled off", input: "\x1b2\x18a\x06\x18a\x06\x06", wantCursor: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "abcdefghijkl", 0)
+			rl.Config.Bind(string(keymap.Emacs), "\x18a", "sticky-argument", false)
+
+			runKeys(t, rl, test.input)
+
+			if got := rl.cursor.Pos(); got != test.wantCursor {
+				t.Errorf("cursor = %d, want %d", got, test.wantCursor)
+			}
+		})
+	}
+
+	// Aborting drops the argument.
+	rl := newTestShell(t, keymap.Emacs, "abcdefghijkl", 0)
+	rl.Config.Bind(string(keymap.Emacs), "\x18a", "sticky-argument", false)
+
+	runKeys(t, rl, "\x1b2\x18a\x06\x07")
+	runKeys(t, rl, "\x06")
+
+	if got := rl.cursor.Pos(); got != 3 || rl.Iterations.IsSticky() {
+		t.Errorf("cursor = %d (sticky: %v), want 3 after abort", got, rl.Iterations.IsSticky())
+	}
+}
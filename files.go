@@ -0,0 +1,155 @@
+package readline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexj212/readline/internal/completion"
+	"github.com/alexj212/readline/internal/strutil"
+)
+
+// SetCompletionBaseDir sets the directory under which the complete-filename
+// command resolves relative paths, instead of the working directory of the
+// process: for shells scoped to a project, for instance. Paths starting with
+// a tilde (the home directory) or absolute ones are not affected by it, and
+// an empty directory restores completion relative to the working directory.
+func (rl *Shell) SetCompletionBaseDir(dir string) {
+	rl.completionBaseDir = dir
+}
+
+// Attempt filename completion on the current word, whatever the completer
+// of the shell: the word is completed as a path, relative to the base
+// directory if one is set (see SetCompletionBaseDir), and directories
+// are inserted with a trailing slash, so as to complete their contents.
+// Names are backslash-escaped, or quoted if the word opens a quote.
+func (rl *Shell) completeFilename() {
+	rl.startMenuComplete(rl.filenameCompletion)
+}
+
+// filenameCompletion generates the file candidates for the shell word before
+// the cursor, which may be quoted or contain backslash-escaped blanks.
+func (rl *Shell) filenameCompletion() completion.Values {
+	line, cursor := rl.completer.Line()
+
+	word := ""
+	if pos := cursor.Pos(); pos > 0 {
+		if bpos, _ := strutil.WordBounds(*line, pos-1); bpos != -1 {
+			word = string((*line)[bpos:pos])
+		}
+	}
+
+	comps := rl.completeFiles(word)
+
+	return comps.convert()
+}
+
+// completeFiles returns the files and directories completing a word, as typed:
+// the path it stands for is listed from the directory of its last slash, if any,
+// and candidates are the word followed by the rest of each name, escaped or quoted
+// like the word. A quote left open by the word is closed after file names.
+func (rl *Shell) completeFiles(word string) Completions {
+	path, quote := unquotePath(word)
+
+	dir, prefix := "", path
+	if slash := strings.LastIndex(path, "/"); slash != -1 {
+		dir, prefix = path[:slash+1], path[slash+1:]
+	}
+
+	entries, err := os.ReadDir(rl.resolvePath(dir))
+	if err != nil {
+		return Completions{}
+	}
+
+	values := make([]Completion, 0, len(entries))
+
+	for _, entry := range entries {
+		name := entry.Name()
+
+		if !strings.HasPrefix(name, prefix) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".")) {
+			continue
+		}
+
+		directory := isDir(rl.resolvePath(dir+name), entry)
+
+		value := word + quotePath(name[len(prefix):], quote, !directory)
+		if directory {
+			value, name = value+"/", name+"/"
+		}
+
+		values = append(values, Completion{Value: value, Display: name})
+	}
+
+	comps := CompleteRaw(values).NoSpace('/')
+	comps.PREFIX = word
+
+	return comps
+}
+
+// unquotePath returns the path a shell word stands for, and the quote it
+// leaves open, if any, in which case the path is the word as if closed.
+func unquotePath(word string) (path string, quote rune) {
+	if path, err := strutil.Unquote(word); err == nil {
+		return path, 0
+	}
+
+	for _, quote := range []rune{'"', '\''} {
+		if path, err := strutil.Unquote(word + string(quote)); err == nil {
+			return path, quote
+		}
+	}
+
+	return word, 0
+}
+
+// quotePath returns a part of a path to be inserted after a shell word, within
+// the quote it leaves open if any (closed if requested), or backslash-escaped.
+func quotePath(part string, quote rune, closed bool) string {
+	var quoted string
+
+	switch quote {
+	case '"':
+		quoted = strutil.QuoteDouble(part)
+	case '\'':
+		quoted = strutil.QuoteSingle(part)
+	default:
+		return strutil.Escape(part)
+	}
+
+	if !closed {
+		quoted = quoted[:len(quoted)-1]
+	}
+
+	return quoted[1:]
+}
+
+// resolvePath returns the directory in which a typed path is to be found:
+// a tilde is expanded to the home directory, absolute paths are kept as is,
+// and any other is joined to the completion base directory.
+func (rl *Shell) resolvePath(path string) string {
+	switch {
+	case path == "~" || strings.HasPrefix(path, "~/"):
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, filepath.FromSlash(path[1:]))
+		}
+	case filepath.IsAbs(path) || strings.HasPrefix(path, "/"):
+		return filepath.FromSlash(path)
+	}
+
+	if path == "" {
+		path = "."
+	}
+
+	return filepath.Join(rl.completionBaseDir, filepath.FromSlash(path))
+}
+
+// isDir returns true if an entry is a directory, or a link to one.
+func isDir(path string, entry os.DirEntry) bool {
+	if entry.Type()&os.ModeSymlink == 0 {
+		return entry.IsDir()
+	}
+
+	info, err := os.Stat(path)
+
+	return err == nil && info.IsDir()
+}
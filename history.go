@@ -1,11 +1,17 @@
 package readline
 
 import (
+	"os"
+	"regexp"
 	"strings"
 
 	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/editor"
 	"github.com/alexj212/readline/internal/history"
+	"github.com/alexj212/readline/internal/keymap"
 	"github.com/alexj212/readline/internal/strutil"
+	"github.com/alexj212/readline/internal/term"
 )
 
 //
@@ -56,6 +62,9 @@ func (rl *Shell) historyCommands() commands {
 
 		"accept-and-hold":                    rl.acceptAndHold,
 		"accept-and-infer-next-history":      rl.acceptAndInferNextHistory,
+		"accept-and-reopen":                  rl.acceptAndReopen,
+		"accept-and-page":                    rl.acceptAndPage,
+		"accept-and-run":                     rl.acceptAndRun,
 		"down-line-or-history":               rl.downLineOrHistory,
 		"vi-down-line-or-history":            rl.viDownLineOrHistory,
 		"up-line-or-history":                 rl.upLineOrHistory,
@@ -68,6 +77,7 @@ func (rl *Shell) historyCommands() commands {
 		"end-of-line-hist":                   rl.endOfLineHist,
 		"incremental-forward-search-history": rl.incrementalForwardSearchHistory,
 		"incremental-reverse-search-history": rl.incrementalReverseSearchHistory,
+		"history-search-word-under-cursor":   rl.historySearchWordUnderCursor,
 		"save-line":                          rl.saveLine,
 		"history-source-next":                rl.historySourceNext,
 		"history-source-prev":                rl.historySourcePrev,
@@ -361,8 +371,7 @@ func (rl *Shell) magicSpace() {
 	}
 
 	rl.History.Save()
-	rl.line.Cut(bpos, lineLen)
-	rl.line.Insert(bpos, suggested...)
+	rl.line.Replace(bpos, lineLen, suggested...)
 	rl.cursor.Set(bpos + suggested.Len())
 }
 
@@ -376,6 +385,64 @@ func (rl *Shell) acceptAndHold() {
 	rl.acceptLineWith(false, true)
 }
 
+// Accept the current input line like accept-and-hold, so that the next
+// readline loop starts with it. If accept-and-reopen-select is on, the
+// line is also selected as the active region when reopened.
+func (rl *Shell) acceptAndReopen() {
+	rl.acceptLineWith(false, true)
+	rl.reopen, _, _ = rl.History.LineAccepted()
+}
+
+// Accept the current input line, run it with the executor set with SetExecutor(),
+// and show its output in the system pager ($PAGER, or less), before starting to
+// read a new line. Without an executor, this is identical to accept-line.
+func (rl *Shell) acceptAndPage() {
+	if rl.executor == nil {
+		rl.acceptLine()
+		return
+	}
+
+	rl.executeLine(func(output string, err error) {
+		if output != "" {
+			rl.withTerminalRestored(func() {
+				if pageErr := editor.Page(output); pageErr != nil && err == nil {
+					err = pageErr
+				}
+			})
+		}
+
+		if err != nil {
+			term.Printf("%s%s%s\r\n", color.FgRed, err.Error(), color.Reset)
+		}
+	})
+}
+
+// Accept the current input line, run it with the executor set with SetExecutor(),
+// and print its output above a new prompt, on which a new line is read. An error
+// returned by the executor is shown in the hint section. Without an executor,
+// this is identical to accept-line.
+func (rl *Shell) acceptAndRun() {
+	if rl.executor == nil {
+		rl.acceptLine()
+		return
+	}
+
+	var output string
+	var err error
+
+	rl.executeLine(func(out string, runErr error) {
+		output, err = out, runErr
+	})
+
+	if output != "" {
+		rl.printAbovePrompt(output)
+	}
+
+	if err != nil {
+		rl.Hint.SetTemporary(color.FgRed + err.Error() + color.Reset)
+	}
+}
+
 // Execute the contents of the buffer. Then search the history list for a line
 // matching the current one and push the event following onto the buffer stack.
 func (rl *Shell) acceptAndInferNextHistory() {
@@ -552,6 +619,36 @@ func (rl *Shell) incrementalReverseSearchHistory() {
 	rl.historyCompletion(forward, filter, regexp)
 }
 
+// Start an incremental search backward in the history, like reverse-search-history,
+// with the search already filled with the blank word under the cursor (or before
+// it, at the end of the line), which is matched literally.
+func (rl *Shell) historySearchWordUnderCursor() {
+	rl.History.SkipSave()
+
+	var word string
+
+	if rl.line.Len() > 0 {
+		bpos, epos := rl.line.SelectBlankWord(rl.cursor.Pos())
+		word = strings.TrimSpace(string((*rl.line)[bpos : epos+1]))
+	}
+
+	rl.reverseSearchHistory()
+
+	if word == "" || rl.Keymap.Local() != keymap.Isearch {
+		return
+	}
+
+	// History matches are filtered with the line as a prefix, so clear it:
+	// the search still restores it if cancelled or if nothing matches.
+	rl.line.Set()
+	rl.cursor.Set(0)
+
+	// The matches are updated with the new search once the command returns.
+	search, cursor, _ := rl.completer.GetBuffer()
+	search.Set([]rune(regexp.QuoteMeta(word))...)
+	cursor.Set(search.Len())
+}
+
 // Write the current line to the history if it is not empty
 // (without executing it), and clear the line buffer.
 func (rl *Shell) saveLine() {
@@ -629,6 +726,21 @@ func (rl *Shell) autosuggestDisable() {
 //
 
 func (rl *Shell) acceptLineWith(infer, hold bool) {
+	if !rl.acceptableLine() {
+		return
+	}
+
+	rl.Macros.StopRecord(rl.Keys.Caller()...)
+
+	rl.Display.AcceptLine()
+	rl.History.Accept(hold, infer, nil)
+}
+
+// acceptableLine returns true if the input line can be accepted by a command,
+// after the checks common to all of them: a non-incremental search in progress
+// is concluded instead, and if the caller doesn't accept the line as complete
+// (see AcceptMultiline), a newline is inserted at the cursor instead.
+func (rl *Shell) acceptableLine() bool {
 	// If we are currently using the incremental-search buffer,
 	// we should cancel this mode so as to run the rest of this
 	// function on (with) the input line itself, not the minibuffer.
@@ -644,39 +756,69 @@ func (rl *Shell) acceptLineWith(infer, hold bool) {
 		line, cursor, _ := rl.completer.GetBuffer()
 		rl.History.InsertMatch(line, cursor, true, forward, substring)
 
-		return
+		return false
 	}
 
 	// Use the correct buffer for the rest of the function.
 	rl.line, rl.cursor, rl.selection = rl.completer.GetBuffer()
 
-	// Without multiline support, we always return the line.
-	if rl.AcceptMultiline == nil {
-		rl.Macros.StopRecord(rl.Keys.Caller()...)
+	// Without multiline support, we always accept the line,
+	// otherwise we ask the caller if it should be accepted.
+	if rl.AcceptMultiline == nil || rl.AcceptMultiline(*rl.line) {
+		return true
+	}
+
+	// If not, we should start editing another line,
+	// and insert a newline where our cursor value is.
+	// This has the nice advantage of being able to work
+	// in multiline mode even in the middle of the buffer.
+	rl.line.Insert(rl.cursor.Pos(), '\n')
+	rl.cursor.Inc()
 
-		rl.Display.AcceptLine()
-		rl.History.Accept(hold, infer, nil)
+	return false
+}
 
+// executeLine accepts the line without returning it from Readline(), after the same
+// checks as accept-line (see acceptableLine): the line is written to the history and
+// run with the executor, and show is called with its results, below the line.
+// A new line is then read, below a new primary prompt.
+func (rl *Shell) executeLine(show func(output string, err error)) {
+	if !rl.acceptableLine() {
 		return
 	}
 
-	// Ask the caller if the line should be accepted
-	// as is, save the command line and accept it.
-	if rl.AcceptMultiline(*rl.line) {
-		rl.Macros.StopRecord(rl.Keys.Caller()...)
+	rl.Macros.StopRecord(rl.Keys.Caller()...)
+
+	rl.Display.AcceptLine()
+	rl.History.Write(false)
+
+	output, err := rl.executor(string(*rl.line))
+	show(output, err)
 
-		rl.Display.AcceptLine()
-		rl.History.Accept(hold, infer, nil)
+	rl.init()
+	rl.Display.PrintPrimaryPrompt()
+}
 
+// withTerminalRestored runs a function with the terminal in the state it was
+// in before Readline() put it in raw mode, and puts it back in raw mode after.
+func (rl *Shell) withTerminalRestored(run func()) {
+	if rl.termState == nil {
+		run()
 		return
 	}
 
-	// If not, we should start editing another line,
-	// and insert a newline where our cursor value is.
-	// This has the nice advantage of being able to work
-	// in multiline mode even in the middle of the buffer.
-	rl.line.Insert(rl.cursor.Pos(), '\n')
-	rl.cursor.Inc()
+	descriptor := int(os.Stdin.Fd())
+
+	raw, err := term.GetState(descriptor)
+	if err != nil {
+		run()
+		return
+	}
+
+	term.Restore(descriptor, rl.termState)
+	defer term.Restore(descriptor, raw)
+
+	run()
 }
 
 func (rl *Shell) insertAutosuggestPartial(emacs bool) {
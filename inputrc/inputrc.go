@@ -16,7 +16,8 @@ import (
 	"unicode"
 )
 
-// Parse parses inputrc data from r.
+// Parse parses inputrc data from r. Unless halting on the first error, all
+// lines are parsed, and the errors encountered are returned together.
 func Parse(r io.Reader, h Handler, opts ...Option) error {
 	return New(opts...).Parse(r, h)
 }
@@ -37,7 +38,7 @@ func ParseFile(name string, h Handler, opts ...Option) error {
 }
 
 // UserDefault loads default inputrc settings for the user.
-func UserDefault(u *user.User, cfg *Config, opts ...Option) error {
+func UserDefault(u *user.User, h Handler, opts ...Option) error {
 	// build possible file list
 	var files []string
 	if name := os.Getenv("INPUTRC"); name != "" {
@@ -55,14 +56,14 @@ func UserDefault(u *user.User, cfg *Config, opts ...Option) error {
 	}
 	// load first available file
 	for _, name := range files {
-		buf, err := cfg.ReadFile(name)
+		buf, err := h.ReadFile(name)
 		switch {
 		case err != nil && errors.Is(err, os.ErrNotExist):
 			continue
 		case err != nil:
 			return err
 		}
-		return ParseBytes(buf, cfg, append(opts, WithName(name))...)
+		return ParseBytes(buf, h, append(opts, WithName(name))...)
 	}
 	return nil
 }
@@ -186,6 +187,10 @@ const (
 	ErrEndifWithoutMatchingIf Error = "$endif without matching $if"
 	// ErrUnknownModifier is the unknown modifier error.
 	ErrUnknownModifier Error = "unknown modifier"
+	// ErrUnknownVariable is the unknown variable error.
+	ErrUnknownVariable Error = "unknown variable"
+	// ErrUnknownCommand is the unknown command error.
+	ErrUnknownCommand Error = "unknown command"
 )
 
 // Error satisfies the error interface.
@@ -3,6 +3,7 @@ package inputrc
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os/user"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
 	"testing"
 	"unicode"
 )
@@ -129,6 +131,50 @@ Meta-Control-p: "f"
 	}
 }
 
+func TestParseErrors(t *testing.T) {
+	const str = `
+set editing-mode vim
+"\C-a": beginning-of-line
+"\C-b
+$endif
+"\C-e": end-of-line
+`
+	cfg := NewConfig()
+	p := New(WithName("test"))
+	err := p.Parse(strings.NewReader(str), cfg)
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	exp := []struct {
+		line int
+		err  error
+	}{
+		{2, ErrInvalidEditingMode},
+		{4, ErrBindMissingClosingQuote},
+		{5, ErrEndifWithoutMatchingIf},
+	}
+	errs := p.Errs()
+	if len(errs) != len(exp) {
+		t.Fatalf("expected %d errors, got %d: %v", len(exp), len(errs), err)
+	}
+	for i, test := range exp {
+		var perr *ParseError
+		if !errors.As(errs[i], &perr) {
+			t.Fatalf("test %d expected *ParseError, got: %T", i, errs[i])
+		}
+		if perr.Name != "test" || perr.Line != test.line || !errors.Is(perr, test.err) {
+			t.Errorf("test %d expected line %d: %v, got: %v", i, test.line, test.err, perr)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("test %d expected returned error to contain %v", i, test.err)
+		}
+	}
+	// lines after errors are still parsed
+	if bind := cfg.Binds["emacs"]["\x05"]; bind.Action != "end-of-line" {
+		t.Errorf("expected end-of-line to be bound, got: %q", bind.Action)
+	}
+}
+
 func TestDecodeKey(t *testing.T) {
 	tests := []struct {
 		s, exp string
@@ -138,6 +184,12 @@ func TestDecodeKey(t *testing.T) {
 		{"return", "\r"},
 		{"Meta-tab", "\x1b\t"},
 		{"Control-Meta-v", string(Encontrol(Enmeta('v')))},
+		{"Left", "\x1b[D"},
+		{"C-Right", "\x1b[1;5C"},
+		{"M-Left", "\x1b[1;3D"},
+		{"Shift-Up", "\x1b[1;2A"},
+		{"C-S-Left", "\x1b[1;6D"},
+		{"Control-Meta-End", "\x1b[1;7F"},
 	}
 	for idx, test := range tests {
 		r := []rune(test.s)
@@ -50,7 +50,9 @@ func New(opts ...Option) *Parser {
 }
 
 // Parse parses inputrc data from the reader, passing sets and binding keys to
-// h based on the configured options.
+// h based on the configured options. Errors returned by the handler are reported
+// as parse errors for the offending line. Unless halting on errors, the whole
+// stream is parsed and all errors are returned, joined: see also Errs().
 func (p *Parser) Parse(stream io.Reader, handler Handler) error {
 	var err error
 	// reset parser state
@@ -74,6 +76,7 @@ func (p *Parser) Parse(stream io.Reader, handler Handler) error {
 		}
 		// next
 		if err = p.next(handler, line, pos, end); err != nil {
+			err = p.lineError(line, pos, end, err)
 			p.errs = append(p.errs, err)
 			if p.haltOnErr {
 				return err
@@ -86,7 +89,23 @@ func (p *Parser) Parse(stream io.Reader, handler Handler) error {
 		return err
 	}
 
-	return nil
+	return errors.Join(p.errs...)
+}
+
+// lineError returns err as a parse error for the current line,
+// unless it is already one (eg. from an included file).
+func (p *Parser) lineError(line []rune, pos, end int, err error) error {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return err
+	}
+
+	return &ParseError{
+		Name: p.name,
+		Line: p.line,
+		Text: strings.TrimSpace(string(line[pos:end])),
+		Err:  err,
+	}
 }
 
 // Errs returns the parse errors encountered.
@@ -536,7 +555,7 @@ func decodeKey(seq []rune, pos, end int) (string, int, error) {
 	}
 
 	val := strings.ToLower(string(seq[start:pos]))
-	meta, control := false, false
+	meta, control, shift := false, false, false
 
 	for idx := strings.Index(val, "-"); idx != -1; idx = strings.Index(val, "-") {
 		switch val[:idx] {
@@ -544,6 +563,8 @@ func decodeKey(seq []rune, pos, end int) (string, int, error) {
 			control = true
 		case "meta", "m":
 			meta = true
+		case "shift", "s":
+			shift = true
 		default:
 			return "", idx, ErrUnknownModifier
 		}
@@ -551,6 +572,15 @@ func decodeKey(seq []rune, pos, end int) (string, int, error) {
 		val = val[idx+1:]
 	}
 
+	// Cursor keys, possibly with modifiers.
+	if final, found := cursorKeys[val]; found {
+		return cursorKey(final, shift, meta, control), pos, nil
+	}
+
+	if shift {
+		return "", pos, ErrUnknownModifier
+	}
+
 	var char rune
 
 	switch val {
@@ -589,6 +619,40 @@ func decodeKey(seq []rune, pos, end int) (string, int, error) {
 	return string(char), pos, nil
 }
 
+// cursorKeys are the final characters of the cursor key sequences, by name.
+var cursorKeys = map[string]rune{
+	"up":    'A',
+	"down":  'B',
+	"right": 'C',
+	"left":  'D',
+	"home":  'H',
+	"end":   'F',
+}
+
+// cursorKey returns the sequence sent by xterm-like terminals for a cursor key,
+// where any modifier is encoded as a parameter (eg. \e[1;5C for Control-Right).
+func cursorKey(final rune, shift, meta, control bool) string {
+	modifier := 1
+
+	if shift {
+		modifier++
+	}
+
+	if meta {
+		modifier += 2
+	}
+
+	if control {
+		modifier += 4
+	}
+
+	if modifier == 1 {
+		return string([]rune{Esc, '[', final})
+	}
+
+	return fmt.Sprintf("%c[1;%d%c", Esc, modifier, final)
+}
+
 /*
 // decodeRunes decodes runes.
 func decodeRunes(r []rune, i, end int) string {
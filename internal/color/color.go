@@ -5,6 +5,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Base text effects.
@@ -199,3 +200,17 @@ var re = regexp.MustCompile(ansi)
 func Strip(str string) string {
 	return re.ReplaceAllString(str, "")
 }
+
+// Positions returns the begin/end indexes of all ANSI escaped sequences in a string,
+// counted in runes (not bytes), and matching the sequences removed by Strip().
+func Positions(str string) [][]int {
+	indexes := re.FindAllStringIndex(str, -1)
+
+	for _, index := range indexes {
+		bpos, epos := index[0], index[1]
+		index[0] = utf8.RuneCountInString(str[:bpos])
+		index[1] = index[0] + utf8.RuneCountInString(str[bpos:epos])
+	}
+
+	return indexes
+}
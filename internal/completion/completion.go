@@ -33,6 +33,9 @@ type Values struct {
 	ListSep  map[string]string
 	Pad      map[string]bool
 	Escapes  map[string]bool
+	Continue map[string]string
+	Append   map[string]string
+	Loading  bool // More values are still being fetched.
 
 	// Initially this will be set to the part of the current word
 	// from the beginning of the word up to the position of the cursor.
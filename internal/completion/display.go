@@ -15,7 +15,7 @@ import (
 func Display(eng *Engine, maxRows int) {
 	eng.usedY = 0
 
-	defer fmt.Print(term.ClearScreenBelow)
+	defer term.Print(term.ClearScreenBelow)
 
 	// The completion engine might be inactive but still having
 	// a non-empty list of completions. This is on purpose, as
@@ -23,7 +23,7 @@ func Display(eng *Engine, maxRows int) {
 	// little more time. The engine itself is responsible for
 	// deleting those lists when it deems them useless.
 	if eng.Matches() == 0 || eng.skipDisplay {
-		fmt.Print(term.ClearLineAfter)
+		term.Print(term.ClearLineAfter)
 		return
 	}
 
@@ -38,7 +38,7 @@ func Display(eng *Engine, maxRows int) {
 	completions, eng.usedY = eng.cropCompletions(completions, maxRows)
 
 	if completions != "" {
-		fmt.Print(completions)
+		term.Print(completions)
 	}
 }
 
@@ -2,6 +2,7 @@ package completion
 
 import (
 	"regexp"
+	"strings"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/core"
@@ -79,7 +80,14 @@ func Init(eng *Engine, k *core.Keys, l *core.Line, cur *core.Cursor, s *core.Sel
 func (e *Engine) Generate(completions Values) {
 	e.prepare(completions)
 
+	// Values still being fetched are not final: even if
+	// there are none or only one yet, keep the menu open.
+	if completions.Loading {
+		return
+	}
+
 	if e.noCompletions() {
+		e.notifyNoMatches()
 		e.ClearMenu(true)
 	}
 
@@ -105,6 +113,39 @@ func (e *Engine) GenerateWith(completer Completer) {
 	e.Generate(e.cached())
 }
 
+// Update generates the completions again with the cached completer function,
+// when its values have changed since (eg. fetched asynchronously), keeping the
+// current candidate selected if it is still generated. Autocompletions are
+// updated with the autocompleter when no completer is cached.
+func (e *Engine) Update() {
+	completer := e.cached
+	if completer == nil && e.auto {
+		completer = e.autoCompleter
+	}
+
+	if completer == nil {
+		return
+	}
+
+	// Generate against the real input line.
+	selected := e.selected
+	if len(selected.Value) > 0 {
+		e.cancelCompletedLine()
+	}
+
+	e.hint.Reset()
+
+	if e.auto {
+		e.prepare(completer())
+	} else {
+		e.Generate(completer())
+	}
+
+	if len(selected.Value) > 0 && e.IsActive() && e.selectCandidate(selected) {
+		e.insertCandidate()
+	}
+}
+
 // SkipDisplay avoids printing completions below the
 // input line, but still enables cycling through them.
 func (e *Engine) SkipDisplay() {
@@ -127,6 +168,26 @@ func (e *Engine) Select(row, column int) {
 	// will influence the coordinates' offsets.
 	row, column = e.adjustCycleKeys(row, column)
 
+	e.moveSelection(grp, row, column)
+}
+
+// Move is like Select, but the offsets are always used as is, whatever the
+// keys that have triggered the current command: x moves the selector across
+// columns (continuing on the next/previous rows), and y across rows.
+func (e *Engine) Move(x, y int) {
+	grp := e.currentGroup()
+
+	if grp == nil || len(grp.rows) == 0 {
+		return
+	}
+
+	e.adjustSelectKeymap()
+	e.moveSelection(grp, x, y)
+}
+
+// moveSelection moves the selector of the current group, possibly going to
+// the next/previous group, and inserts the new candidate in the line.
+func (e *Engine) moveSelection(grp *group, row, column int) {
 	// If we already have an inserted candidate
 	// remove it before inserting the new one.
 	if len(e.selected.Value) > 0 {
@@ -274,6 +335,18 @@ func (e *Engine) IsInserting() bool {
 	return e.selected.Value != ""
 }
 
+// Continues returns true if the currently selected candidate ends with the
+// continuation marker of its group, meaning that completion should go on
+// once it has been inserted (like after a directory in a path completion).
+func (e *Engine) Continues() bool {
+	grp := e.currentGroup()
+	if grp == nil || len(e.selected.Value) == 0 || grp.continueOn == "" {
+		return false
+	}
+
+	return strings.HasSuffix(e.selected.Value, grp.continueOn)
+}
+
 // Matches returns the number of completion candidates
 // matching the current line/settings requirements.
 func (e *Engine) Matches() int {
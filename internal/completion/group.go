@@ -25,6 +25,9 @@ type group struct {
 	noSort            bool          // Don't sort completions
 	aliased           bool          // Are their aliased completions
 	preserveEscapes   bool          // Preserve escape sequences in the completion inserted values.
+	continueOn        string        // Suffix after which completion should continue (accept-and-complete).
+	appendSuffix      string        // Suffix inserted after a candidate accepted as unique.
+	uniqueSuffix      string        // Suffix inserted after a unique common prefix.
 	isCurrent         bool          // Currently cycling through this group, for highlighting choice
 	longestValue      int           // Used when display is map/list, for determining message width
 	longestDesc       int           // Used to know how much descriptions can use when there are aliases.
@@ -96,6 +99,34 @@ func (g *group) initOptions(eng *Engine, comps *Values, tag string, vals RawValu
 		g.preserveEscapes = comps.Escapes["*"]
 	}
 
+	// Suffix marking candidates after which to complete again.
+	g.continueOn = "/"
+
+	if marker, found := comps.Continue[tag]; found {
+		g.continueOn = marker
+	} else if marker, found := comps.Continue["*"]; found {
+		g.continueOn = marker
+	}
+
+	// Suffix inserted after unique candidates: always by the common prefix
+	// completion, and only if completion-append-space is on or if the suffix
+	// is given by the completions when accepting candidates.
+	g.uniqueSuffix = " "
+
+	if strings.HasSuffix(g.tag, "directories") {
+		g.uniqueSuffix = "/"
+	}
+
+	if eng.config.GetBool("completion-append-space") {
+		g.appendSuffix = g.uniqueSuffix
+	}
+
+	if suffix, found := comps.Append[tag]; found {
+		g.appendSuffix, g.uniqueSuffix = suffix, suffix
+	} else if suffix, found := comps.Append["*"]; found {
+		g.appendSuffix, g.uniqueSuffix = suffix, suffix
+	}
+
 	// Always list long commands when they have descriptions.
 	if strings.HasSuffix(g.tag, "commands") && len(vals) > 0 && vals[0].Description != "" {
 		g.list = true
@@ -547,9 +578,11 @@ func (g *group) moveSelector(x, y int) (done, next bool) {
 		}
 	}
 
-	// 4) If we are on the last column, go to next row or next group
+	// 4) If we are on the last column, go to next row or next group.
+	// When moving vertically, the last row might not be full: keep
+	// moving in the same direction until we find a valid cell.
 	if g.posX > len(g.rows[g.posY])-1 {
-		if g.aliased {
+		if g.aliased || y != 0 {
 			return g.findFirstCandidate(x, y)
 		}
 
@@ -0,0 +1,154 @@
+package completion
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// newTestGrid returns a non-aliased group of count candidates, arranged in a grid
+// of the given number of columns, along with the coordinates of each candidate.
+func newTestGrid(count, columns int) (*group, map[string][2]int) {
+	var vals RawValues
+
+	for i := 0; i < count; i++ {
+		vals = append(vals, Candidate{Value: fmt.Sprintf("c%d", i)})
+	}
+
+	rowCount := int(math.Ceil(float64(count) / float64(columns)))
+
+	grp := &group{
+		rows:         createGrid(vals, rowCount, columns),
+		columnsWidth: make([]int, columns),
+		posX:         -1,
+		posY:         -1,
+		maxY:         rowCount,
+		maxX:         columns,
+	}
+
+	cells := make(map[string][2]int)
+
+	for y, row := range grp.rows {
+		for x, val := range row {
+			cells[val.Value] = [2]int{x, y}
+		}
+	}
+
+	return grp, cells
+}
+
+// rowMajor and columnMajor return the values of a grid in the order
+// in which they should be selected when cycling horizontally/vertically.
+func rowMajor(grp *group) (order []string) {
+	for _, row := range grp.rows {
+		for _, val := range row {
+			order = append(order, val.Value)
+		}
+	}
+
+	return order
+}
+
+func columnMajor(grp *group) (order []string) {
+	for x := 0; x < grp.maxX; x++ {
+		for _, row := range grp.rows {
+			if x < len(row) {
+				order = append(order, row[x].Value)
+			}
+		}
+	}
+
+	return order
+}
+
+func TestGroup_moveSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int
+		columns int
+	}{
+		{name: "Single candidate", count: 1, columns: 3},
+		{name: "Full grid", count: 6, columns: 3},
+		{name: "Last row with one candidate", count: 7, columns: 3},
+		{name: "Last row with two candidates", count: 8, columns: 3},
+		{name: "Single incomplete row", count: 2, columns: 4},
+		{name: "Several incomplete columns", count: 9, columns: 4},
+		{name: "Single column", count: 5, columns: 1},
+	}
+
+	directions := []struct {
+		name  string
+		x, y  int
+		order func(*group) []string
+	}{
+		{name: "next", x: 1, order: rowMajor},
+		{name: "down", y: 1, order: columnMajor},
+	}
+
+	for _, test := range tests {
+		for _, dir := range directions {
+			t.Run(test.name+" "+dir.name, func(t *testing.T) {
+				grp, cells := newTestGrid(test.count, test.columns)
+				order := dir.order(grp)
+
+				// From each candidate, moving forward must select the next
+				// one in order, or finish with the group at the last one.
+				for i, value := range order {
+					grp.posX, grp.posY = cells[value][0], cells[value][1]
+
+					done, next := grp.moveSelector(dir.x, dir.y)
+
+					if i == len(order)-1 {
+						if !done || !next {
+							t.Errorf("moving %s from last %s: done=%v next=%v, want true true", dir.name, value, done, next)
+						}
+
+						continue
+					}
+
+					if done {
+						t.Fatalf("moving %s from %s: group done before the last candidate", dir.name, value)
+					}
+
+					if got := grp.selected().Value; got != order[i+1] {
+						t.Errorf("moving %s from %s: selected %s, want %s", dir.name, value, got, order[i+1])
+					}
+				}
+
+				// And backward must select the previous one.
+				for i := len(order) - 1; i >= 0; i-- {
+					value := order[i]
+					grp.posX, grp.posY = cells[value][0], cells[value][1]
+
+					done, next := grp.moveSelector(-dir.x, -dir.y)
+
+					if i == 0 {
+						if !done || next {
+							t.Errorf("moving back from first %s: done=%v next=%v, want true false", value, done, next)
+						}
+
+						continue
+					}
+
+					if done {
+						t.Fatalf("moving back from %s: group done before the first candidate", value)
+					}
+
+					if got := grp.selected().Value; got != order[i-1] {
+						t.Errorf("moving back from %s: selected %s, want %s", value, got, order[i-1])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestGroup_moveSelectorFirstUse(t *testing.T) {
+	for _, count := range []int{1, 4, 5} {
+		grp, _ := newTestGrid(count, 3)
+
+		if done, _ := grp.moveSelector(1, 0); done || grp.selected().Value != "c0" {
+			t.Errorf("%d candidates: first selection is %s (done=%v), want c0", count, grp.selected().Value, done)
+		}
+	}
+}
@@ -1,9 +1,11 @@
 package completion
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/keymap"
 	"github.com/alexj212/readline/internal/term"
 )
 
@@ -29,7 +31,7 @@ func (e *Engine) hintCompletions(comps Values) {
 	}
 
 	// If we don't have any completions, and no messages, let's say it.
-	if e.Matches() == 0 && hint == color.Dim+term.NewlineReturn && !e.auto {
+	if e.Matches() == 0 && hint == "" && !e.auto {
 		hint = e.hintNoMatches()
 	}
 
@@ -42,8 +44,17 @@ func (e *Engine) hintCompletions(comps Values) {
 	e.hint.Set(hint + color.Reset)
 }
 
+// hintNoMatches returns the completion-no-matches-hint text, if not empty,
+// followed by the tags of the groups (eg. when they are all empty).
 func (e *Engine) hintNoMatches() string {
-	noMatches := color.Dim + "no matching"
+	noMatches := e.config.GetString("completion-no-matches-hint")
+	if unquoted, err := strconv.Unquote(noMatches); err == nil {
+		noMatches = unquoted
+	}
+
+	if noMatches == "" {
+		return ""
+	}
 
 	var groups []string
 
@@ -56,9 +67,19 @@ func (e *Engine) hintNoMatches() string {
 	}
 
 	if len(groups) > 0 {
-		groupsStr := strings.Join(groups, ", ")
-		noMatches += "'" + groupsStr + "'"
+		noMatches += " (" + strings.Join(groups, ", ") + ")"
+	}
+
+	return color.Dim + noMatches
+}
+
+// notifyNoMatches rings the bell (according to the bell-style) when the user
+// asked for completions and there are none. This is not done when completing
+// as-you-type, or when searching (since an empty search is not an error).
+func (e *Engine) notifyNoMatches() {
+	if e.auto || e.keymap.Local() == keymap.Isearch {
+		return
 	}
 
-	return noMatches + " completions"
+	term.RingBell(e.config.GetString("bell-style"))
 }
@@ -1,7 +1,10 @@
 package completion
 
 import (
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/core"
@@ -64,8 +67,9 @@ func (e *Engine) TrimSuffix() {
 		return
 	}
 
-	// If the key is a space or matches the suffix matcher, cut the suffix.
-	if e.sm.Matches(string(key)) || unicode.IsSpace(key) {
+	// If the key is one of the autoremoval characters (spaces by default)
+	// or matches the suffix matcher, cut the suffix.
+	if e.sm.Matches(string(key)) || strings.ContainsRune(e.autoremoveChars(), key) {
 		e.cursor.Dec()
 		e.line.CutRune(e.cursor.Pos())
 	}
@@ -77,6 +81,19 @@ func (e *Engine) TrimSuffix() {
 	}
 }
 
+// autoremoveChars returns the characters which, when typed right after
+// an inserted completion, remove its suffix (in addition to the suffix
+// matchers of the completion itself).
+func (e *Engine) autoremoveChars() string {
+	chars := e.config.GetString("completion-autoremove-chars")
+
+	if unquoted, err := strconv.Unquote(chars); err == nil {
+		chars = unquoted
+	}
+
+	return chars
+}
+
 // refreshLine - Either insert the only candidate in the real line
 // and drop the current completion list, prefix, keymaps, etc, or
 // swap the formerly selected candidate with the new one.
@@ -115,10 +132,13 @@ func (e *Engine) acceptCandidate() {
 	completion := e.prepareSuffix()
 	e.inserted = []rune(completion)
 
-	// Remove the line prefix and insert the candidate.
-	e.cursor.Move(-1 * len(e.prefix))
-	e.line.Cut(e.cursor.Pos(), e.cursor.Pos()+len(e.prefix))
+	// Remove the line prefix (and the rest of the word
+	// after the cursor), and insert the candidate.
+	prefix, suffix := e.prefixLen(), e.suffixLen(e.line, e.cursor.Pos())
+	e.cursor.Move(-1 * prefix)
+	e.line.Cut(e.cursor.Pos(), e.cursor.Pos()+prefix+suffix)
 	e.cursor.InsertAt(e.inserted...)
+	e.appendSuffix(cur, cur.appendSuffix, e.selected.Value)
 
 	// And forget about this inserted completion.
 	e.inserted = make([]rune, 0)
@@ -126,6 +146,81 @@ func (e *Engine) acceptCandidate() {
 	e.suffix = ""
 }
 
+// InsertCommonPrefix generates completions with the given completer and, without
+// displaying them, inserts the longest prefix common to all candidates in the line.
+// A unique candidate is accepted and followed by its group suffix (a space by default,
+// or a slash for directories), whether completion-append-space is on or not.
+// Returns false if nothing could be inserted, that is, if there are no candidates
+// or if they are still ambiguous with the current prefix.
+func (e *Engine) InsertCommonPrefix(completer Completer) (inserted bool) {
+	if completer == nil {
+		return false
+	}
+
+	e.prepare(completer())
+	defer e.ClearMenu(true)
+
+	var values []string
+
+	for _, grp := range e.groups {
+		for _, row := range grp.rows {
+			for _, cand := range row {
+				values = append(values, cand.Value)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return false
+	}
+
+	common := []rune(commonPrefix(values, e.config.GetBool("completion-ignore-case")))
+	if len(common) <= e.prefixLen() {
+		return false
+	}
+
+	// Replace the prefix (and the rest of the word) with the common prefix.
+	prefix, suffix := e.prefixLen(), e.suffixLen(e.line, e.cursor.Pos())
+	e.cursor.Move(-1 * prefix)
+	e.line.Cut(e.cursor.Pos(), e.cursor.Pos()+prefix+suffix)
+	e.cursor.InsertAt(common...)
+
+	if string(common) == values[0] && uniqueValue(values) {
+		if grp := e.currentGroup(); grp != nil {
+			e.appendSuffix(grp, grp.uniqueSuffix, values[0])
+		}
+	}
+
+	return true
+}
+
+// appendSuffix inserts a group suffix after an accepted candidate, unless the
+// candidate already ends with it or with one of the group no-space suffixes.
+// The suffix is kept for autoremoval if the next key typed requires it.
+func (e *Engine) appendSuffix(grp *group, suffix, value string) {
+	if suffix == "" || strings.HasSuffix(value, suffix) || grp.noSpace.Matches(value) {
+		return
+	}
+
+	e.cursor.InsertAt([]rune(suffix)...)
+
+	e.sm = grp.noSpace
+	e.sm.Add([]rune(suffix)...)
+	e.sm.pos = e.cursor.Pos() - 1
+}
+
+// uniqueValue returns true if all values are identical:
+// aliased candidates might be different ones with the same value.
+func uniqueValue(values []string) bool {
+	for _, val := range values {
+		if val != values[0] {
+			return false
+		}
+	}
+
+	return true
+}
+
 // insertCandidate inserts a completion candidate into the virtual (completed) line.
 func (e *Engine) insertCandidate() {
 	grp := e.currentGroup()
@@ -135,7 +230,7 @@ func (e *Engine) insertCandidate() {
 
 	e.selected = grp.selected()
 
-	if len(e.selected.Value) < len(e.prefix) {
+	if utf8.RuneCountInString(e.selected.Value) < e.prefixLen() {
 		return
 	}
 
@@ -151,9 +246,12 @@ func (e *Engine) insertCandidate() {
 	e.compCursor = core.NewCursor(e.compLine)
 	e.compCursor.Set(e.cursor.Pos())
 
-	// Remove the line prefix and insert the candidate.
-	e.compCursor.Move(-1 * len(e.prefix))
-	e.compLine.Cut(e.compCursor.Pos(), e.compCursor.Pos()+len(e.prefix))
+	// Remove the line prefix and insert the candidate: the prefix
+	// boundary is computed against the real line, so that cycling
+	// through candidates only ever replaces the completed portion.
+	prefix, suffix := e.prefixLen(), e.suffixLen(e.compLine, e.compCursor.Pos())
+	e.compCursor.Move(-1 * prefix)
+	e.compLine.Cut(e.compCursor.Pos(), e.compCursor.Pos()+prefix+suffix)
 	e.compCursor.InsertAt(e.inserted...)
 }
 
@@ -166,11 +264,12 @@ func (e *Engine) prepareSuffix() (comp string) {
 	}
 
 	comp = e.selected.Value
-	prefix := len(e.prefix)
+	runes := utf8.RuneCountInString(comp)
+	prefix := e.prefixLen()
 
 	// When the completion has a size of 1, don't remove anything:
 	// stacked flags, for example, will never be inserted otherwise.
-	if len(comp) > 0 && len(comp[prefix:]) <= 1 {
+	if runes > 0 && runes-prefix <= 1 {
 		return
 	}
 
@@ -178,11 +277,34 @@ func (e *Engine) prepareSuffix() (comp string) {
 	// matcher for later: whatever the decision we take here will be identical
 	// to the one we take while removing suffix in "non-virtual comp" mode.
 	e.sm = cur.noSpace
-	e.sm.pos = e.cursor.Pos() + len(comp) - prefix - 1
+	e.sm.pos = e.cursor.Pos() + runes - prefix - 1
 
 	return comp
 }
 
+// prefixLen returns the length of the completion prefix, in runes, that is,
+// the number of characters before the cursor replaced by inserted candidates.
+func (e *Engine) prefixLen() int {
+	return utf8.RuneCountInString(e.prefix)
+}
+
+// suffixLen returns the length of the completion suffix, in runes, that is, the
+// number of characters after the cursor also replaced by inserted candidates.
+// If the suffix is not found at the cursor position in the line, it is ignored.
+func (e *Engine) suffixLen(line *core.Line, start int) int {
+	if e.suffix == "" || e.keymap.Local() == keymap.Isearch {
+		return 0
+	}
+
+	suffix := []rune(e.suffix)
+
+	if start < 0 || start+len(suffix) > line.Len() || string((*line)[start:start+len(suffix)]) != e.suffix {
+		return 0
+	}
+
+	return len(suffix)
+}
+
 func (e *Engine) cancelCompletedLine() {
 	// The completed line includes any currently selected
 	// candidate, just overwrite it with the normal line.
@@ -0,0 +1,58 @@
+package completion
+
+// State is a snapshot of the completions currently generated by the engine,
+// with the position of the selector in them.
+type State struct {
+	Groups []GroupState // The groups of candidates, in display order.
+	Group  int          // The group of the selected candidate, or -1 if none is.
+	Row    int          // The row of the selected candidate in its group, or -1.
+	Column int          // The column of the selected candidate in its row, or -1.
+}
+
+// GroupState is a group of candidates, as laid out in the completion menu.
+type GroupState struct {
+	Tag     string        // The group heading, if any.
+	Rows    [][]Candidate // The candidates, row by row.
+	Columns int           // The number of columns of the grid (the longest row).
+}
+
+// Selected returns the selected candidate, and false if none is.
+func (s *State) Selected() (Candidate, bool) {
+	if s.Group == -1 {
+		return Candidate{}, false
+	}
+
+	return s.Groups[s.Group].Rows[s.Row][s.Column], true
+}
+
+// State returns the completions currently generated and the position of the
+// selector in them, or nil if the engine is not active or there are no matches.
+func (e *Engine) State() *State {
+	if !e.IsActive() || e.Matches() == 0 {
+		return nil
+	}
+
+	state := &State{Group: -1, Row: -1, Column: -1}
+
+	for _, grp := range e.groups {
+		if len(grp.rows) == 0 {
+			continue
+		}
+
+		group := GroupState{Tag: grp.tag}
+
+		for _, row := range grp.rows {
+			group.Rows = append(group.Rows, append([]Candidate{}, row...))
+			group.Columns = max(group.Columns, len(row))
+		}
+
+		if grp.isCurrent && e.IsInserting() && grp.posY >= 0 && grp.posY < len(grp.rows) &&
+			grp.posX >= 0 && grp.posX < len(grp.rows[grp.posY]) {
+			state.Group, state.Row, state.Column = len(state.Groups), grp.posY, grp.posX
+		}
+
+		state.Groups = append(state.Groups, group)
+	}
+
+	return state
+}
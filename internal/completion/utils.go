@@ -46,8 +46,15 @@ func (e *Engine) generate(completions Values) {
 
 	// Apply the prefix to the completions, and filter out any
 	// completions that don't match, optionally ignoring case.
+	// Unless completing in word, the part of the word after
+	// the cursor must also be matched by the candidates.
+	prefix := e.prefix
+	if !e.config.GetBool("complete-in-word") {
+		prefix += e.suffix
+	}
+
 	matchCase := e.config.GetBool("completion-ignore-case")
-	completions.values = completions.values.FilterPrefix(e.prefix, !matchCase)
+	completions.values = completions.values.FilterPrefix(prefix, !matchCase)
 
 	// Classify, group together and initialize completions.
 	completions.values.EachTag(e.generateGroup(completions))
@@ -85,9 +92,20 @@ func (e *Engine) setPrefix(completions Values) {
 }
 
 func (e *Engine) setSuffix(completions Values) {
-	switch completions.SUFFIX {
-	case "":
+	switch {
+	case completions.SUFFIX == "" && completions.PREFIX != "":
+		// A prefix not taken from the line says nothing
+		// about the part of the word after the cursor.
+		e.suffix = ""
+
+	case completions.SUFFIX == "":
 		cpos := e.cursor.Pos()
+
+		// The cursor is not in a word, but after it.
+		if cpos == e.line.Len() || unicode.IsSpace(e.cursor.Char()) {
+			e.suffix = ""
+			return
+		}
 		_, epos := e.line.SelectBlankWord(cpos)
 
 		// Safety checks and adjustments.
@@ -312,6 +330,36 @@ func (e *Engine) adjustSelectKeymap() {
 	}
 }
 
+// selectCandidate moves the selector on the first candidate having the same
+// value as comp, in any group, and returns false if none does.
+func (e *Engine) selectCandidate(comp Candidate) bool {
+	for _, grp := range e.groups {
+		for y, row := range grp.rows {
+			for x, cand := range row {
+				value := cand.Value
+				if !grp.preserveEscapes {
+					value = color.Strip(value)
+				}
+
+				if value != comp.Value {
+					continue
+				}
+
+				for _, g := range e.groups {
+					g.isCurrent = false
+				}
+
+				grp.isCurrent = true
+				grp.posX, grp.posY = x, y
+
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // completionCount returns the number of completions for a given group,
 // as well as the number of real terminal lines it spans on, including
 // the group name if there is one.
@@ -502,3 +550,34 @@ func longest(vals []string, trimEscapes bool) int {
 
 	return length
 }
+
+// commonPrefix returns the longest prefix shared by all values.
+// When ignoring case, the prefix is taken from the first value.
+func commonPrefix(vals []string, ignoreCase bool) string {
+	if len(vals) == 0 {
+		return ""
+	}
+
+	common := []rune(vals[0])
+
+	for _, val := range vals[1:] {
+		runes := []rune(val)
+		if len(runes) < len(common) {
+			common = common[:len(runes)]
+		}
+
+		for i := range common {
+			same := runes[i] == common[i]
+			if ignoreCase {
+				same = unicode.ToLower(runes[i]) == unicode.ToLower(common[i])
+			}
+
+			if !same {
+				common = common[:i]
+				break
+			}
+		}
+	}
+
+	return string(common)
+}
@@ -9,6 +9,8 @@ import (
 	"reflect"
 	"syscall"
 	"unsafe"
+
+	"github.com/alexj212/readline/internal/term"
 )
 
 var (
@@ -163,6 +165,9 @@ func setConsoleCursorPosition(c *_COORD) error {
 
 // GetCursorPos returns the current cursor position on Windows.
 func (k *Keys) GetCursorPos() (x, y int) {
+	// The position depends on any buffered display output.
+	term.Flush()
+
 	t := new(_CONSOLE_SCREEN_BUFFER_INFO)
 	kernel.GetConsoleScreenBufferInfo(
 		stdout,
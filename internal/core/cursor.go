@@ -388,6 +388,28 @@ func CoordinatesCursor(cur *Cursor, indent int) (x, y int) {
 	return
 }
 
+// PosAtCoordinates is the reverse of CoordinatesCursor: it returns the position
+// in the line displayed at the given column (x) and row (y), relative to the
+// beginning of the line. Coordinates past the end of a row return the end of
+// this row, and those past the last one return the end of the line.
+// @indent -    Used to align all lines (except the first) together on a single column.
+func PosAtCoordinates(line *Line, indent, x, y int) (pos int) {
+	cur := NewCursor(line)
+
+	for i := 0; i <= line.Len(); i++ {
+		cur.Set(i)
+		curX, curY := CoordinatesCursor(cur, indent)
+
+		if curY > y || (curY == y && curX > x) {
+			break
+		}
+
+		pos = i
+	}
+
+	return pos
+}
+
 func (c *Cursor) moveLineDown() {
 	var cpos, begin int
 	begin = -1
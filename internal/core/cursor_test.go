@@ -1015,3 +1015,34 @@ func TestCursor_Coordinates(t *testing.T) {
 		})
 	}
 }
+
+func TestPosAtCoordinates(t *testing.T) {
+	indent := 2 // Assumes the prompt strings uses two columns
+
+	// Second line wraps after 78 characters (80 columns minus the indent).
+	wrapped := strings.Repeat("a", 78) + "bbbb"
+	line := Line("hello world\n" + wrapped + "\n")
+
+	tests := []struct {
+		name string
+		x, y int
+		want int
+	}{
+		{name: "Beginning of line", x: indent, y: 0, want: 0},
+		{name: "On the prompt", x: 0, y: 0, want: 0},
+		{name: "Middle of first line", x: indent + 6, y: 0, want: 6},
+		{name: "Past end of first line", x: 40, y: 0, want: 11},
+		{name: "Second line", x: indent + 3, y: 1, want: 15},
+		{name: "Wrapped row", x: 2, y: 2, want: 12 + 80},
+		{name: "Last empty line", x: 10, y: 3, want: line.Len()},
+		{name: "Below the line", x: 5, y: 10, want: line.Len()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := PosAtCoordinates(&line, indent, test.x, test.y); got != test.want {
+				t.Errorf("PosAtCoordinates() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
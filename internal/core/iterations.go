@@ -13,6 +13,7 @@ type Iterations struct {
 	times   string // Stores iteration value
 	active  bool   // Are we currently setting the iterations.
 	pending bool   // Has the last command been an iteration one (vi-pending style)
+	sticky  bool   // Keep the iterations across commands, until dropped.
 }
 
 // Add accepts a string to be converted as an integer representing
@@ -29,6 +30,11 @@ func (i *Iterations) Add(times string) {
 		return
 	}
 
+	// A new sticky argument replaces the previous one.
+	if i.sticky && !i.pending {
+		i.times = ""
+	}
+
 	i.active = true
 	i.pending = true
 
@@ -42,8 +48,8 @@ func (i *Iterations) Add(times string) {
 	}
 }
 
-// Get returns the number of iterations (possibly
-// negative), and resets the iterations to 1.
+// Get returns the number of iterations (possibly negative),
+// and resets the iterations to 1, unless they are sticky.
 func (i *Iterations) Get() int {
 	times, err := strconv.Atoi(i.times)
 
@@ -61,11 +67,23 @@ func (i *Iterations) Get() int {
 		times++
 	}
 
-	i.times = ""
+	if !i.sticky {
+		i.times = ""
+	}
 
 	return times
 }
 
+// Times returns the numeric argument as it has been typed (possibly
+// a negative sign), or an empty string if no argument is active.
+func (i *Iterations) Times() string {
+	if !i.active {
+		return ""
+	}
+
+	return i.times
+}
+
 // IsSet returns true if an iteration/numeric argument is active.
 func (i *Iterations) IsSet() bool {
 	return i.active
@@ -77,8 +95,28 @@ func (i *Iterations) IsPending() bool {
 	return i.pending
 }
 
-// Reset resets the iterations (drops them).
+// IsSticky returns true if the iterations are kept across commands.
+func (i *Iterations) IsSticky() bool {
+	return i.sticky
+}
+
+// SetSticky keeps the iterations across commands, even those resetting them,
+// or drops them if sticky is false and they were sticky until now.
+func (i *Iterations) SetSticky(sticky bool) {
+	if i.sticky && !sticky {
+		i.sticky = false
+		i.Reset()
+	}
+
+	i.sticky = sticky
+}
+
+// Reset resets the iterations (drops them), unless they are sticky.
 func (i *Iterations) Reset() {
+	if i.sticky {
+		return
+	}
+
 	i.times = ""
 	i.active = false
 	i.pending = false
@@ -87,6 +125,16 @@ func (i *Iterations) Reset() {
 // ResetPostRunIterations resets the iterations if the last command didn't set them.
 // If the reset operated on active iterations, this function returns true.
 func ResetPostRunIterations(iter *Iterations) (hint string) {
+	if iter.sticky {
+		iter.pending = false
+
+		if iter.times == "" {
+			return color.Dim + "(sticky arg)"
+		}
+
+		return color.Dim + fmt.Sprintf("(sticky arg: %s)", iter.times)
+	}
+
 	if iter.pending {
 		hint = color.Dim + fmt.Sprintf("(arg: %s)", iter.times)
 	}
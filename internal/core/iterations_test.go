@@ -270,3 +270,35 @@ func TestResetPostRunIterations(t *testing.T) {
 		})
 	}
 }
+
+func TestIterations_SetSticky(t *testing.T) {
+	iter := &Iterations{}
+	iter.Add("5")
+	iter.SetSticky(true)
+
+	for i := 0; i < 3; i++ {
+		if hint := ResetPostRunIterations(iter); hint != color.Dim+"(sticky arg: 5)" {
+			t.Errorf("ResetPostRunIterations() = %q, want the sticky argument", hint)
+		}
+
+		iter.Reset()
+
+		if got := iter.Get(); got != 5 || !iter.IsSet() {
+			t.Errorf("Iterations.Get() = %v (set: %v), want 5 kept", got, iter.IsSet())
+		}
+	}
+
+	// A new argument replaces the sticky one.
+	iter.Add("1")
+	iter.Add("2")
+
+	if got := iter.Get(); got != 12 {
+		t.Errorf("Iterations.Get() = %v, want 12", got)
+	}
+
+	iter.SetSticky(false)
+
+	if got := iter.Get(); got != 1 || iter.IsSet() || iter.IsSticky() {
+		t.Errorf("Iterations.Get() = %v (set: %v), want dropped", got, iter.IsSet())
+	}
+}
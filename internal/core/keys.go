@@ -1,11 +1,14 @@
 package core
 
 import (
+	"bytes"
 	"errors"
 	"io"
 	"os"
 	"regexp"
+	"strconv"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/strutil"
@@ -20,19 +23,43 @@ const (
 // custom io.Readers, such as the one used on Windows.
 var Stdin io.ReadCloser = os.Stdin
 
-var rxRcvCursorPos = regexp.MustCompile(`\x1b\[([0-9]+);([0-9]+)R`)
+var (
+	rxRcvCursorPos  = regexp.MustCompile(`\x1b\[([0-9]+);([0-9]+)R`)
+	rxRcvFocusEvent = regexp.MustCompile(`\x1b\[[IO]`)
+	rxRcvMouseEvent = regexp.MustCompile(`\x1b\[<([0-9]+);([0-9]+);([0-9]+)([Mm])`)
+)
+
+// Mouse buttons, as reported by terminals in SGR mouse mode.
+const (
+	MouseLeft      = 0
+	MouseMiddle    = 1
+	MouseRight     = 2
+	MouseWheelUp   = 64
+	MouseWheelDown = 65
+)
+
+// MouseEvent is a mouse button press or release reported by the terminal,
+// with the column and row (1-based, from the top-left of the terminal).
+type MouseEvent struct {
+	Button  int
+	X, Y    int
+	Pressed bool
+}
 
 // Keys is used read, manage and use keys input by the shell user.
 type Keys struct {
-	buf       []byte      // Keys read and waiting to be used.
-	matched   []rune      // Keys that have been successfully matched against a bind.
-	macroKeys []rune      // Keys that have been fed by a macro.
-	mustWait  bool        // Keys are in the stack, but we must still read stdin.
-	waiting   bool        // Currently waiting for keys on stdin.
-	reading   bool        // Currently reading keys out of the main loop.
-	keysOnce  chan []byte // Passing keys from the main routine.
-	cursor    chan []byte // Cursor coordinates has been read on stdin.
-	resize    chan bool   // Resize events on Windows are sent on stdin.
+	buf       []byte       // Keys read and waiting to be used.
+	matched   []rune       // Keys that have been successfully matched against a bind.
+	macroKeys []rune       // Keys that have been fed by a macro.
+	mustWait  bool         // Keys are in the stack, but we must still read stdin.
+	waiting   bool         // Currently waiting for keys on stdin.
+	reading   bool         // Currently reading keys out of the main loop.
+	keysOnce  chan []byte  // Passing keys from the main routine.
+	cursor    chan []byte  // Cursor coordinates has been read on stdin.
+	resize    chan bool    // Resize events on Windows are sent on stdin.
+	focus     func(bool)   // Called on terminal focus in/out events.
+	mouse     []MouseEvent // Mouse events read on stdin, not yet handled.
+	input     []byte       // Buffer reused for reading stdin.
 
 	cfg   *inputrc.Config // Configuration file used for meta key settings
 	mutex sync.RWMutex    // Concurrency safety
@@ -43,18 +70,15 @@ type Keys struct {
 func WaitAvailableKeys(keys *Keys, cfg *inputrc.Config) {
 	keys.cfg = cfg
 
-	if len(keys.buf) > 0 && !keys.mustWait {
-		return
-	}
-
-	// The macro engine might have fed some keys
-	if len(keys.macroKeys) > 0 {
+	if AvailableKeys(keys) {
 		return
 	}
 
 	keys.mutex.Lock()
 	keys.waiting = true
-	keys.cursor = make(chan []byte)
+	if keys.cursor == nil {
+		keys.cursor = make(chan []byte)
+	}
 	keys.mutex.Unlock()
 
 	defer func() {
@@ -72,19 +96,20 @@ func WaitAvailableKeys(keys *Keys, cfg *inputrc.Config) {
 			return
 		}
 
-		if len(keyBuf) == 0 {
+		// Mouse events are not keys, but must be handled.
+		if len(keyBuf) == 0 && len(keys.mouse) == 0 {
 			continue
 		}
 
 		switch {
 		case keys.reading:
-			keys.keysOnce <- keyBuf
+			keys.keysOnce <- append([]byte(nil), keyBuf...)
 			continue
 
 		default:
 			// When convert-meta is on, any meta-prefixed bind should
 			// be stripped and replaced with an escape meta instead.
-			if keys.cfg != nil && keys.cfg.GetBool("convert-meta") {
+			if keys.cfg != nil && !isASCII(keyBuf) && keys.cfg.GetBool("convert-meta") {
 				keyBuf = []byte(strutil.ConvertMeta([]rune(string(keyBuf))))
 			}
 
@@ -97,6 +122,17 @@ func WaitAvailableKeys(keys *Keys, cfg *inputrc.Config) {
 	}
 }
 
+// AvailableKeys returns true if keys are available in the stack without reading
+// standard input: either keys not yet used, or keys fed by the macro engine.
+func AvailableKeys(keys *Keys) bool {
+	if len(keys.buf) > 0 && !keys.mustWait {
+		return true
+	}
+
+	// The macro engine might have fed some keys
+	return len(keys.macroKeys) > 0
+}
+
 // PopKey is used to pop a key off the key stack without
 // yet marking this key as having matched a bind command.
 func PopKey(keys *Keys) (key byte, empty bool) {
@@ -201,6 +237,47 @@ func FlushUsed(keys *Keys) {
 	defer keys.mutex.Unlock()
 }
 
+// PendingKeys returns all keys in the stack that have not yet been dispatched
+// to a command: those read on stdin first, then those fed by macros, if any.
+func PendingKeys(keys *Keys) []rune {
+	keys.mutex.RLock()
+	defer keys.mutex.RUnlock()
+
+	pending := []rune(string(keys.buf))
+
+	return append(pending, keys.macroKeys...)
+}
+
+// DropPending removes all keys in the stack that have not yet been dispatched.
+func DropPending(keys *Keys) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	keys.buf = nil
+	keys.macroKeys = nil
+	keys.mustWait = false
+}
+
+// SetFocusHandler sets the function called when the terminal reports focus in
+// (true) or focus out (false) events. Those events are always stripped from the
+// input keys, whether or not a handler is set. Passing nil removes the handler.
+func SetFocusHandler(keys *Keys, handler func(focused bool)) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	keys.focus = handler
+}
+
+// PopMouseEvents returns the mouse events read on stdin since the last call.
+func PopMouseEvents(keys *Keys) (events []MouseEvent) {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	events, keys.mouse = keys.mouse, nil
+
+	return events
+}
+
 // ReadKey reads keys from stdin like Read(), but immediately
 // returns them instead of storing them in the stack, along with
 // an indication on whether this key is an escape/abort one.
@@ -223,10 +300,14 @@ func (k *Keys) ReadKey() (key rune, isAbort bool) {
 
 	case k.waiting:
 		buf := <-k.keysOnce
-		key = []rune(string(buf))[0]
+		key, _ = utf8.DecodeRune(buf)
 	default:
 		buf, _ := k.readInputFiltered()
-		key = []rune(string(buf))[0]
+		if len(buf) == 0 {
+			return inputrc.Esc, true
+		}
+
+		key, _ = utf8.DecodeRune(buf)
 	}
 
 	// Always mark those keys as matched, so that
@@ -287,14 +368,128 @@ func (k *Keys) Feed(begin bool, keys ...rune) {
 	}
 }
 
+// bracketedPasteStart is sent by the terminal before pasted text,
+// when the enable-bracketed-paste option is on.
+const bracketedPasteStart = "\x1b[200~"
+
+// bracketedPasteEnd is sent by the terminal after pasted text.
+const bracketedPasteEnd = "\x1b[201~"
+
+// normalizeLineEndings replaces the line endings of pasted text with a single
+// Return key, so that each of them inserts a single newline in multiline mode
+// and blank lines are kept: in keys read at once, \r\n pairs are replaced, while
+// a lone \n is kept, since it might be a C-j typed quickly after other keys.
+// In a bracketed paste, all of them (\r\n, or \n alone) are replaced, while
+// other sequences starting with an escape (like Alt-Enter) are left unchanged.
+func normalizeLineEndings(keys []byte) []byte {
+	if bytes.IndexByte(keys, '\n') == -1 {
+		return keys
+	}
+
+	pasted := bytes.HasPrefix(keys, []byte(bracketedPasteStart))
+	if !pasted && keys[0] == byte(inputrc.Esc) {
+		return keys
+	}
+
+	normalized := make([]byte, 0, len(keys))
+
+	for i := 0; i < len(keys); i++ {
+		switch {
+		case keys[i] == '\r' && i+1 < len(keys) && keys[i+1] == '\n':
+			normalized = append(normalized, '\r')
+			i++
+		case keys[i] == '\n' && pasted:
+			normalized = append(normalized, '\r')
+		default:
+			normalized = append(normalized, keys[i])
+		}
+
+		if pasted && bytes.HasPrefix(keys[i+1:], []byte(bracketedPasteEnd)) {
+			pasted = false
+		}
+	}
+
+	return normalized
+}
+
+// readBuffer returns the buffer in which keys are read from stdin. Since it is
+// reused for each read, keys passed around from it must be copied if retained.
+func (k *Keys) readBuffer() []byte {
+	if k.input == nil {
+		k.input = make([]byte, keyScanBufSize)
+	}
+
+	return k.input
+}
+
+// extractCursorPos returns the last cursor position response found in
+// the keys (copied, since the keys are in the read buffer), and the keys
+// stripped from all responses.
 func (k *Keys) extractCursorPos(keys []byte) (cursor, remain []byte) {
 	if !rxRcvCursorPos.Match(keys) {
 		return cursor, keys
 	}
 
 	allCursors := rxRcvCursorPos.FindAll(keys, -1)
-	cursor = allCursors[len(allCursors)-1]
+	cursor = append([]byte(nil), allCursors[len(allCursors)-1]...)
 	remain = rxRcvCursorPos.ReplaceAll(keys, nil)
 
 	return
 }
+
+// isASCII returns true if the keys contain no multibyte or meta characters.
+func isASCII(keys []byte) bool {
+	for _, key := range keys {
+		if key >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+// extractFocusEvents strips terminal focus in/out events from the keys,
+// and notifies the focus handler of each of them, if there is one.
+func (k *Keys) extractFocusEvents(keys []byte) (remain []byte) {
+	if !rxRcvFocusEvent.Match(keys) {
+		return keys
+	}
+
+	k.mutex.RLock()
+	handler := k.focus
+	k.mutex.RUnlock()
+
+	if handler != nil {
+		for _, event := range rxRcvFocusEvent.FindAll(keys, -1) {
+			handler(event[2] == 'I')
+		}
+	}
+
+	return rxRcvFocusEvent.ReplaceAll(keys, nil)
+}
+
+// extractMouseEvents strips SGR mouse events from the keys,
+// and stores them until they are popped by the shell.
+func (k *Keys) extractMouseEvents(keys []byte) (remain []byte) {
+	if !rxRcvMouseEvent.Match(keys) {
+		return keys
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	for _, match := range rxRcvMouseEvent.FindAllSubmatch(keys, -1) {
+		button, _ := strconv.Atoi(string(match[1]))
+		x, _ := strconv.Atoi(string(match[2]))
+		y, _ := strconv.Atoi(string(match[3]))
+
+		k.mouse = append(k.mouse, MouseEvent{
+			Button:  button,
+			X:       x,
+			Y:       y,
+			Pressed: match[4][0] == 'M',
+		})
+	}
+
+	return rxRcvMouseEvent.ReplaceAll(keys, nil)
+}
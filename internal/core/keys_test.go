@@ -0,0 +1,150 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/alexj212/readline/inputrc"
+)
+
+func TestKeys_extractFocusEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		handler    bool
+		wantKeys   string
+		wantEvents []bool
+	}{
+		{name: "No events", input: "abc", handler: true, wantKeys: "abc"},
+		{name: "Focus in", input: "\x1b[I", handler: true, wantKeys: "", wantEvents: []bool{true}},
+		{name: "Events within keys", input: "a\x1b[Ob\x1b[Ic", handler: true, wantKeys: "abc", wantEvents: []bool{false, true}},
+		{name: "Other sequences", input: "\x1b[A\x1b[1;5C", handler: true, wantKeys: "\x1b[A\x1b[1;5C"},
+		{name: "No handler", input: "a\x1b[Ib", handler: false, wantKeys: "ab"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := new(Keys)
+
+			var events []bool
+
+			if test.handler {
+				SetFocusHandler(keys, func(focused bool) {
+					events = append(events, focused)
+				})
+			}
+
+			got := keys.extractFocusEvents([]byte(test.input))
+
+			if string(got) != test.wantKeys {
+				t.Errorf("keys = %q, want %q", got, test.wantKeys)
+			}
+
+			if !reflect.DeepEqual(events, test.wantEvents) {
+				t.Errorf("events = %v, want %v", events, test.wantEvents)
+			}
+		})
+	}
+}
+
+func TestKeys_normalizeLineEndings(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "CRLF", input: "a\r\nb\r\n\r\nc", want: "a\rb\r\rc"},
+		{name: "CR only", input: "a\rb\r\rc", want: "a\rb\r\rc"},
+		{name: "LF only", input: "a\nb\n\nc", want: "a\nb\n\nc"},
+		{name: "Mixed", input: "a\r\nb\n\rc", want: "a\rb\n\rc"},
+		{name: "Single key", input: "\n", want: "\n"},
+		{name: "Keys typed quickly", input: "x\n", want: "x\n"},
+		{name: "Escape sequence", input: "\x1b\n", want: "\x1b\n"},
+		{name: "Escape sequence CRLF", input: "\x1b\r\n", want: "\x1b\r\n"},
+		{name: "Bracketed paste", input: "\x1b[200~a\r\nb\x1b[201~", want: "\x1b[200~a\rb\x1b[201~"},
+		{name: "Bracketed paste LF only", input: "\x1b[200~a\nb\n\nc\x1b[201~", want: "\x1b[200~a\rb\r\rc\x1b[201~"},
+		{name: "Bracketed paste mixed", input: "\x1b[200~a\r\nb\n\rc\r\r\nd\x1b[201~", want: "\x1b[200~a\rb\r\rc\r\rd\x1b[201~"},
+		{name: "Keys after a bracketed paste", input: "\x1b[200~a\nb\x1b[201~\n", want: "\x1b[200~a\rb\x1b[201~\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := string(normalizeLineEndings([]byte(test.input))); got != test.want {
+				t.Errorf("normalizeLineEndings() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeys_extractMouseEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantKeys   string
+		wantEvents []MouseEvent
+	}{
+		{name: "No events", input: "abc", wantKeys: "abc"},
+		{
+			name: "Left click", input: "\x1b[<0;12;3M\x1b[<0;12;3m", wantKeys: "",
+			wantEvents: []MouseEvent{{Button: MouseLeft, X: 12, Y: 3, Pressed: true}, {Button: MouseLeft, X: 12, Y: 3}},
+		},
+		{
+			name: "Events within keys", input: "a\x1b[<64;1;1Mb", wantKeys: "ab",
+			wantEvents: []MouseEvent{{Button: MouseWheelUp, X: 1, Y: 1, Pressed: true}},
+		},
+		{name: "Other sequences", input: "\x1b[A\x1b[1;5C", wantKeys: "\x1b[A\x1b[1;5C"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := new(Keys)
+
+			got := keys.extractMouseEvents([]byte(test.input))
+
+			if string(got) != test.wantKeys {
+				t.Errorf("keys = %q, want %q", got, test.wantKeys)
+			}
+
+			if events := PopMouseEvents(keys); !reflect.DeepEqual(events, test.wantEvents) {
+				t.Errorf("events = %v, want %v", events, test.wantEvents)
+			}
+		})
+	}
+}
+
+// typingReader returns one key per read, like a user typing.
+type typingReader struct {
+	keys []byte
+	pos  int
+}
+
+func (r *typingReader) Read(p []byte) (int, error) {
+	p[0] = r.keys[r.pos%len(r.keys)]
+	r.pos++
+
+	return 1, nil
+}
+
+func (r *typingReader) Close() error { return nil }
+
+// BenchmarkKeys_typing reads, dispatches and flushes keys one by one, like the shell
+// loop does when the user is typing. Allocations per key should remain minimal.
+func BenchmarkKeys_typing(b *testing.B) {
+	stdin := Stdin
+	Stdin = &typingReader{keys: []byte("echo hello wörld")}
+
+	defer func() { Stdin = stdin }()
+
+	keys := new(Keys)
+	cfg := inputrc.NewDefaultConfig()
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		WaitAvailableKeys(keys, cfg)
+
+		key, _ := PopKey(keys)
+		MatchedKeys(keys, []byte{key})
+		FlushUsed(keys)
+	}
+}
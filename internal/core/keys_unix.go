@@ -4,10 +4,11 @@ package core
 
 import (
 	"errors"
-	"fmt"
 	"io"
 	"os"
 	"strconv"
+
+	"github.com/alexj212/readline/internal/term"
 )
 
 // GetCursorPos returns the current cursor position in the terminal.
@@ -21,9 +22,11 @@ func (k *Keys) GetCursorPos() (x, y int) {
 	var cursor []byte
 	var match [][]string
 
-	// Echo the query and wait for the main key
-	// reading routine to send us the response back.
-	fmt.Print("\x1b[6n")
+	// Echo the query (after any buffered display output, since the
+	// position depends on it) and wait for the main key reading
+	// routine to send us the response back.
+	term.Flush()
+	term.Print("\x1b[6n")
 
 	// In order not to get stuck with an input that might be user-one
 	// (like when the user typed before the shell is fully started, and yet not having
@@ -34,11 +37,13 @@ func (k *Keys) GetCursorPos() (x, y int) {
 		case k.waiting, k.reading:
 			cursor = <-k.cursor
 		default:
-			buf := make([]byte, keyScanBufSize)
+			buf := k.readBuffer()
 
-			read, err := os.Stdin.Read(buf)
+			// Without any input (like when no terminal is used),
+			// there is no answer to wait for, nor anything to report.
+			read, err := Stdin.Read(buf)
 			if err != nil {
-				return disable()
+				return -1, -1
 			}
 
 			cursor = buf[:read]
@@ -87,7 +92,7 @@ func (k *Keys) readInputFiltered() (keys []byte, err error) {
 	// Start reading from os.Stdin in the background.
 	// We will either read keys from user, or an EOF
 	// send by ourselves, because we pause reading.
-	buf := make([]byte, keyScanBufSize)
+	buf := k.readBuffer()
 
 	read, err := Stdin.Read(buf)
 	if err != nil && errors.Is(err, io.EOF) {
@@ -102,5 +107,12 @@ func (k *Keys) readInputFiltered() (keys []byte, err error) {
 		k.cursor <- cursor
 	}
 
+	// Same for terminal focus and mouse events, if enabled.
+	keys = k.extractFocusEvents(keys)
+	keys = k.extractMouseEvents(keys)
+
+	// Pasted text may use any line endings.
+	keys = normalizeLineEndings(keys)
+
 	return keys, nil
 }
@@ -69,7 +69,7 @@ func (k *Keys) readInputFiltered() (keys []byte, err error) {
 		// Start reading from os.Stdin in the background.
 		// We will either read keys from user, or an EOF
 		// send by ourselves, because we pause reading.
-		buf := make([]byte, keyScanBufSize)
+		buf := k.readBuffer()
 
 		read, err := Stdin.Read(buf)
 		if err != nil && errors.Is(err, io.EOF) {
@@ -93,6 +93,13 @@ func (k *Keys) readInputFiltered() (keys []byte, err error) {
 			k.cursor <- cursor
 		}
 
+		// Same for terminal focus and mouse events, if enabled.
+		keys = k.extractFocusEvents(keys)
+		keys = k.extractMouseEvents(keys)
+
+		// Pasted text may use any line endings.
+		keys = normalizeLineEndings(keys)
+
 		return keys, nil
 	}
 }
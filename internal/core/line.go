@@ -1,11 +1,9 @@
 package core
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 	"unicode"
-	"unicode/utf8"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/color"
@@ -22,10 +20,25 @@ type Tokenizer func(cursorPos int) (split []string, index int, newPos int)
 // split itself with tokenizers, and displaying itself.
 type Line []rune
 
-// Set replaces the line contents altogether with a new slice of characters.
+// Lines of at least gapThreshold runes are edited in place: their backing array
+// keeps a gap of free runes after the line contents, so that edits only move the
+// runes after the edited range, and reallocate only when the gap is exhausted.
+// Smaller lines are simple slices reallocated on each edit, which is cheaper.
+// Since large lines runes are modified in place, they are never shared: their
+// arrays are only ever allocated by the line itself, either when set, or when
+// growing past the threshold.
+var gapThreshold = 4096
+
+// Set replaces the line contents altogether with a new slice of characters,
+// which are copied, so that the line never shares the runes passed to it.
 // If no characters are passed, the line is thus made empty.
 func (l *Line) Set(chars ...rune) {
-	*l = chars
+	size := len(chars)
+	if size >= gapThreshold {
+		size = withGap(size)
+	}
+
+	*l = append(make([]rune, 0, size), chars...)
 }
 
 // Insert inserts one or more runes at the given position.
@@ -50,14 +63,11 @@ func (l *Line) Insert(pos int, chars ...rune) {
 
 	switch {
 	case l.Len() == 0:
-		*l = chars
-	case pos < l.Len():
-		forward := string((*l)[pos:])
-		cut := string(append((*l)[:pos], chars...))
-		cut += forward
-		*l = []rune(cut)
-	case pos == l.Len():
+		l.Set(chars...)
+	case pos == l.Len() && (l.Len() >= gapThreshold || l.Len()+len(chars) < gapThreshold):
 		*l = append(*l, chars...)
+	default:
+		l.replace(pos, pos, chars)
 	}
 }
 
@@ -74,17 +84,29 @@ func (l *Line) InsertBetween(bpos, epos int, chars ...rune) {
 	switch {
 	case epos == -1:
 		l.Insert(bpos, chars...)
-	case epos == l.Len():
-		cut := string((*l)[:bpos]) + string(chars)
-		*l = []rune(cut)
 	default:
-		forward := string((*l)[epos:])
-		cut := string(append((*l)[:bpos], chars...))
-		cut += forward
-		*l = []rune(cut)
+		l.replace(bpos, epos, chars)
 	}
 }
 
+// Replace replaces the runes between a beginning and end position on the line
+// with the given ones, which may be more or less numerous. Positions are checked
+// like with Cut(): out of bounds ones are brought back onto the line, reversed
+// ones are reordered, and an end position of -1 replaces until the end of line.
+// The new line is built in a single allocation, without converting its parts.
+func (l *Line) Replace(bpos, epos int, chars ...rune) {
+	bpos, epos, valid := l.checkRange(bpos, epos)
+	if !valid {
+		return
+	}
+
+	if epos == -1 || epos > l.Len() {
+		epos = l.Len()
+	}
+
+	l.replace(min(bpos, epos), epos, chars)
+}
+
 // Cut deletes a slice of runes between a beginning and end position on the line.
 // If the begin/end pos is negative/greater than the line, all runes located on
 // valid indexes in the given range are removed.
@@ -96,13 +118,9 @@ func (l *Line) Cut(bpos, epos int) {
 
 	switch epos {
 	case -1:
-		cut := string((*l)[:bpos])
-		*l = []rune(cut)
+		l.replace(bpos, l.Len(), nil)
 	default:
-		forward := string((*l)[epos:])
-		cut := string((*l)[:bpos])
-		cut += forward
-		*l = []rune(cut)
+		l.replace(bpos, epos, nil)
 	}
 }
 
@@ -119,18 +137,64 @@ func (l *Line) CutRune(pos int) {
 	case pos == l.Len():
 		*l = (*l)[:pos-1]
 	default:
-		forward := string((*l)[pos+1:])
-		cut := string((*l)[:pos])
-		cut += forward
-		*l = []rune(cut)
+		l.replace(pos, pos+1, nil)
 	}
 }
 
 // Len returns the length of the line, as given by ut8.RuneCount.
 // This should NOT confused with the length of the line in terms of
 // how many terminal columns its printed representation will take.
+// Since each rune of the line is encoded as exactly one UTF-8 rune,
+// this is the length of the line slice itself.
 func (l *Line) Len() int {
-	return utf8.RuneCountInString(string(*l))
+	return len(*l)
+}
+
+// replace builds the line with the runes between (valid) bpos and epos replaced.
+// Small lines are reallocated, since other lines might share their runes, while
+// large ones are edited in place (see gapThreshold).
+func (l *Line) replace(bpos, epos int, chars []rune) {
+	length := l.Len() - (epos - bpos) + len(chars)
+
+	// Only lines already large are guaranteed not to share their runes,
+	// and lines growing large get an array of their own, with a gap.
+	if l.Len() < gapThreshold {
+		size := length
+		if size >= gapThreshold {
+			size = withGap(size)
+		}
+
+		line := make([]rune, 0, size)
+		line = append(line, (*l)[:bpos]...)
+		line = append(line, chars...)
+		line = append(line, (*l)[epos:]...)
+
+		*l = line
+
+		return
+	}
+
+	// The replacement might come from the line itself.
+	chars = append([]rune(nil), chars...)
+
+	// Reallocate with a new gap if the current one is too small.
+	if cap(*l) < length {
+		line := make([]rune, l.Len(), withGap(length))
+		copy(line, *l)
+		*l = line
+	}
+
+	// Move the runes after the edited range, and insert.
+	line := (*l)[:max(l.Len(), length)]
+	copy(line[bpos+len(chars):], (*l)[epos:])
+	copy(line[bpos:], chars)
+
+	*l = line[:length]
+}
+
+// withGap returns the capacity of a large line array for a given length.
+func withGap(length int) int {
+	return length + length/2
 }
 
 // SelectWord returns the begin and end index positions of a word
@@ -337,13 +401,13 @@ func DisplayLine(l *Line, indent int) {
 
 		// Clear everything after each line, except the last.
 		if num < len(lines)-1 {
-			if len(line)+indent < term.GetWidth() {
+			if strutil.RealLength(line)+indent < term.GetWidth() {
 				line += term.ClearLineAfter
 			}
 			line += term.NewlineReturn
 		}
 
-		fmt.Print(line)
+		term.Print(line)
 	}
 }
 
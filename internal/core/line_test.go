@@ -1,7 +1,9 @@
 package core
 
 import (
+	"math/rand"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/alexj212/readline/internal/term"
@@ -224,6 +226,181 @@ func TestLine_CutRune(t *testing.T) {
 	}
 }
 
+func TestLine_Replace(t *testing.T) {
+	tests := []struct {
+		name  string
+		line  string
+		bpos  int
+		epos  int
+		chars string
+		want  string
+	}{
+		{name: "Same length", line: "echo hello world", bpos: 5, epos: 10, chars: "howdy", want: "echo howdy world"},
+		{name: "Longer replacement", line: "echo hello world", bpos: 5, epos: 10, chars: "good morning", want: "echo good morning world"},
+		{name: "Shorter replacement", line: "echo hello world", bpos: 5, epos: 11, chars: "", want: "echo world"},
+		{name: "Empty range inserts", line: "echo world", bpos: 5, epos: 5, chars: "hello ", want: "echo hello world"},
+		{name: "Reversed range", line: "echo hello world", bpos: 10, epos: 5, chars: "hi", want: "echo hi world"},
+		{name: "Until end of line", line: "echo hello world", bpos: 5, epos: -1, chars: "bye", want: "echo bye"},
+		{name: "Out of bounds", line: "echo hello", bpos: -2, epos: 20, chars: "ls", want: "ls"},
+		{name: "Invalid range", line: "echo hello", bpos: -1, epos: -1, chars: "ls", want: "echo hello"},
+		{name: "Unicode", line: "éçho wörld", bpos: 5, epos: 10, chars: "mönde", want: "éçho mönde"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := Line(test.line)
+			shared := line
+
+			line.Replace(test.bpos, test.epos, []rune(test.chars)...)
+
+			if string(line) != test.want {
+				t.Errorf("Line: '%s', wanted '%s'", string(line), test.want)
+			}
+
+			// Other lines sharing the same runes are unchanged.
+			if string(shared) != test.line {
+				t.Errorf("shared line: '%s', wanted '%s'", string(shared), test.line)
+			}
+		})
+	}
+}
+
+// BenchmarkLine_edit edits a large buffer with each mutation method, in its middle.
+func BenchmarkLine_edit(b *testing.B) {
+	large := []rune(strings.Repeat("echo hello world; ", 600))
+	middle := len(large) / 2
+
+	edits := []struct {
+		name string
+		edit func(l *Line)
+	}{
+		{name: "Insert", edit: func(l *Line) { l.Insert(middle, 'a', 'b') }},
+		{name: "InsertBetween", edit: func(l *Line) { l.InsertBetween(middle, middle+2, 'a', 'b') }},
+		{name: "Replace", edit: func(l *Line) { l.Replace(middle, middle+5, []rune("hello")...) }},
+		{name: "Cut", edit: func(l *Line) { l.Cut(middle, middle+2) }},
+		{name: "CutRune", edit: func(l *Line) { l.CutRune(middle) }},
+	}
+
+	for _, edit := range edits {
+		b.Run(edit.name, func(b *testing.B) {
+			buf := make([]rune, 0, len(large))
+
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				line := Line(append(buf[:0], large...))
+				edit.edit(&line)
+			}
+		})
+	}
+}
+
+// TestLine_largeEdits checks that large lines, edited in place, behave exactly like small ones.
+func TestLine_largeEdits(t *testing.T) {
+	base := []rune(strings.Repeat("échö hello world; ", 300))
+	if len(base) < gapThreshold {
+		t.Fatalf("base line has %d runes, want at least %d", len(base), gapThreshold)
+	}
+
+	var line Line
+	line.Set(base...)
+
+	want := string(base)
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 2000; i++ {
+		pos := rnd.Intn(line.Len() + 1)
+		end := min(pos+rnd.Intn(12), line.Len())
+		chars := []rune(strings.Repeat("ab", rnd.Intn(4)))
+
+		switch rnd.Intn(4) {
+		case 0:
+			line.Insert(pos, chars...)
+			want = string([]rune(want)[:pos]) + string(chars) + string([]rune(want)[pos:])
+		case 1:
+			line.Cut(pos, end)
+			want = string([]rune(want)[:pos]) + string([]rune(want)[end:])
+		case 2:
+			line.Replace(pos, end, chars...)
+			want = string([]rune(want)[:pos]) + string(chars) + string([]rune(want)[end:])
+		case 3:
+			// Replacements taken from the line itself.
+			self := line[pos:end]
+			want = string([]rune(want)[:end]) + string(self) + string([]rune(want)[end:])
+			line.Insert(end, self...)
+		}
+
+		if string(line) != want {
+			t.Fatalf("edit %d: line differs from expected result", i)
+		}
+	}
+
+	// Large lines never share their runes.
+	if string(base) != strings.Repeat("échö hello world; ", 300) {
+		t.Error("runes passed to Set() have been modified by edits")
+	}
+}
+
+// TestLine_largeAliased checks that lines set from one another don't share their runes,
+// even when one of them grows large enough to be edited in place.
+func TestLine_largeAliased(t *testing.T) {
+	for _, size := range []int{gapThreshold - 1, gapThreshold, gapThreshold * 2} {
+		var a, b Line
+
+		a = Line(append(make([]rune, 0, size*2), []rune(strings.Repeat("a", size))...))
+		b.Set(a...)
+
+		a.Insert(a.Len(), 'x')
+		a.Insert(0, 'y')
+		a.Replace(1, 3, 'z')
+		a.Cut(4, 6)
+
+		if want := strings.Repeat("a", size); string(b) != want {
+			t.Errorf("%d runes: line set from another one has been modified by its edits", size)
+		}
+
+		b.Insert(0, 'w')
+
+		if a[0] != 'y' {
+			t.Errorf("%d runes: line has been modified by edits of a line set from it", size)
+		}
+	}
+}
+
+// BenchmarkLine_largeEdits types in the middle of a large buffer, either with lines
+// always reallocated on edits, or edited in place above the gap threshold (default).
+func BenchmarkLine_largeEdits(b *testing.B) {
+	large := []rune(strings.Repeat("echo hello world; ", 1000))
+
+	for _, inPlace := range []bool{false, true} {
+		name := "reallocated"
+		if inPlace {
+			name = "in place"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			threshold := gapThreshold
+			if !inPlace {
+				gapThreshold = len(large) * 2
+			}
+
+			defer func() { gapThreshold = threshold }()
+
+			var line Line
+			line.Set(large...)
+
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				pos := len(large) / 4
+
+				line.Insert(pos, 'a')
+				line.CutRune(pos)
+			}
+		})
+	}
+}
+
 func TestLine_Len(t *testing.T) {
 	line := Line("basic -f \"commands.go,line.go\" -cp=/usr")
 
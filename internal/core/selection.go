@@ -486,6 +486,16 @@ func (s *Selection) SelectKeyword(bpos, epos int, next bool) (kbpos, kepos int,
 // selection range by applying to each rune the provided replacer function.
 // After replacement, the selection is reset.
 func (s *Selection) ReplaceWith(replacer func(r rune) rune) {
+	s.ReplaceWithIndexed(func(_ int, r rune) rune {
+		return replacer(r)
+	})
+}
+
+// ReplaceWithIndexed is like ReplaceWith, but the replacer function is also
+// given the index of each rune in the selection (0 for its first rune), so that
+// the replacement can depend on its position. After replacement, the selection
+// is reset.
+func (s *Selection) ReplaceWithIndexed(replacer func(i int, r rune) rune) {
 	if s.line.Len() == 0 || s.Len() == 0 {
 		return
 	}
@@ -499,7 +509,7 @@ func (s *Selection) ReplaceWith(replacer func(r rune) rune) {
 
 	for pos := bpos; pos < epos; pos++ {
 		char := (*s.line)[pos]
-		char = replacer(char)
+		char = replacer(pos-bpos, char)
 		(*s.line)[pos] = char
 	}
 }
@@ -1316,6 +1316,88 @@ func TestSelection_ReplaceWith(t *testing.T) {
 	}
 }
 
+func TestSelection_ReplaceWithIndexed(t *testing.T) {
+	emptyline, emptycur := newLine("")
+	line, cur := newLine("multiple-ambiguous lower UPPER")
+
+	// Alternate case, starting with uppercase on the first rune of the selection.
+	alternate := func(i int, r rune) rune {
+		if i%2 == 0 {
+			return unicode.ToUpper(r)
+		}
+
+		return unicode.ToLower(r)
+	}
+
+	// Rotate each letter by its index in the selection.
+	rotate := func(i int, r rune) rune {
+		if r < 'a' || r > 'z' {
+			return r
+		}
+
+		return 'a' + (r-'a'+rune(i))%26
+	}
+
+	type args struct {
+		bpos     int
+		epos     int
+		replacer func(i int, r rune) rune
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantBuf string
+	}{
+		{
+			name:    "Empty line",
+			fields:  fieldsWith(emptyline, &emptycur),
+			args:    args{bpos: 0, epos: 0, replacer: alternate},
+			wantBuf: "",
+		},
+		{
+			name:    "Alternate case",
+			fields:  fieldsWith(line, &cur),
+			args:    args{bpos: 19, epos: 29, replacer: alternate},
+			wantBuf: "multiple-ambiguous LoWeR UpPeR",
+		},
+		{
+			name:    "Rotate by index",
+			fields:  fieldsWith(line, &cur),
+			args:    args{bpos: 0, epos: 7, replacer: rotate},
+			wantBuf: "mvnwmure-ambiguous lower UPPER",
+		},
+		{
+			name:    "Index from selection start (with epos out-of-range)",
+			fields:  fieldsWith(line, &cur),
+			args:    args{bpos: 25, epos: line.Len() + 1, replacer: alternate},
+			wantBuf: "multiple-ambiguous lower UpPeR",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line, cur = newLine("multiple-ambiguous lower UPPER")
+			if test.fields.line == nil || test.fields.line.Len() != 0 {
+				test.fields.line, test.fields.cursor = &line, &cur
+			}
+
+			sel := newTestSelection(test.fields)
+
+			// Mark and replace the selection.
+			sel.MarkRange(test.args.bpos, test.args.epos)
+			sel.ReplaceWithIndexed(test.args.replacer)
+
+			// Check line contents and selection reset.
+			gotBuf := string(*test.fields.line)
+			if gotBuf != test.wantBuf {
+				t.Errorf("Selection.ReplaceWithIndexed() gotBuf = %v, want %v", gotBuf, test.wantBuf)
+			}
+			testSelectionReset(t, sel)
+		})
+	}
+}
+
 func TestSelection_Cut(t *testing.T) {
 	emptyline, emptycur := newLine("")
 	line, cur := newLine("multiple-ambiguous 10.203.23.45 127.0.0.1")
@@ -13,7 +13,7 @@ import (
 func WatchResize(eng *Engine) chan<- bool {
 	done := make(chan bool, 1)
 
-	resizeChannel := make(chan os.Signal)
+	resizeChannel := make(chan os.Signal, 1)
 	signal.Notify(resizeChannel, syscall.SIGWINCH)
 
 	go func() {
@@ -1,7 +1,7 @@
 package display
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/color"
@@ -32,6 +32,11 @@ type Engine struct {
 	hintRows       int
 	compRows       int
 	primaryPrinted bool
+	folded         bool
+	foldStart      int
+	foldEnd        int
+	foldTailStart  int
+	foldTailEnd    int
 
 	// UI components
 	keys      *core.Keys
@@ -69,7 +74,10 @@ func Init(e *Engine, highlighter func([]rune) string) {
 // Refresh recomputes and redisplays the entire readline interface, except
 // the first lines of the primary prompt when the latter is a multiline one.
 func (e *Engine) Refresh() {
-	fmt.Print(term.HideCursor)
+	term.StartBuffer()
+	defer term.EndBuffer()
+
+	term.Print(term.HideCursor)
 
 	// Go back to the first column, and if the primary prompt
 	// was not printed yet, back up to the line's beginning row.
@@ -94,7 +102,7 @@ func (e *Engine) Refresh() {
 	// Go back to the start of the line, then to cursor.
 	e.cursorHintToLineStart()
 	e.lineStartToCursorPos()
-	fmt.Print(term.ShowCursor)
+	term.Print(term.ShowCursor)
 }
 
 // PrintPrimaryPrompt redraws the primary prompt.
@@ -107,8 +115,11 @@ func (e *Engine) PrintPrimaryPrompt() {
 
 // ClearHelpers clears the hint and completion sections below the line.
 func (e *Engine) ClearHelpers() {
+	term.StartBuffer()
+	defer term.EndBuffer()
+
 	e.CursorBelowLine()
-	fmt.Print(term.ClearScreenBelow)
+	term.Print(term.ClearScreenBelow)
 
 	term.MoveCursorUp(1)
 	term.MoveCursorUp(e.lineRows)
@@ -127,6 +138,9 @@ func (e *Engine) ResetHelpers() {
 // hints, completions and some right prompts, the shell will put the
 // display at the start of the line immediately following the line.
 func (e *Engine) AcceptLine() {
+	term.StartBuffer()
+	defer term.EndBuffer()
+
 	e.CursorToLineStart()
 
 	e.computeCoordinates(false)
@@ -135,14 +149,14 @@ func (e *Engine) AcceptLine() {
 	term.MoveCursorBackwards(term.GetWidth())
 	term.MoveCursorDown(e.lineRows)
 	term.MoveCursorForwards(e.lineCol)
-	fmt.Print(term.ClearScreenBelow)
+	term.Print(term.ClearScreenBelow)
 
 	// Reprint the right-side prompt if it's not a tooltip one.
 	e.prompt.RightPrint(e.lineCol, false)
 
 	// Go below this non-suggested line and clear everything.
 	term.MoveCursorBackwards(term.GetWidth())
-	fmt.Print(term.NewlineReturn)
+	term.Print(term.NewlineReturn)
 }
 
 // RefreshTransient goes back to the first line of the input buffer
@@ -152,6 +166,9 @@ func (e *Engine) RefreshTransient() {
 		return
 	}
 
+	term.StartBuffer()
+	defer term.EndBuffer()
+
 	// Go to the beginning of the primary prompt.
 	e.CursorToLineStart()
 	term.MoveCursorUp(e.prompt.PrimaryUsed())
@@ -159,7 +176,7 @@ func (e *Engine) RefreshTransient() {
 	// And redisplay the transient/primary/line.
 	e.prompt.TransientPrint()
 	e.displayLine()
-	fmt.Print(term.NewlineReturn)
+	term.Print(term.NewlineReturn)
 }
 
 // CursorToLineStart moves the cursor just after the primary prompt.
@@ -178,7 +195,7 @@ func (e *Engine) CursorToLineStart() {
 func (e *Engine) CursorBelowLine() {
 	term.MoveCursorUp(e.cursorRow)
 	term.MoveCursorDown(e.lineRows)
-	fmt.Print(term.NewlineReturn)
+	term.Print(term.NewlineReturn)
 }
 
 // lineStartToCursorPos can be used if the cursor is currently
@@ -200,11 +217,7 @@ func (e *Engine) cursorHintToLineStart() {
 func (e *Engine) computeCoordinates(suggested bool) {
 	// Get the new input line and auto-suggested one.
 	e.line, e.cursor = e.completer.Line()
-	if e.completer.IsInserting() {
-		e.suggested = *e.line
-	} else {
-		e.suggested = e.histories.Suggest(e.line)
-	}
+	e.suggested = e.suggestion()
 
 	// Get the position of the line's beginning by querying
 	// the terminal for the cursor position.
@@ -221,8 +234,15 @@ func (e *Engine) computeCoordinates(suggested bool) {
 
 	e.cursorCol, e.cursorRow = core.CoordinatesCursor(e.cursor, e.startCols)
 
+	// A folded line always fits on a single row, without suggestion.
+	e.computeFold()
+
 	// Get the number of rows used by the line, and the end line X pos.
-	if e.opts.GetBool("history-autosuggest") && suggested {
+	if e.isFolded() {
+		e.suggested = *e.line
+		e.cursorCol, e.cursorRow = e.startCols+e.foldedPos(e.cursor.Pos()), 0
+		e.lineCol, e.lineRows = e.startCols+e.foldedPos(e.line.Len()), 0
+	} else if e.opts.GetBool("history-autosuggest") && suggested {
 		e.lineCol, e.lineRows = core.CoordinatesLine(&e.suggested, e.startCols)
 	} else {
 		e.lineCol, e.lineRows = core.CoordinatesLine(e.line, e.startCols)
@@ -232,6 +252,49 @@ func (e *Engine) computeCoordinates(suggested bool) {
 }
 
 func (e *Engine) displayLine() {
+	line := e.renderLine(e.suggested) + term.ClearLineAfter
+
+	// And display the line.
+	e.suggested.Set([]rune(line)...)
+	core.DisplayLine(&e.suggested, e.startCols)
+
+	// Adjust the cursor if the line fits exactly in the terminal width.
+	if e.lineCol == 0 {
+		term.Print(term.NewlineReturn)
+		term.Print(term.ClearLineAfter)
+	}
+}
+
+// RenderedLine returns the primary prompt and the input line, styled as they are
+// displayed, without the terminal sequences used to clear the screen or move the
+// cursor: lines of input after the first one are indented with spaces as wide as
+// the last line of the prompt. The right prompt and the helpers are not included.
+func (e *Engine) RenderedLine() string {
+	e.line, e.cursor = e.completer.Line()
+
+	prompt := e.prompt.PrimaryString()
+	indent := strings.Repeat(" ", strutil.RealLength(prompt[strings.LastIndex(prompt, "\n")+1:]))
+	lines := strings.Split(e.renderLine(e.suggestion()), "\n")
+
+	return prompt + strings.Join(lines, color.BgDefault+"\n"+indent) + color.BgDefault
+}
+
+// suggestion returns the input line followed by its autosuggested
+// part, if any: the latter is never used while completing.
+func (e *Engine) suggestion() core.Line {
+	switch {
+	case e.completer.IsInserting():
+		return *e.line
+	case e.opts.GetBool("autosuggest-end-of-line") && e.cursor.Pos() < e.line.Len():
+		return *e.line
+	default:
+		return e.histories.Suggest(e.line)
+	}
+}
+
+// renderLine returns the input line with all its highlighting, and the
+// autosuggested part of the suggested line if any, ready to be printed.
+func (e *Engine) renderLine(suggested core.Line) string {
 	var line string
 
 	// Apply user-defined highlighter to the input line.
@@ -250,30 +313,35 @@ func (e *Engine) displayLine() {
 	// Apply visual selections highlighting if any
 	line = e.highlightLine([]rune(line), *e.selection)
 
+	// Hide the middle of the line if it is folded.
+	if e.isFolded() {
+		line = string(e.foldLine([]rune(line)))
+	}
+
 	// Get the subset of the suggested line to print.
-	if len(e.suggested) > e.line.Len() && e.opts.GetBool("history-autosuggest") {
-		line += color.Dim + color.Fmt(color.Fg+"242") + string(e.suggested[e.line.Len():]) + color.Reset
+	if len(suggested) > e.line.Len() && e.opts.GetBool("history-autosuggest") {
+		line += e.autosuggestStyle() + string(suggested[e.line.Len():]) + color.Reset
 	}
 
 	// Format tabs as spaces, for consistent display
-	line = strutil.FormatTabs(line) + term.ClearLineAfter
-
-	// And display the line.
-	e.suggested.Set([]rune(line)...)
-	core.DisplayLine(&e.suggested, e.startCols)
+	return strutil.FormatTabs(line)
+}
 
-	// Adjust the cursor if the line fits exactly in the terminal width.
-	if e.lineCol == 0 {
-		fmt.Print(term.NewlineReturn)
-		fmt.Print(term.ClearLineAfter)
+// autosuggestStyle returns the color sequences used to display the autosuggested
+// part of the line: the autosuggest-style option if set, or a dimmed gray.
+func (e *Engine) autosuggestStyle() string {
+	if style := color.UnquoteRC(e.opts.GetString("autosuggest-style")); style != "" {
+		return style
 	}
+
+	return color.Dim + color.Fmt(color.Fg+"242")
 }
 
 // displayHelpers renders the hint and completion sections.
 // It assumes that the cursor is on the last line of input,
 // and goes back to this same line after displaying this.
 func (e *Engine) displayHelpers() {
-	fmt.Print(term.NewlineReturn)
+	term.Print(term.NewlineReturn)
 
 	// Recompute completions and hints if autocompletion is on.
 	e.completer.Autocomplete()
@@ -302,3 +370,19 @@ func (e *Engine) AvailableHelperLines() int {
 
 	return compLines
 }
+
+// LinePosition returns the position in the input line displayed at the given
+// terminal coordinates (1-based, as reported by mouse events), or false if the
+// coordinates are not on one of the rows used by the input line.
+func (e *Engine) LinePosition(x, y int) (pos int, ok bool) {
+	row := y - e.startRows
+	if e.startRows <= 0 || row < 0 || row > e.lineRows {
+		return 0, false
+	}
+
+	if e.isFolded() {
+		return e.unfoldedPos(x - 1 - e.startCols), true
+	}
+
+	return core.PosAtCoordinates(e.line, e.startCols, x-1, row), true
+}
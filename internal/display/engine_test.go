@@ -0,0 +1,100 @@
+package display
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/core"
+	"github.com/alexj212/readline/internal/strutil"
+	"github.com/alexj212/readline/internal/term"
+)
+
+func TestEngine_autosuggestStyle(t *testing.T) {
+	tests := []struct {
+		name  string
+		style string
+		want  string
+	}{
+		{name: "Default dimmed style", style: "", want: color.Dim + color.Fmt(color.Fg+"242")},
+		{name: "Inputrc escapes", style: `\e[3;36m`, want: "\x1b[3;36m"},
+		{name: "Quoted value", style: `"\e[38;05;110m"`, want: "\x1b[38;05;110m"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts := inputrc.NewDefaultConfig()
+			opts.Set("autosuggest-style", test.style)
+
+			eng := &Engine{opts: opts}
+
+			if got := eng.autosuggestStyle(); got != test.want {
+				t.Errorf("autosuggestStyle() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEngine_fold(t *testing.T) {
+	long := strings.Repeat("abcdefghij", 20)
+
+	tests := []struct {
+		name      string
+		line      string
+		cursor    int
+		folded    bool
+		wantStart int
+		wantEnd   int
+		wantTail  [2]int
+	}{
+		{name: "Unfolded", line: long, cursor: 0},
+		{name: "Short line", line: "echo hello", cursor: 0, folded: true},
+		{name: "Multiline", line: long + "\n" + long, cursor: 0, folded: true},
+		{name: "Cursor at start", line: long, cursor: 0, folded: true, wantStart: 38, wantEnd: 162},
+		{name: "Cursor at end", line: long, cursor: 200, folded: true, wantStart: 38, wantEnd: 162},
+		{name: "Cursor after head", line: long, cursor: 50, folded: true, wantStart: 51, wantEnd: 175},
+		{name: "Cursor before tail", line: long, cursor: 130, folded: true, wantStart: 6, wantEnd: 130},
+		{name: "Cursor far from ends", line: long + long, cursor: 200, folded: true, wantStart: 19, wantEnd: 182, wantTail: [2]int{219, 381}},
+		{name: "Cursor far from ends, near head", line: long, cursor: 75, folded: true, wantStart: 19, wantEnd: 57, wantTail: [2]int{94, 181}},
+		{name: "Cursor far from ends, near tail", line: long, cursor: 124, folded: true, wantStart: 19, wantEnd: 106, wantTail: [2]int{143, 181}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := core.Line(test.line)
+			cursor := core.NewCursor(&line)
+			cursor.Set(test.cursor)
+
+			eng := &Engine{line: &line, cursor: cursor, startCols: 2, folded: test.folded}
+			eng.computeFold()
+
+			if eng.foldStart != test.wantStart || eng.foldEnd != test.wantEnd {
+				t.Fatalf("fold = %d-%d, want %d-%d", eng.foldStart, eng.foldEnd, test.wantStart, test.wantEnd)
+			}
+
+			if tail := [2]int{eng.foldTailStart, eng.foldTailEnd}; tail != test.wantTail {
+				t.Fatalf("tail fold = %d-%d, want %d-%d", tail[0], tail[1], test.wantTail[0], test.wantTail[1])
+			}
+
+			if !eng.isFolded() {
+				return
+			}
+
+			// The folded line fits in the terminal width, and keeps the cursor visible.
+			folded := eng.foldLine([]rune(color.Bold + test.line + color.Reset))
+			if got := strutil.RealLength(string(folded)); got != term.GetWidth()-eng.startCols-1 {
+				t.Errorf("folded line length = %d, want %d", got, term.GetWidth()-eng.startCols-1)
+			}
+
+			col := eng.foldedPos(test.cursor)
+			if test.cursor < line.Len() && []rune(color.Strip(string(folded)))[col] != line[test.cursor] {
+				t.Errorf("cursor column %d does not display %q", col, line[test.cursor])
+			}
+
+			if got := eng.unfoldedPos(col); got != test.cursor {
+				t.Errorf("unfoldedPos(%d) = %d, want %d", col, got, test.cursor)
+			}
+		})
+	}
+}
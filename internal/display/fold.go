@@ -0,0 +1,159 @@
+package display
+
+import (
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/strutil"
+	"github.com/alexj212/readline/internal/term"
+)
+
+// foldEllipsis is displayed in place of the hidden part of a folded line.
+const foldEllipsis = '…'
+
+// ToggleFold folds or unfolds the display of the input line. When folded,
+// a line too long to fit on the terminal width has its middle part displayed
+// as an ellipsis. The line itself is not modified, and the cursor is kept
+// visible, the ellipsis moving around it if needed.
+func (e *Engine) ToggleFold() {
+	e.folded = !e.folded
+}
+
+// Folded returns true if the input line is displayed folded when too long.
+func (e *Engine) Folded() bool {
+	return e.folded
+}
+
+// computeFold computes the ranges of the line hidden behind an ellipsis, if
+// the line must be folded. Lines spanning several rows (or using tabs or wide
+// characters) are never folded. When the cursor is too far away from both ends
+// of the line to be kept visible, both sides of a window around it are folded,
+// with a second ellipsis after the window.
+func (e *Engine) computeFold() {
+	e.foldStart, e.foldEnd = 0, 0
+	e.foldTailStart, e.foldTailEnd = 0, 0
+
+	if !e.folded {
+		return
+	}
+
+	length := e.line.Len()
+	shown := term.GetWidth() - e.startCols - 2
+	pos := e.cursor.Pos()
+
+	if length <= shown+1 || shown < 2 || strutil.RealLength(string(*e.line)) != length {
+		return
+	}
+
+	// Hide the middle of the line, or have the
+	// ellipsis just before or after the cursor.
+	head := shown / 2
+
+	switch {
+	case pos < head || pos >= length-(shown-head):
+	case pos < shown-1:
+		head = pos + 1
+	case length-pos < shown:
+		head = shown - (length - pos)
+	default:
+		e.computeFoldWindow(length, shown, pos)
+		return
+	}
+
+	e.foldStart, e.foldEnd = head, length-(shown-head)
+}
+
+// computeFoldWindow folds both sides of a window centered on the cursor, keeping
+// a quarter of the shown width for both the start and the end of the line.
+func (e *Engine) computeFoldWindow(length, shown, pos int) {
+	head, tail := shown/4, shown/4
+	window := shown - 1 - head - tail
+
+	start := pos - window/2
+	end := start + window
+
+	if start <= head || end >= length-tail {
+		return
+	}
+
+	e.foldStart, e.foldEnd = head, start
+	e.foldTailStart, e.foldTailEnd = end, length-tail
+}
+
+// isFolded returns true if the line currently has a part hidden.
+func (e *Engine) isFolded() bool {
+	return e.foldEnd > e.foldStart
+}
+
+// isTailFolded returns true if the line has a second part hidden, after the cursor.
+func (e *Engine) isTailFolded() bool {
+	return e.foldTailEnd > e.foldTailStart
+}
+
+// foldedPos returns the column at which a line position is displayed, relative
+// to the start of the line, when the latter is folded.
+func (e *Engine) foldedPos(pos int) int {
+	if pos < e.foldStart {
+		return pos
+	}
+
+	col := pos - (e.foldEnd - e.foldStart) + 1
+
+	if e.isTailFolded() && pos >= e.foldTailStart {
+		col -= e.foldTailEnd - e.foldTailStart - 1
+	}
+
+	return col
+}
+
+// unfoldedPos returns the line position displayed at a given column relative
+// to the start of the line, when the latter is folded. An ellipsis column is
+// the first position it hides.
+func (e *Engine) unfoldedPos(col int) int {
+	switch {
+	case col < e.foldStart:
+		return max(col, 0)
+	case col == e.foldStart:
+		return e.foldStart
+	}
+
+	pos := col + (e.foldEnd - e.foldStart) - 1
+
+	switch {
+	case !e.isTailFolded() || pos < e.foldTailStart:
+		return min(pos, e.line.Len())
+	case pos == e.foldTailStart:
+		return e.foldTailStart
+	default:
+		return min(pos+(e.foldTailEnd-e.foldTailStart)-1, e.line.Len())
+	}
+}
+
+// foldLine replaces the hidden parts of a (highlighted) line with an ellipsis.
+// All color sequences are kept, so that highlighting remains consistent.
+func (e *Engine) foldLine(line []rune) []rune {
+	colors := color.Positions(string(line))
+	folded := make([]rune, 0, len(line))
+	pos := 0
+
+	for i := 0; i < len(line); i++ {
+		if len(colors) > 0 && colors[0][0] == i {
+			folded = append(folded, line[i:colors[0][1]]...)
+			i = colors[0][1] - 1
+			colors = colors[1:]
+
+			continue
+		}
+
+		tailHidden := e.isTailFolded() && pos >= e.foldTailStart && pos < e.foldTailEnd
+
+		switch {
+		case pos == e.foldStart, e.isTailFolded() && pos == e.foldTailStart:
+			folded = append(folded, foldEllipsis)
+		case (pos < e.foldStart || pos >= e.foldEnd) && !tailHidden:
+			folded = append(folded, line[i])
+		}
+
+		pos++
+	}
+
+	return folded
+}
@@ -32,9 +32,9 @@ func (e *Engine) highlightLine(line []rune, selection core.Selection) string {
 
 	// Finally, highlight comments using a regex.
 	comment := strings.Trim(e.opts.GetString("comment-begin"), "\"")
-	commentPattern := fmt.Sprintf(`(^|\s)%s.*`, comment)
+	commentPattern := fmt.Sprintf(`(^|\s)%s.*`, regexp.QuoteMeta(comment))
 
-	if commentsMatch, err := regexp.Compile(commentPattern); err == nil {
+	if commentsMatch, err := regexp.Compile(commentPattern); err == nil && comment != "" {
 		commentColor := color.SGRStart + color.Fg + "244" + color.SGREnd
 		highlighted = commentsMatch.ReplaceAllString(highlighted, fmt.Sprintf("%s${0}%s", commentColor, color.Reset))
 	}
@@ -100,10 +100,7 @@ func (e *Engine) getHighlights(line []rune, sorted []core.Selection) map[int][]r
 
 	// Find any highlighting already applied on the line,
 	// and keep the indexes so that we can skip those.
-	var colors [][]int
-
-	colorMatch := regexp.MustCompile(`\x1b\[[0-9;]+m`)
-	colors = colorMatch.FindAllStringIndex(string(line), -1)
+	colors := color.Positions(string(line))
 
 	// marks that started highlighting, but not done yet.
 	regions := make([]core.Selection, 0)
@@ -0,0 +1,103 @@
+package display
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/core"
+	"github.com/alexj212/readline/internal/strutil"
+)
+
+// highlightEach wraps every rune of the line in its own SGR sequences.
+func highlightEach(line []rune) string {
+	var highlighted string
+
+	for _, r := range line {
+		highlighted += "\x1b[1;31m" + string(r) + "\x1b[m"
+	}
+
+	return highlighted
+}
+
+func TestEngine_highlightLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		line      string
+		bpos      int
+		epos      int
+		cursor    int
+		cursorCol int
+	}{
+		{name: "ASCII line", line: "select * from users", bpos: 7, epos: 8, cursor: 14, cursorCol: 14},
+		{name: "Multibyte line", line: "héllo wörld ünïcode", bpos: 12, epos: 18, cursor: 9, cursorCol: 9},
+		{name: "Wide characters", line: "日本語 text", bpos: 4, epos: 7, cursor: 3, cursorCol: 6},
+		{name: "Wide characters after the cursor", line: "ab 日本語", bpos: 3, epos: 5, cursor: 4, cursorCol: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := core.Line(test.line)
+			cursor := core.NewCursor(&line)
+			cursor.Set(test.cursor)
+
+			selection := core.NewSelection(&line, cursor)
+			selection.MarkRange(test.bpos, test.epos)
+			selection.Visual(false)
+
+			eng := &Engine{opts: inputrc.NewDefaultConfig()}
+			highlighted := eng.highlightLine([]rune(highlightEach(line)), *selection)
+
+			// The visible text is unchanged by the highlighting.
+			if got := color.Strip(highlighted); got != test.line {
+				t.Fatalf("visible line = %q, want %q", got, test.line)
+			}
+
+			// The selection starts on the expected column.
+			start := strings.Index(highlighted, color.Reverse)
+			if start == -1 {
+				t.Fatalf("no selection highlighting in %q", highlighted)
+			}
+
+			wantCol := strutil.RealLength(string(line[:test.bpos]))
+			if got := strutil.RealLength(highlighted[:start]); got != wantCol {
+				t.Errorf("selection starts on column %d, want %d", got, wantCol)
+			}
+
+			// And the cursor column, computed on the raw line, is
+			// the width of the highlighted text before the cursor.
+			if got, _ := core.CoordinatesCursor(cursor, 0); got != test.cursorCol {
+				t.Errorf("cursor column = %d, want %d", got, test.cursorCol)
+			}
+
+			if got := strutil.RealLength(highlightedPrefix(highlighted, test.cursor)); got != test.cursorCol {
+				t.Errorf("highlighted text before the cursor spans %d columns, want %d", got, test.cursorCol)
+			}
+		})
+	}
+}
+
+// highlightedPrefix returns the part of a highlighted line, sequences
+// included, up to (excluded) its nth printed rune.
+func highlightedPrefix(highlighted string, n int) string {
+	printed := 0
+
+	for i := 0; i < len(highlighted); {
+		if strings.HasPrefix(highlighted[i:], "\x1b[") {
+			i += strings.IndexByte(highlighted[i:], 'm') + 1
+			continue
+		}
+
+		if printed == n {
+			return highlighted[:i]
+		}
+
+		_, size := utf8.DecodeRuneInString(highlighted[i:])
+		i += size
+		printed++
+	}
+
+	return highlighted
+}
@@ -0,0 +1,132 @@
+package display
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexj212/readline/internal/color"
+)
+
+// Token is a range of the input line, with the style to display it with.
+type Token struct {
+	Start int    // Index of the first rune of the token in the line.
+	End   int    // Index of the rune following the last one of the token.
+	Style string // Color/text effects sequence applied to the token runes.
+}
+
+// HighlightTokens returns the line with each token range wrapped in its style
+// and the sequences resetting the colors and effects it sets, and only those,
+// so that a selection highlighted across tokens is not reset by them. Tokens are
+// sorted, clipped to the line and their overlapping parts are dropped, so that the
+// output never misses a reset. Visual selections highlighting is then applied by
+// the display on top of it.
+func HighlightTokens(line []rune, tokens []Token) string {
+	sorted := make([]Token, len(tokens))
+	copy(sorted, tokens)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	var highlighted []rune
+
+	pos := 0
+
+	for _, token := range sorted {
+		if token.Start < pos {
+			token.Start = pos
+		}
+
+		if token.End > len(line) {
+			token.End = len(line)
+		}
+
+		if token.Start >= token.End || token.Style == "" {
+			continue
+		}
+
+		highlighted = append(highlighted, line[pos:token.Start]...)
+		highlighted = append(highlighted, []rune(token.Style)...)
+		highlighted = append(highlighted, line[token.Start:token.End]...)
+		highlighted = append(highlighted, []rune(styleReset(token.Style))...)
+
+		pos = token.End
+	}
+
+	highlighted = append(highlighted, line[pos:]...)
+
+	return string(highlighted)
+}
+
+// sgrSequence matches a Select Graphic Rendition sequence, with its parameters.
+var sgrSequence = regexp.MustCompile(`\x1b\[([\d;]*)m`)
+
+// styleReset returns the sequences resetting the colors and effects set by a
+// style, eg. the foreground color for "\x1b[31m". If the style is anything else
+// than SGR sequences setting them, or resets them all itself, all colors and
+// effects are reset.
+func styleReset(style string) string {
+	if sgrSequence.ReplaceAllString(style, "") != "" {
+		return color.Reset
+	}
+
+	var resets []string
+
+	add := func(reset string) {
+		for _, seq := range resets {
+			if seq == reset {
+				return
+			}
+		}
+
+		resets = append(resets, reset)
+	}
+
+	for _, match := range sgrSequence.FindAllStringSubmatch(style, -1) {
+		params := strings.Split(match[1], ";")
+
+		for i := 0; i < len(params); i++ {
+			switch param := params[i]; {
+			case param == "1", param == "2":
+				add(color.BoldReset)
+			case param == "3":
+				add(color.SGRStart + "23" + color.SGREnd)
+			case param == "4":
+				add(color.UnderscoreReset)
+			case param == "5":
+				add(color.BlinkReset)
+			case param == "7":
+				add(color.ReverseReset)
+			case param == "9":
+				add(color.SGRStart + "29" + color.SGREnd)
+			case param == "38", param == "48":
+				// Extended colors: 5;n or 2;r;g;b.
+				if i+1 < len(params) {
+					switch mode, _ := strconv.Atoi(params[i+1]); mode {
+					case 5:
+						i += 2
+					case 2:
+						i += 4
+					}
+				}
+
+				if param == "38" {
+					add(color.FgDefault)
+				} else {
+					add(color.BgDefault)
+				}
+			case len(param) == 2 && (param[0] == '3' || param[0] == '9') && param[1] <= '7':
+				add(color.FgDefault)
+			case len(param) == 2 && param[0] == '4' && param[1] <= '7',
+				len(param) == 3 && param[:2] == "10" && param[2] <= '7':
+				add(color.BgDefault)
+			default:
+				return color.Reset
+			}
+		}
+	}
+
+	return strings.Join(resets, "")
+}
@@ -0,0 +1,100 @@
+package display
+
+import (
+	"testing"
+
+	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/core"
+)
+
+func TestHighlightTokens(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		tokens []Token
+		want   string
+	}{
+		{name: "No tokens", line: "echo hi", want: "echo hi"},
+		{
+			name: "Foreground", line: "echo hi", tokens: []Token{{Start: 0, End: 4, Style: "\x1b[31m"}},
+			want: "\x1b[31mecho\x1b[39m hi",
+		},
+		{
+			name: "Background", line: "echo hi", tokens: []Token{{Start: 5, End: 7, Style: "\x1b[44m"}},
+			want: "echo \x1b[44mhi\x1b[49m",
+		},
+		{
+			name: "Effects and extended colors", line: "echo hi", tokens: []Token{{Start: 0, End: 4, Style: "\x1b[1;4m\x1b[38;05;208m"}},
+			want: "\x1b[1;4m\x1b[38;05;208mecho\x1b[22m\x1b[24m\x1b[39m hi",
+		},
+		{
+			name: "True color", line: "ab", tokens: []Token{{Start: 0, End: 1, Style: "\x1b[48;2;10;20;30;3m"}},
+			want: "\x1b[48;2;10;20;30;3ma\x1b[49m\x1b[23mb",
+		},
+		{
+			name: "Full reset in the style", line: "ab", tokens: []Token{{Start: 0, End: 1, Style: "\x1b[0;32m"}},
+			want: "\x1b[0;32ma\x1b[0mb",
+		},
+		{
+			name: "Not an SGR sequence", line: "ab", tokens: []Token{{Start: 1, End: 2, Style: "\x1b]8;;x\x07"}},
+			want: "a\x1b]8;;x\x07b\x1b[0m",
+		},
+		{
+			name: "Unsorted, overlapping and clipped", line: "echo hi",
+			tokens: []Token{{Start: 5, End: 9, Style: "\x1b[32m"}, {Start: 0, End: 6, Style: "\x1b[31m"}, {Start: 2, End: 3, Style: "\x1b[1m"}},
+			want:   "\x1b[31mecho h\x1b[39m\x1b[32mi\x1b[39m",
+		},
+		{
+			name: "Empty style", line: "echo", tokens: []Token{{Start: 0, End: 4}},
+			want: "echo",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := HighlightTokens([]rune(test.line), test.tokens); got != test.want {
+				t.Errorf("HighlightTokens() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEngine_highlightTokensSelection(t *testing.T) {
+	tokens := []Token{{Start: 0, End: 4, Style: "\x1b[31m"}, {Start: 5, End: 10, Style: "\x1b[1;32m"}, {Start: 11, End: 16, Style: "\x1b[34m"}}
+
+	tests := []struct {
+		name string
+		bpos int
+		epos int
+		want string
+	}{
+		{
+			name: "No region", bpos: -1, epos: -1,
+			want: "\x1b[31mecho\x1b[39m \x1b[1;32mhello\x1b[22m\x1b[39m \x1b[34mworld\x1b[39m\x1b[0m",
+		},
+		{
+			name: "Region across tokens", bpos: 2, epos: 13,
+			want: "\x1b[31mec\x1b[7mh\x1b[7mo\x1b[39m\x1b[7m \x1b[1;32m\x1b[7mh\x1b[7me\x1b[7ml\x1b[7ml\x1b[7mo\x1b[22m\x1b[39m\x1b[7m \x1b[34m" +
+				"\x1b[7mw\x1b[7mo\x1b[7mr\x1b[27mld\x1b[39m\x1b[0m",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line := core.Line("echo hello world")
+			cursor := core.NewCursor(&line)
+			selection := core.NewSelection(&line, cursor)
+
+			if test.bpos != -1 {
+				selection.MarkRange(test.bpos, test.epos)
+				selection.Visual(false)
+			}
+
+			eng := &Engine{opts: inputrc.NewDefaultConfig()}
+
+			if got := eng.highlightLine([]rune(HighlightTokens(line, tokens)), *selection); got != test.want {
+				t.Errorf("highlightLine() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"sync"
 	"unicode"
 
+	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/color"
 	"github.com/alexj212/readline/internal/completion"
 )
@@ -23,23 +24,28 @@ var (
 // Buffers is a list of registers in which to put yanked/cut contents.
 // These buffers technically are Vim registers with full functionality.
 type Buffers struct {
-	num      map[int][]rune  // numbered registers (0-9)
+	ring     [][]rune        // The kill ring, from the most recent entry (the kill buffer).
+	num      map[int][]rune  // numbered registers (1-9) written explicitly
 	alpha    map[rune][]rune // lettered registers ( a-z )
 	ro       map[rune][]rune // read-only registers ( . % : )
 	waiting  bool            // The user wants to use a still unidentified register
 	selected bool            // We have identified the register, and acting on it.
 	active   rune            // Any of the read/write registers ("/num/alpha)
+	killing  bool            // The last command run killed text.
+	killed   bool            // The command being run has killed text.
+	config   *inputrc.Config // The kill-ring-size option bounds the kill ring.
 	mutex    *sync.Mutex
 }
 
 // NewBuffers is a required constructor to set up all the buffers/registers
 // for the shell, because it contains maps that must be correctly initialized.
-func NewBuffers() *Buffers {
+func NewBuffers(config *inputrc.Config) *Buffers {
 	return &Buffers{
-		num:   make(map[int][]rune, numRegisters),
-		alpha: make(map[rune][]rune, alphaRegisters),
-		ro:    map[rune][]rune{},
-		mutex: &sync.Mutex{},
+		num:    make(map[int][]rune, numRegisters),
+		alpha:  make(map[rune][]rune, alphaRegisters),
+		ro:     map[rune][]rune{},
+		config: config,
+		mutex:  &sync.Mutex{},
 	}
 }
 
@@ -81,7 +87,7 @@ func (reg *Buffers) Get(register rune) []rune {
 
 	num, err := strconv.Atoi(string(register))
 	if err == nil {
-		return reg.num[num]
+		return reg.getNum(num)
 	}
 
 	if buf, found := reg.alpha[register]; found {
@@ -107,32 +113,60 @@ func (reg *Buffers) Active() []rune {
 	return reg.Get(reg.active)
 }
 
-// Pop rotates the kill ring and returns the new top.
-func (reg *Buffers) Pop() []rune {
-	if len(reg.num) == 0 {
+// Rotate rotates the kill ring count times, and returns the new top: with
+// a positive count, the top becomes the oldest entry and the next one is the
+// new top, while a negative count rotates the other way (the oldest entry
+// becoming the top). The rotation wraps around the ring in both directions.
+func (reg *Buffers) Rotate(count int) []rune {
+	size := len(reg.ring)
+	if size == 0 {
 		return nil
 	}
 
-	// Reassign the kill buffer and
-	// pop the first numbered register.
-	buf := string(reg.num[0])
-	delete(reg.num, 0)
+	shift := (count%size + size) % size
+	reg.ring = append(reg.ring[shift:], reg.ring[:shift]...)
 
-	for i := 0; i < len(reg.num); i++ {
-		reg.num[i] = []rune(string(reg.num[i+1]))
-		delete(reg.num, i+1)
-	}
-
-	return []rune(buf)
+	return reg.ring[0]
 }
 
 // GetKill returns the contents of the kill buffer.
 func (reg *Buffers) GetKill() []rune {
-	if len(reg.num) == 0 {
+	if len(reg.ring) == 0 {
 		return nil
 	}
 
-	return reg.num[0]
+	return reg.ring[0]
+}
+
+// Ring returns a copy of the kill ring, from the most
+// recent entry (the kill buffer) to the oldest one.
+func (reg *Buffers) Ring() []string {
+	ring := make([]string, 0, len(reg.ring))
+
+	for _, entry := range reg.ring {
+		ring = append(ring, string(entry))
+	}
+
+	return ring
+}
+
+// SetRing replaces the kill ring with entries, the first one being the most
+// recent (the kill buffer). Empty entries are ignored, as are those exceeding
+// the size of the ring.
+func (reg *Buffers) SetRing(entries []string) {
+	reg.ring = nil
+
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+
+		if len(reg.ring) == reg.ringSize() {
+			break
+		}
+
+		reg.ring = append(reg.ring, []rune(entry))
+	}
 }
 
 // Write writes a slice to the currently active buffer, and/or to the kill one.
@@ -154,6 +188,39 @@ func (reg *Buffers) Write(content ...rune) {
 	}
 }
 
+// Kill writes text killed by a command, like Write, except that consecutive
+// kills (the previous command having killed text too) are accumulated in the
+// kill buffer instead of being pushed to the kill ring: the text is appended
+// to it, or prepended to it if backward (the text being before the cursor).
+func (reg *Buffers) Kill(backward bool, content ...rune) {
+	reg.killed = true
+
+	if !reg.killing || reg.selected || len(reg.ring) == 0 {
+		reg.Write(content...)
+		return
+	}
+
+	defer reg.Reset()
+
+	if backward {
+		reg.ring[0] = append(append([]rune{}, content...), reg.ring[0]...)
+	} else {
+		reg.ring[0] = append(append([]rune{}, reg.ring[0]...), content...)
+	}
+}
+
+// IsKilling returns true if the last command run has killed text, in which
+// case the text killed by the next one is accumulated in the kill buffer.
+func (reg *Buffers) IsKilling() bool {
+	return reg.killing
+}
+
+// CommandDone must be called after each command run by the shell: unless
+// the command has killed text, the next kill is pushed to the kill ring.
+func (reg *Buffers) CommandDone() {
+	reg.killing, reg.killed = reg.killed, false
+}
+
 // WriteTo writes a slice directly to a target register.
 // If the register name is invalid, nothing is written anywhere.
 func (reg *Buffers) WriteTo(register rune, content ...rune) {
@@ -170,7 +237,7 @@ func (reg *Buffers) WriteTo(register rune, content ...rune) {
 
 	// If number register.
 	num, err := strconv.Atoi(string(register))
-	if num > 0 && num < 10 && err != nil {
+	if num > 0 && num < 10 && err == nil {
 		reg.writeNum(num, []rune(buf))
 		return
 	}
@@ -235,23 +302,41 @@ func (reg *Buffers) writeNum(register int, buf []rune) {
 		return
 	}
 
-	// Add to the stack with the specified register
+	// Write the specified register, out of the kill ring.
 	if register > 0 {
 		reg.num[register] = buf
 
 		return
 	}
 
-	// No push to the stack if we are already using 9
-	for i := len(reg.num); i > 0; i-- {
-		if i == numRegisters {
-			i--
-		}
+	// Push on the ring, evicting its oldest entries when full.
+	size := reg.ringSize()
 
-		reg.num[i] = append([]rune{}, reg.num[i-1]...)
+	reg.ring = append([][]rune{append([]rune{}, buf...)}, reg.ring[:min(len(reg.ring), size-1)]...)
+}
+
+// getNum returns the contents of a numbered register: the one written to it
+// explicitly if any, or the entry of the kill ring with the same index.
+func (reg *Buffers) getNum(register int) []rune {
+	if buf, found := reg.num[register]; found {
+		return buf
 	}
 
-	reg.num[0] = append([]rune{}, buf...)
+	if register >= 0 && register < len(reg.ring) {
+		return reg.ring[register]
+	}
+
+	return nil
+}
+
+// ringSize returns the maximum number of entries of the kill ring: with
+// a kill-ring-size of 0 (or below), the ring is reduced to one entry.
+func (reg *Buffers) ringSize() int {
+	if reg.config == nil {
+		return numRegisters
+	}
+
+	return max(reg.config.GetInt("kill-ring-size"), 1)
 }
 
 func (reg *Buffers) writeAlpha(register rune, buf []rune) {
@@ -260,7 +345,7 @@ func (reg *Buffers) writeAlpha(register rune, buf []rune) {
 
 	for _, char := range appendRegs {
 		if char == register {
-			register = unicode.ToLower(reg.active)
+			register = unicode.ToLower(register)
 			_, exists := reg.alpha[register]
 
 			if exists {
@@ -282,15 +367,12 @@ func (reg *Buffers) completeNumRegs() []completion.Candidate {
 	regs := make([]completion.Candidate, 0)
 	tag := color.Dim + "num ([0-9])" + color.Reset
 
-	var nums []int
-	for reg := range reg.num {
-		nums = append(nums, reg)
-	}
-
-	sort.Ints(nums)
+	for num := 0; num < numRegisters; num++ {
+		buf := reg.getNum(num)
+		if len(buf) == 0 {
+			continue
+		}
 
-	for _, num := range nums {
-		buf := reg.num[num]
 		display := strings.ReplaceAll(string(buf), "\n", ` `)
 
 		comp := completion.Candidate{
@@ -0,0 +1,105 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexj212/readline/inputrc"
+)
+
+func newTestBuffers(ringSize int) *Buffers {
+	config := inputrc.NewDefaultConfig()
+	config.Set("kill-ring-size", ringSize)
+
+	return NewBuffers(config)
+}
+
+func TestBuffers_ring(t *testing.T) {
+	reg := newTestBuffers(3)
+
+	for _, kill := range []string{"one", "two", "three", "four"} {
+		reg.Write([]rune(kill)...)
+	}
+
+	if got := strings.Join(reg.Ring(), ","); got != "four,three,two" {
+		t.Errorf("Ring() = %q, want the most recent entries first, bounded by the ring size", got)
+	}
+
+	if got := string(reg.Get('1')); got != "three" {
+		t.Errorf("register 1 = %q, want the second ring entry", got)
+	}
+
+	tests := []struct {
+		count int
+		want  string
+		ring  string
+	}{
+		{count: 1, want: "three", ring: "three,two,four"},
+		{count: 2, want: "four", ring: "four,three,two"},
+		{count: -1, want: "two", ring: "two,four,three"},
+		{count: 4, want: "four", ring: "four,three,two"},
+		{count: 0, want: "four", ring: "four,three,two"},
+	}
+
+	for _, test := range tests {
+		if got := string(reg.Rotate(test.count)); got != test.want {
+			t.Errorf("Rotate(%d) = %q, want %q", test.count, got, test.want)
+		}
+
+		if got := strings.Join(reg.Ring(), ","); got != test.ring {
+			t.Errorf("Ring() after Rotate(%d) = %q, want %q", test.count, got, test.ring)
+		}
+	}
+}
+
+// TestBuffers_numberedRegisters checks that numbered registers written explicitly
+// are kept out of the kill ring: they neither leave holes in it, nor are evicted.
+func TestBuffers_numberedRegisters(t *testing.T) {
+	reg := newTestBuffers(2)
+
+	reg.Write([]rune("one")...)
+	reg.Write([]rune("two")...)
+	reg.WriteTo('5', []rune("five")...)
+
+	if got := strings.Join(reg.Ring(), ","); got != "two,one" {
+		t.Errorf("Ring() = %q, want only the killed entries", got)
+	}
+
+	if got := string(reg.Rotate(1)); got != "one" {
+		t.Errorf("Rotate(1) = %q, want the previous entry of the ring", got)
+	}
+
+	reg.Write([]rune("three")...)
+	reg.Write([]rune("four")...)
+
+	if got := string(reg.Get('5')); got != "five" {
+		t.Errorf("register 5 = %q after kills, want %q", got, "five")
+	}
+
+	if got := strings.Join(reg.Ring(), ","); got != "four,three" {
+		t.Errorf("Ring() = %q, want the last two kills", got)
+	}
+
+	if got := string(reg.Get('9')); got != "" {
+		t.Errorf("register 9 = %q, want it empty", got)
+	}
+}
+
+func TestBuffers_Kill(t *testing.T) {
+	reg := newTestBuffers(10)
+
+	reg.Kill(false, []rune("hello")...)
+	reg.CommandDone()
+	reg.Kill(false, []rune(" world")...)
+	reg.CommandDone()
+	reg.Kill(true, []rune(">> ")...)
+	reg.CommandDone()
+
+	// Another command breaks the sequence of kills.
+	reg.CommandDone()
+	reg.Kill(false, []rune("next")...)
+
+	if got := strings.Join(reg.Ring(), ","); got != "next,>> hello world" {
+		t.Errorf("Ring() = %q, want consecutive kills accumulated", got)
+	}
+}
@@ -124,3 +124,19 @@ func getSystemEditor(emacsDefault bool) (editor string) {
 
 	return "vi"
 }
+
+func getSystemPager() string {
+	if pager := os.Getenv("PAGER"); pager != "" {
+		return pager
+	}
+
+	return "less"
+}
+
+func getSystemShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+
+	return "sh"
+}
@@ -9,3 +9,13 @@ import "errors"
 func (reg *Buffers) EditBuffer(buf []rune, filename, filetype string, emacs bool) ([]rune, error) {
 	return buf, errors.New("Not currently supported on Plan 9")
 }
+
+// Output is currently not supported on Plan9 operating systems.
+func Output(command string) (string, error) {
+	return "", errors.New("Not currently supported on Plan 9")
+}
+
+// Page is currently not supported on Plan9 operating systems.
+func Page(content string) error {
+	return errors.New("Not currently supported on Plan 9")
+}
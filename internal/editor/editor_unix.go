@@ -3,14 +3,22 @@
 package editor
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"strings"
 )
 
-// ErrStart indicates that the command to start the editor failed.
-var ErrStart = errors.New("failed to start editor")
+var (
+	// ErrStart indicates that the command to start the editor failed.
+	ErrStart = errors.New("failed to start editor")
+	// ErrCommand indicates that a command run for its output has failed.
+	ErrCommand = errors.New("command failed")
+	// ErrPager indicates that the pager failed to run.
+	ErrPager = errors.New("pager failed")
+)
 
 // EditBuffer starts the system editor and opens the given buffer in it.
 // If the filename is specified, the file will be created in the system
@@ -50,3 +58,40 @@ func (reg *Buffers) EditBuffer(buf []rune, filename, filetype string, emacs bool
 
 	return []rune(string(b)), err
 }
+
+// Output runs a command with the system shell, and returns its standard output.
+// If the command fails, the error contains its standard error output, if any.
+func Output(command string) (string, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(getSystemShell(), "-c", command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", ErrCommand, msg)
+		}
+
+		return "", fmt.Errorf("%w: %s", ErrCommand, err.Error())
+	}
+
+	return stdout.String(), nil
+}
+
+// Page runs the system pager ($PAGER, or less) with the system shell, and
+// writes the content to its standard input. The pager uses the process
+// standard output and error, and Page returns once it has exited.
+func Page(content string) error {
+	cmd := exec.Command(getSystemShell(), "-c", getSystemPager())
+
+	cmd.Stdin = strings.NewReader(content)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", ErrPager, err.Error())
+	}
+
+	return nil
+}
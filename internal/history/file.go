@@ -16,6 +16,9 @@ var (
 	errOutOfRangeIndex = errors.New("index requested greater than number of items in history")
 )
 
+// maxEntrySize is the maximum size of a history file entry, once encoded.
+const maxEntrySize = 1024 * 1024
+
 // fileHistory provides a history source based on a file.
 type fileHistory struct {
 	file  string
@@ -46,7 +49,11 @@ func openHist(filename string) (list []Item, err error) {
 		return list, fmt.Errorf("%w: %s", errOpenHistoryFile, err.Error())
 	}
 
+	// Entries (multiline ones especially) might be longer
+	// than the default scanner limit, which would stop it.
 	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxEntrySize)
+
 	for scanner.Scan() {
 		var item Item
 
@@ -32,6 +32,7 @@ type Sources struct {
 	// Line changes history
 	skip    bool                            // Skip saving the current line state.
 	undoing bool                            // The last command executed was an undo.
+	noUndo  bool                            // No undo states are saved at all.
 	last    inputrc.Bind                    // The last command being ran.
 	lines   map[string]map[int]*lineHistory // Each line in each history source has its own buffer history.
 
@@ -181,7 +182,7 @@ func (h *Sources) Walk(pos int) {
 	// Save the current line buffer if we are leaving it.
 	if h.hpos == -1 && pos > 0 {
 		h.skip = false
-		h.Save()
+		h.saveBuffer()
 		h.cpos = -1
 		h.hpos = 0
 	}
@@ -297,7 +298,9 @@ func (h *Sources) Write(infer bool) {
 		return
 	}
 
-	line := string(*h.line)
+	// Multiline entries are written as a whole, with
+	// their newlines normalized for display on recall.
+	line := strings.ReplaceAll(string(*h.line), "\r\n", "\n")
 
 	if len(strings.TrimSpace(line)) == 0 {
 		return
@@ -23,7 +23,7 @@ type undoItem struct {
 func (h *Sources) Save() {
 	defer h.Reset()
 
-	if h.skip {
+	if h.skip || h.noUndo {
 		return
 	}
 
@@ -60,6 +60,19 @@ func (h *Sources) Save() {
 	})
 }
 
+// DisableUndo disables saving undo states if disable is true, and drops any of the
+// states already saved, or enables them back otherwise. When disabled, Save() has
+// no effect, and Undo(), Redo() and Revert() do nothing.
+func (h *Sources) DisableUndo(disable bool) {
+	h.noUndo = disable
+
+	if disable {
+		for source := range h.lines {
+			h.lines[source] = make(map[int]*lineHistory)
+		}
+	}
+}
+
 // SkipSave will not save the current line when the target command is done
 // (more precisely, the next call to h.Save() will have no effect).
 // This function is not useful is most cases, as call to saves will efficiently
@@ -81,6 +94,10 @@ func (h *Sources) Undo() {
 	h.skip = true
 	h.undoing = true
 
+	if h.noUndo {
+		return
+	}
+
 	// Get the undo states for the current line.
 	line := h.getLineHistory()
 	if line == nil || len(line.items) == 0 {
@@ -116,6 +133,10 @@ func (h *Sources) Undo() {
 // like when the shell started reading user input. Note that this state might
 // be a line that was inferred, accept-and-held from the previous readline run.
 func (h *Sources) Revert() {
+	if h.noUndo {
+		return
+	}
+
 	line := h.getLineHistory()
 	if line == nil || len(line.items) == 0 {
 		return
@@ -140,6 +161,10 @@ func (h *Sources) Redo() {
 	h.skip = true
 	h.undoing = true
 
+	if h.noUndo {
+		return
+	}
+
 	line := h.getLineHistory()
 	if line == nil || len(line.items) == 0 {
 		return
@@ -231,6 +256,25 @@ func (h *Sources) getLineHistory() *lineHistory {
 	return hist[linePos]
 }
 
+// saveBuffer saves the input line before walking the history, so that it can be
+// restored when coming back to it. With undo disabled, this state replaces any
+// previous one, and is the only one kept.
+func (h *Sources) saveBuffer() {
+	if !h.noUndo {
+		h.Save()
+		return
+	}
+
+	defer h.Reset()
+
+	line := h.getLineHistory()
+	line.pos = 0
+	line.items = append(line.items[:0], undoItem{
+		line: string(*h.line),
+		pos:  h.cursor.Pos(),
+	})
+}
+
 func (h *Sources) restoreLineBuffer() {
 	h.hpos = -1
 
@@ -5,6 +5,8 @@ import "github.com/alexj212/readline/inputrc"
 // menuselectKeys are the default keymaps in menuselect mode.
 var menuselectKeys = map[string]inputrc.Bind{
 	unescape(`\C-i`):    {Action: "menu-complete"},
+	unescape(`\C-M`):    {Action: "menu-accept-line"},
+	unescape(`\C-J`):    {Action: "menu-accept-line"},
 	unescape(`\C-N`):    {Action: "menu-complete"},
 	unescape(`\C-P`):    {Action: "menu-complete-backward"},
 	unescape(`\e[Z`):    {Action: "menu-complete-backward"},
@@ -39,6 +41,8 @@ var isearchCommands = []string{
 	"accept-and-infer-next-history",
 	"accept-line",
 	"accept-and-hold",
+	"accept-and-page",
+	"accept-and-run",
 	"operate-and-get-next",
 	"history-incremental-search-forward",
 	"history-incremental-search-backward",
@@ -57,6 +61,8 @@ var isearchCommands = []string{
 var nonIsearchCommands = []string{
 	"abort",
 	"accept-line",
+	"accept-and-page",
+	"accept-and-run",
 	"backward-delete-char",
 	"backward-kill-word",
 	"backward-kill-line",
@@ -1,33 +1,66 @@
 package keymap
 
 import (
-	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"sort"
 	"strings"
 
 	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/strutil"
+	"github.com/alexj212/readline/internal/term"
 )
 
 // readline global options specific to this library.
 var readlineOptions = map[string]interface{}{
 	// General edition
-	"autopairs": false,
+	"auto-pair":                 false,
+	"auto-pair-wrap":            true,
+	"autopairs":                 false,
+	"accept-and-reopen-select":  false,
+	"replace-selection-on-type": false,
+	"datetime-format":           "2006-01-02T15:04:05Z07:00",
+	"multiline-join-separator":  "; ",
+	"block-insert-pad":          false,
+	"tab-width":                 8,
+	"tab-convert-leading-only":  true,
+	"toggle-prefix":             "sudo ",
+	"number-lines-format":       "%d. ",
+	"kill-word-punctuation":     false,
+	"kill-ring-size":            60,
+	"max-length":                0,
+	"max-length-status":         false,
 
 	// Completion
-	"autocomplete":               false,
-	"completion-list-separator":  "--",
-	"completion-selection-style": "\x1b[1;30m",
+	"autocomplete":                  false,
+	"completion-list-separator":     "--",
+	"completion-selection-style":    "\x1b[1;30m",
+	"menu-complete-accept-on-enter": "insert-and-submit",
+	"complete-common-prefix-first":  false,
+	"completion-append-space":       false,
+	"completion-autoremove-chars":   " \t;&|",
+	"completion-no-matches-hint":    "no matching completions",
+	"complete-in-word":              true,
+	"completion-async-timeout":      10,
 
 	// Prompt & General UI
-	"transient-prompt":    false,
-	"usage-hint-always":   false,
-	"history-autosuggest": false,
+	"transient-prompt":        false,
+	"usage-hint-always":       false,
+	"history-autosuggest":     false,
+	"autosuggest-style":       "",
+	"autosuggest-end-of-line": false,
+	"enable-focus-events":     false,
+	"enable-mouse":            false,
+	"confirm-default":         false,
+	"spinner-frames":          "⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏",
+	"spinner-interval":        100,
 }
 
 // ReloadConfig parses all valid .inputrc configurations and immediately
 // updates/reloads all related settings (editing mode, variables behavior, etc.)
+// Invalid lines, unknown variables and binds to unknown commands do not abort
+// parsing: they are returned, joined, and are available with Errors().
 func (m *Engine) ReloadConfig(opts ...inputrc.Option) (err error) {
 	// Builtin Go binds (in addition to default readline binds)
 	m.loadBuiltinOptions()
@@ -42,29 +75,64 @@ func (m *Engine) ReloadConfig(opts ...inputrc.Option) (err error) {
 	inputrc.UserDefault(user, m.config, inputrc.WithApp("go"))
 
 	// Parse user configurations.
-	//
-	// Those default settings are the base options often needed
-	// by /etc/inputrc on various Linux distros (for special keys).
-	defaults := []inputrc.Option{
-		inputrc.WithMode("emacs"),
-		inputrc.WithTerm(os.Getenv("TERM")),
-	}
-
-	opts = append(defaults, opts...)
+	opts = append(defaultOptions(), opts...)
 
 	// This will only overwrite binds that have been
 	// set in those configs, and leave the default ones
 	// (those just set above), so as to keep most of the
 	// default functionality working out of the box.
-	err = inputrc.UserDefault(user, m.config, opts...)
-	if err != nil {
-		return err
+	err = inputrc.UserDefault(user, m.validator(), opts...)
+	m.errs = flattenErrors(err)
+
+	m.applyConfig(true)
+
+	return err
+}
+
+// LoadConfig parses an inputrc configuration from r (like one embedded in the
+// application binary) on top of the current one, and updates related settings.
+// The main keymap is only changed if the configuration sets the editing-mode.
+// Like ReloadConfig, errors do not abort parsing, and are added to Errors().
+func (m *Engine) LoadConfig(r io.Reader, opts ...inputrc.Option) error {
+	mode := m.config.GetString("editing-mode")
+
+	opts = append(defaultOptions(), opts...)
+
+	err := inputrc.Parse(r, m.validator(), opts...)
+	m.errs = append(m.errs, flattenErrors(err)...)
+
+	m.applyConfig(mode != m.config.GetString("editing-mode"))
+
+	return err
+}
+
+// Errors returns the errors encountered while parsing the last inputrc
+// configurations loaded: each of them is an *inputrc.ParseError, giving
+// the file and line of the error, unless a file could not be read.
+func (m *Engine) Errors() []error {
+	return m.errs
+}
+
+// defaultOptions returns the base options often needed by
+// /etc/inputrc on various Linux distros (for special keys).
+func defaultOptions() []inputrc.Option {
+	return []inputrc.Option{
+		inputrc.WithMode("emacs"),
+		inputrc.WithTerm(os.Getenv("TERM")),
 	}
+}
 
+// applyConfig updates the keymaps and binds after parsing
+// a configuration, and optionally the startup editing mode.
+func (m *Engine) applyConfig(editingMode bool) {
 	// Some configuration variables might have an
 	// effect on our various keymaps and bindings.
 	m.overrideBindsSpecial()
 
+	if !editingMode {
+		return
+	}
+
 	// Startup editing mode
 	switch m.config.GetString("editing-mode") {
 	case "emacs":
@@ -72,10 +140,93 @@ func (m *Engine) ReloadConfig(opts ...inputrc.Option) (err error) {
 	case "vi":
 		m.main = ViInsert
 	}
+}
+
+// validator returns the configuration as an inputrc handler reporting
+// unknown variables and binds to commands that are not registered.
+func (m *Engine) validator() inputrc.Handler {
+	return &configValidator{Config: m.config, commands: m.commands}
+}
+
+// configValidator is an inputrc handler reporting unknown variables
+// and commands: those are still set and bound, since applications
+// might use their own variables, or register their commands later.
+type configValidator struct {
+	*inputrc.Config
+	commands map[string]func()
+}
+
+// Set satisfies the inputrc.Handler interface.
+func (v *configValidator) Set(name string, value interface{}) error {
+	known := v.Config.Get(name) != nil
+
+	if err := v.Config.Set(name, value); err != nil {
+		return err
+	}
+
+	if !known {
+		return inputrc.ErrUnknownVariable
+	}
+
+	return nil
+}
+
+// Bind satisfies the inputrc.Handler interface.
+// Since the same keys can be written with an escape or a meta prefix (\e[3~
+// and \M-[3~), any bind to an equivalent sequence is replaced by this one.
+func (v *configValidator) Bind(keymap, sequence, action string, macro bool) error {
+	if seq, bound := boundSequence(v.Config.Binds[keymap], sequence); bound && seq != sequence {
+		delete(v.Config.Binds[keymap], seq)
+	}
+
+	if err := v.Config.Bind(keymap, sequence, action, macro); err != nil {
+		return err
+	}
+
+	if _, found := v.commands[action]; !found && !macro {
+		return inputrc.ErrUnknownCommand
+	}
 
 	return nil
 }
 
+// boundSequence returns the sequence of binds matching the same keys as seq,
+// whether they are written with escape or meta prefixes, if there is one.
+func boundSequence(binds map[string]inputrc.Bind, seq string) (string, bool) {
+	if _, found := binds[seq]; found {
+		return seq, true
+	}
+
+	keys := strutil.ConvertMeta([]rune(seq))
+
+	for sequence := range binds {
+		if strutil.ConvertMeta([]rune(sequence)) == keys {
+			return sequence, true
+		}
+	}
+
+	return "", false
+}
+
+// flattenErrors returns the list of errors joined in err,
+// including those of included files, if any.
+func flattenErrors(err error) (errs []error) {
+	if err == nil {
+		return nil
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, flattenErrors(err)...)
+	}
+
+	return errs
+}
+
 // loadBuiltinOptions loads some options specific to
 // this library, if they are not loaded already.
 func (m *Engine) loadBuiltinOptions() {
@@ -106,6 +257,15 @@ func (m *Engine) loadBuiltinBinds() {
 		m.config.Binds[string(ViInsert)][seq] = bind
 	}
 
+	// Editing keys variants
+	for _, keymap := range []Mode{Emacs, ViInsert, ViCommand, ViMove, Vi} {
+		for seq, bind := range specialKeys {
+			if _, bound := boundSequence(m.config.Binds[string(keymap)], seq); !bound {
+				m.config.Binds[string(keymap)][seq] = bind
+			}
+		}
+	}
+
 	// Vim local keymaps
 	m.config.Binds[string(Visual)] = visualKeys
 	m.config.Binds[string(ViOpp)] = vioppKeys
@@ -148,7 +308,7 @@ func printBindsReadable(commands []string, all map[string][]string) {
 			}
 
 			bindsStr := strings.Join(firstBinds, ", ")
-			fmt.Printf("%s can be found on %s ...\n", command, bindsStr)
+			term.Printf("%s can be found on %s ...\n", command, bindsStr)
 
 		default:
 			var firstBinds []string
@@ -158,7 +318,7 @@ func printBindsReadable(commands []string, all map[string][]string) {
 			}
 
 			bindsStr := strings.Join(firstBinds, ", ")
-			fmt.Printf("%s can be found on %s\n", command, bindsStr)
+			term.Printf("%s can be found on %s\n", command, bindsStr)
 		}
 	}
 }
@@ -170,7 +330,7 @@ func printBindsInputrc(commands []string, all map[string][]string) {
 
 		if len(commandBinds) > 0 {
 			for _, bind := range commandBinds {
-				fmt.Printf("\"%s\": %s\n", bind, command)
+				term.Printf("\"%s\": %s\n", bind, command)
 			}
 		}
 	}
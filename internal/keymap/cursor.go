@@ -3,6 +3,8 @@ package keymap
 import (
 	"fmt"
 	"strings"
+
+	"github.com/alexj212/readline/internal/term"
 )
 
 // CursorStyle is the style of the cursor
@@ -58,14 +60,14 @@ func (m *Engine) PrintCursor(keymap Mode) {
 	modeSet := strings.TrimSpace(m.config.GetString(cursorOptname))
 
 	if _, valid := cursors[CursorStyle(modeSet)]; valid {
-		fmt.Print(cursors[CursorStyle(modeSet)])
+		term.Print(cursors[CursorStyle(modeSet)])
 		return
 	}
 
 	if defaultCur, valid := defaultCursors[keymap]; valid {
-		fmt.Print(cursors[defaultCur])
+		term.Print(cursors[defaultCur])
 		return
 	}
 
-	fmt.Print(cursors[cursor])
+	term.Print(cursors[cursor])
 }
@@ -17,6 +17,7 @@ var emacsKeys = map[string]inputrc.Bind{
 	unescape(`\C-Xr`):    {Action: "reverse-search-history"},
 	unescape(`\C-Xs`):    {Action: "forward-search-history"},
 	unescape(`\C-Xu`):    {Action: "undo"},
+	unescape(`\C-xz`):    {Action: "repeat-last-command"},
 	unescape(`\M-\C-^`):  {Action: "copy-prev-word"},
 	unescape(`\M-'`):     {Action: "quote-line"},
 	unescape(`\M-<`):     {Action: "beginning-of-buffer-or-history"},
@@ -22,11 +22,14 @@ type Engine struct {
 	iterations *core.Iterations
 	config     *inputrc.Config
 	commands   map[string]func()
+	errs       []error
 }
 
 // NewEngine is a required constructor for the keymap modes manager.
-// It initializes the keymaps to their defaults or configured values.
-func NewEngine(keys *core.Keys, i *core.Iterations, opts ...inputrc.Option) (*Engine, *inputrc.Config) {
+// It initializes the keymaps to their defaults: the inputrc configurations
+// are loaded with ReloadConfig(), once all commands have been registered,
+// so that binds to unknown commands can be reported.
+func NewEngine(keys *core.Keys, i *core.Iterations) (*Engine, *inputrc.Config) {
 	modes := &Engine{
 		main:       Emacs,
 		keys:       keys,
@@ -35,9 +38,6 @@ func NewEngine(keys *core.Keys, i *core.Iterations, opts ...inputrc.Option) (*En
 		commands:   make(map[string]func()),
 	}
 
-	// Load the inputrc configurations and set up related things.
-	modes.ReloadConfig(opts...)
-
 	return modes, modes.config
 }
 
@@ -0,0 +1,51 @@
+package keymap
+
+import "github.com/alexj212/readline/inputrc"
+
+// specialKeys are the default binds of the editing keys (Delete, Home, End,
+// PageUp, PageDown and modified arrows) in their most common encodings:
+// terminals (and curses keypad modes) do not agree on them, and modifiers
+// (Shift, Alt, Control) are encoded as a parameter, as in \e[3;5~ for
+// Control-Delete. They are added to the Emacs and Vim insert/command keymaps
+// where not bound yet, and as all builtin binds, can be overridden by user
+// inputrc files (which can also name them, as in C-Left: backward-word).
+var specialKeys = map[string]inputrc.Bind{
+	// Delete
+	unescape(`\e[3~`):   {Action: "delete-char"},
+	unescape(`\e[3;2~`): {Action: "delete-char"},
+	unescape(`\e[3;3~`): {Action: "kill-word"},
+	unescape(`\e[3;5~`): {Action: "kill-word"},
+	unescape(`\e[P`):    {Action: "delete-char"},
+
+	// Home
+	unescape(`\e[H`):    {Action: "beginning-of-line"},
+	unescape(`\eOH`):    {Action: "beginning-of-line"},
+	unescape(`\e[1~`):   {Action: "beginning-of-line"},
+	unescape(`\e[7~`):   {Action: "beginning-of-line"},
+	unescape(`\e[1;5H`): {Action: "beginning-of-buffer-or-history"},
+	unescape(`\e[1;2H`): {Action: "select-to-beginning-of-line"},
+
+	// End
+	unescape(`\e[F`):    {Action: "end-of-line"},
+	unescape(`\eOF`):    {Action: "end-of-line"},
+	unescape(`\e[4~`):   {Action: "end-of-line"},
+	unescape(`\e[8~`):   {Action: "end-of-line"},
+	unescape(`\e[1;5F`): {Action: "end-of-buffer-or-history"},
+	unescape(`\e[1;2F`): {Action: "select-to-end-of-line"},
+
+	// Arrows: Control and Alt move by words, Shift extends the selection.
+	unescape(`\e[1;5C`): {Action: "forward-word"},
+	unescape(`\e[1;5D`): {Action: "backward-word"},
+	unescape(`\e[1;3C`): {Action: "forward-word"},
+	unescape(`\e[1;3D`): {Action: "backward-word"},
+	unescape(`\e[1;2A`): {Action: "select-up-line"},
+	unescape(`\e[1;2B`): {Action: "select-down-line"},
+	unescape(`\e[1;2C`): {Action: "select-forward-char"},
+	unescape(`\e[1;2D`): {Action: "select-backward-char"},
+	unescape(`\e[1;6C`): {Action: "select-forward-word"},
+	unescape(`\e[1;6D`): {Action: "select-backward-word"},
+
+	// PageUp/PageDown
+	unescape(`\e[5~`): {Action: "up-line-or-history"},
+	unescape(`\e[6~`): {Action: "down-line-or-history"},
+}
@@ -1,13 +1,13 @@
 package macro
 
 import (
-	"fmt"
 	"sort"
 	"strings"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/color"
 	"github.com/alexj212/readline/internal/core"
+	"github.com/alexj212/readline/internal/term"
 	"github.com/alexj212/readline/internal/ui"
 )
 
@@ -18,9 +18,11 @@ var validMacroKeys = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ012345
 // recording, dumping and feeding (running) them to the shell.
 type Engine struct {
 	recording  bool
-	current    []rune          // Key sequence of the current macro being recorded.
-	currentKey rune            // The identifier of the macro being recorded.
-	macros     map[rune]string // All previously recorded macros.
+	current    []rune            // Key sequence of the current macro being recorded.
+	currentKey rune              // The identifier of the macro being recorded.
+	macros     map[rune]string   // All previously recorded macros.
+	named      map[string]string // Macros saved under a name by the user.
+	lastRun    rune              // The identifier of the last macro ran.
 	started    bool
 
 	keys   *core.Keys // The engine feeds macros directly in the key stack.
@@ -33,6 +35,7 @@ func NewEngine(keys *core.Keys, hint *ui.Hint) *Engine {
 	return &Engine{
 		current: make([]rune, 0),
 		macros:  make(map[rune]string),
+		named:   make(map[string]string),
 		keys:    keys,
 		hint:    hint,
 	}
@@ -82,14 +85,15 @@ func (e *Engine) StartRecord(key rune) {
 
 // StopRecord stops using key input as part of a macro.
 // The hint section displaying the currently saved sequence is cleared.
-func (e *Engine) StopRecord(keys ...rune) {
+// Returns true if a macro has been saved, false if no keys were recorded.
+func (e *Engine) StopRecord(keys ...rune) (saved bool) {
 	e.recording = false
 
 	// Remove the hint.
 	e.hint.ResetPersist()
 
 	if len(e.current) == 0 {
-		return
+		return false
 	}
 
 	e.current = append(e.current, keys...)
@@ -99,6 +103,8 @@ func (e *Engine) StopRecord(keys ...rune) {
 	e.macros[rune(0)] = macro
 
 	e.current = make([]rune, 0)
+
+	return true
 }
 
 // Recording returns true if the macro engine is recording the keys for a macro.
@@ -141,6 +147,68 @@ func (e *Engine) RunMacro(key rune) {
 
 	macro = strings.ReplaceAll(macro, `\e`, "\x1b")
 	e.keys.Feed(false, []rune(macro)...)
+	e.lastRun = key
+}
+
+// RunKeys feeds a raw key sequence (like the contents of a register) into the
+// shell key stack, and remembers the key as the identifier of the last macro ran.
+func (e *Engine) RunKeys(key rune, keys ...rune) {
+	if len(keys) == 0 {
+		return
+	}
+
+	e.keys.Feed(false, keys...)
+	e.lastRun = key
+}
+
+// LastRun returns the identifier of the last macro ran with RunMacro/RunKeys,
+// or a nil rune if none has been ran yet.
+func (e *Engine) LastRun() rune {
+	return e.lastRun
+}
+
+// CurrentKey returns the identifier of the macro being (or last) recorded.
+func (e *Engine) CurrentKey() rune {
+	return e.currentKey
+}
+
+// Last returns the last recorded (or set) macro, escaped in the same format
+// as the one used when printing it, or an empty string if there is none.
+func (e *Engine) Last() string {
+	return e.macros[rune(0)]
+}
+
+// SetLast replaces the last recorded macro with the given sequence, which
+// is in the inputrc escape format (like the output of Last()): it will be
+// replayed by RunLastMacro() as if it had been recorded by the user.
+// An empty sequence deletes the last macro.
+func (e *Engine) SetLast(sequence string) {
+	if sequence == "" {
+		delete(e.macros, rune(0))
+		return
+	}
+
+	e.macros[rune(0)] = inputrc.EscapeMacro(inputrc.Unescape(sequence))
+}
+
+// SaveNamed saves the last recorded macro under the given name, overwriting
+// any macro previously saved with it. Returns false if there is no macro to
+// save, or if the name is empty.
+func (e *Engine) SaveNamed(name string) bool {
+	last := e.macros[rune(0)]
+	if name == "" || last == "" {
+		return false
+	}
+
+	e.named[name] = last
+
+	return true
+}
+
+// Named returns the escaped sequence of a named macro, and true if found.
+func (e *Engine) Named(name string) (macro string, found bool) {
+	macro, found = e.named[name]
+	return
 }
 
 // PrintLastMacro dumps the last recorded macro sequence to the screen.
@@ -152,7 +220,7 @@ func (e *Engine) PrintLastMacro() {
 	// Print the macro and the prompt.
 	// The shell takes care of clearing itself
 	// before printing, and refreshing after.
-	fmt.Printf("\n%s\n", e.macros[e.currentKey])
+	term.Printf("\n%s\n", e.macros[rune(0)])
 }
 
 // PrintAllMacros dumps all macros to the screen, which one line
@@ -178,7 +246,7 @@ func (e *Engine) PrintAllMacros() {
 			macro = '"'
 		}
 
-		fmt.Printf("\"%s\": %s\n", string(macro), sequence)
+		term.Printf("\"%s\": %s\n", string(macro), sequence)
 	}
 }
 
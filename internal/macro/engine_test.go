@@ -0,0 +1,94 @@
+package macro
+
+import (
+	"testing"
+
+	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/core"
+	"github.com/alexj212/readline/internal/ui"
+)
+
+func TestEngine_SetLast(t *testing.T) {
+	tests := []struct {
+		name     string
+		sequence string
+		want     string
+	}{
+		{
+			name:     "Plain characters",
+			sequence: "abc",
+			want:     "abc",
+		},
+		{
+			name:     "Control and escape sequences",
+			sequence: `\C-a\e[Aword\r`,
+			want:     "\x01\x1b[Aword\r",
+		},
+		{
+			name:     "Escaped quotes and backslashes",
+			sequence: `\"quoted\" \\`,
+			want:     `"quoted" \`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := new(core.Keys)
+			eng := NewEngine(keys, new(ui.Hint))
+
+			eng.SetLast(test.sequence)
+
+			// The macro must be stored escaped, and round-trip.
+			last := eng.Last()
+			if got := inputrc.Unescape(last); got != test.want {
+				t.Errorf("Last() = %q, unescaped %q, want %q", last, got, test.want)
+			}
+
+			eng.SetLast(last)
+			if eng.Last() != last {
+				t.Errorf("SetLast(Last()) = %q, want %q", eng.Last(), last)
+			}
+
+			// And replaying it must feed the raw keys.
+			eng.RunLastMacro()
+
+			var fed []rune
+			for range []rune(test.want) {
+				key, _ := keys.ReadKey()
+				fed = append(fed, key)
+			}
+
+			if string(fed) != test.want {
+				t.Errorf("RunLastMacro() fed %q, want %q", string(fed), test.want)
+			}
+		})
+	}
+}
+
+func TestEngine_RecordThenSet(t *testing.T) {
+	keys := new(core.Keys)
+	eng := NewEngine(keys, new(ui.Hint))
+
+	// Record a macro like the shell would do.
+	eng.StartRecord(0)
+	eng.started = false
+	eng.current = []rune("ab\x1b")
+	eng.StopRecord()
+
+	if got, want := eng.Last(), `ab\e`; got != want {
+		t.Fatalf("Last() after recording = %q, want %q", got, want)
+	}
+
+	// Replace it and ensure the new one is used.
+	eng.SetLast("cd")
+
+	if got, want := eng.Last(), "cd"; got != want {
+		t.Errorf("Last() after SetLast = %q, want %q", got, want)
+	}
+
+	eng.SetLast("")
+
+	if got := eng.Last(); got != "" {
+		t.Errorf("Last() after deleting = %q, want empty", got)
+	}
+}
@@ -32,6 +32,34 @@ func KeywordSwitchers() []KeywordSwitcher {
 	}
 }
 
+// RotationSwitcher returns a keyword switcher replacing a word found in one of the
+// groups with the word found times after it in this group (or before it when not
+// increasing, or when times is negative), cycling through the group.
+func RotationSwitcher(groups [][]string) KeywordSwitcher {
+	return func(word string, increase bool, times int) (done bool, switched string, bpos, epos int) {
+		if times < 0 {
+			increase, times = !increase, -times
+		}
+
+		for _, group := range groups {
+			for i, item := range group {
+				if item != word {
+					continue
+				}
+
+				step := times % len(group)
+				if !increase {
+					step = len(group) - step
+				}
+
+				return true, group[(i+step)%len(group)], 0, len(word)
+			}
+		}
+
+		return
+	}
+}
+
 // AdjustNumberOperatorPos returns an adjusted cursor position when
 // the word around the cursor is an expression with an operator.
 func AdjustNumberOperatorPos(cpos int, line []rune) int {
@@ -74,6 +102,63 @@ func lineSlice(line []rune, cpos, adjust int) (slice string) {
 	return
 }
 
+// IncrementNumbers adds inc to each decimal integer found in text. A minus sign
+// is considered part of a number when not preceded by a letter or digit (so that
+// file-01 is incremented to file-02, not file-00). Numbers with leading zeros
+// keep their width (007 => 008), and those too large to parse are left unchanged.
+func IncrementNumbers(text string, inc int) string {
+	var incremented strings.Builder
+
+	digits := regexp.MustCompile(`[0-9]+`)
+	last := 0
+
+	for _, match := range digits.FindAllStringIndex(text, -1) {
+		bpos, epos := match[0], match[1]
+		number := text[bpos:epos]
+
+		if bpos > 0 && text[bpos-1] == '-' && (bpos == 1 || !isAlphaNum(text[bpos-2])) {
+			bpos--
+		}
+
+		num, err := strconv.ParseInt(text[bpos:epos], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		num += int64(inc)
+
+		switched := strconv.FormatInt(num, 10)
+
+		if len(number) > 1 && number[0] == '0' {
+			switched = fmt.Sprintf("%0*d", len(number), absInt64(num))
+
+			if num < 0 {
+				switched = "-" + switched
+			}
+		}
+
+		incremented.WriteString(text[last:bpos])
+		incremented.WriteString(switched)
+		last = epos
+	}
+
+	incremented.WriteString(text[last:])
+
+	return incremented.String()
+}
+
+func isAlphaNum(char byte) bool {
+	return (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') || (char >= '0' && char <= '9')
+}
+
+func absInt64(num int64) int64 {
+	if num < 0 {
+		return -num
+	}
+
+	return num
+}
+
 func switchNumber(word string, _ bool, times int) (done bool, switched string, bpos, epos int) {
 	if done, switched, bpos, epos = switchHexa(word, times); done {
 		return
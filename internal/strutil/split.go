@@ -20,6 +20,7 @@ var (
 	doubleChar        = '"'
 	escapeChar        = '\\'
 	doubleEscapeChars = "$`\"\n\\"
+	shellSpecialChars = " \t'\"\\$`&;|<>()*?[]{}#~!"
 )
 
 // NewlineMatcher is a regular expression matching all newlines or returned newlines.
@@ -158,3 +159,109 @@ double:
 done:
 	return buf.String(), input, nil
 }
+
+// Unquote returns the literal value of a shell word, as a shell would pass it
+// to a program: quotes are removed and backslash-escapes are resolved. Several
+// words, if any, are joined with a space. If the word has unterminated quotes
+// or escapes, it is returned unchanged along with the error.
+func Unquote(word string) (string, error) {
+	words, err := Split(word)
+	if err != nil {
+		return word, err
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// QuoteSingle returns a literal value as a single-quoted shell word.
+// Embedded single quotes are escaped with a backslash, outside of the quotes.
+func QuoteSingle(value string) string {
+	return string(singleChar) + strings.ReplaceAll(value, "'", `'\''`) + string(singleChar)
+}
+
+// QuoteDouble returns a literal value as a double-quoted shell word,
+// with the characters special within double quotes backslash-escaped.
+func QuoteDouble(value string) string {
+	var buf strings.Builder
+
+	buf.WriteRune(doubleChar)
+
+	for _, char := range value {
+		if char != '\n' && strings.ContainsRune(doubleEscapeChars, char) {
+			buf.WriteRune(escapeChar)
+		}
+
+		buf.WriteRune(char)
+	}
+
+	buf.WriteRune(doubleChar)
+
+	return buf.String()
+}
+
+// Escape returns a literal value as an unquoted shell word,
+// with blanks, quotes and shell metacharacters backslash-escaped.
+func Escape(value string) string {
+	var buf strings.Builder
+
+	for _, char := range value {
+		if strings.ContainsRune(shellSpecialChars, char) {
+			buf.WriteRune(escapeChar)
+		}
+
+		buf.WriteRune(char)
+	}
+
+	return buf.String()
+}
+
+// WordBounds returns the beginning and end (excluded) positions of the shell word
+// in line containing the position pos, following the same rules as Split: blanks
+// within quotes or escaped with a backslash do not delimit words. If pos is on a
+// blank space delimiting words, or out of the line, -1 is returned for both.
+func WordBounds(line []rune, pos int) (bpos, epos int) {
+	if pos < 0 || pos >= len(line) {
+		return -1, -1
+	}
+
+	var quote rune
+
+	bpos = -1
+
+	for i := 0; i < len(line); i++ {
+		char := line[i]
+
+		switch {
+		case quote == 0 && strings.ContainsRune(splitChars, char):
+			if pos == i {
+				return -1, -1
+			}
+
+			if bpos != -1 && pos < i {
+				return bpos, i
+			}
+
+			bpos = -1
+
+			continue
+
+		case bpos == -1:
+			bpos = i
+		}
+
+		switch {
+		case char == escapeChar && quote != singleChar:
+			i++
+		case quote == 0 && (char == singleChar || char == doubleChar):
+			quote = char
+		case char == quote:
+			quote = 0
+		}
+	}
+
+	if bpos == -1 || pos < bpos {
+		return -1, -1
+	}
+
+	return bpos, len(line)
+}
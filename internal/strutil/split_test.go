@@ -0,0 +1,37 @@
+package strutil
+
+import "testing"
+
+func TestWordBounds(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		pos      int
+		wantBpos int
+		wantEpos int
+	}{
+		{name: "First word", line: "echo foo bar", pos: 2, wantBpos: 0, wantEpos: 4},
+		{name: "Word start", line: "echo foo bar", pos: 5, wantBpos: 5, wantEpos: 8},
+		{name: "Last word", line: "echo foo bar", pos: 11, wantBpos: 9, wantEpos: 12},
+		{name: "Blank after word", line: "echo foo bar", pos: 4, wantBpos: -1, wantEpos: -1},
+		{name: "Blanks between words", line: "echo  foo bar", pos: 4, wantBpos: -1, wantEpos: -1},
+		{name: "Second of several blanks", line: "a  b c", pos: 2, wantBpos: -1, wantEpos: -1},
+		{name: "Leading blank", line: " a", pos: 0, wantBpos: -1, wantEpos: -1},
+		{name: "Trailing blank", line: "a ", pos: 1, wantBpos: -1, wantEpos: -1},
+		{name: "Quoted blank", line: `echo "foo bar" baz`, pos: 9, wantBpos: 5, wantEpos: 14},
+		{name: "Single-quoted word", line: `echo 'a b'`, pos: 6, wantBpos: 5, wantEpos: 10},
+		{name: "Escaped blank", line: `echo foo\ bar`, pos: 9, wantBpos: 5, wantEpos: 13},
+		{name: "Unclosed quote", line: `echo "foo bar`, pos: 10, wantBpos: 5, wantEpos: 13},
+		{name: "Out of line", line: "echo", pos: 4, wantBpos: -1, wantEpos: -1},
+		{name: "Negative position", line: "echo", pos: -1, wantBpos: -1, wantEpos: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bpos, epos := WordBounds([]rune(tt.line), tt.pos)
+			if bpos != tt.wantBpos || epos != tt.wantEpos {
+				t.Errorf("WordBounds() = %d, %d, want %d, %d", bpos, epos, tt.wantBpos, tt.wantEpos)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package strutil
+
+// ExpandTabs replaces tabs in line with the spaces needed to reach the next tab
+// stop, every width columns, and returns the new line and the new position of
+// pos in it. If leading is true, only tabs in the indentation of each line (the
+// whitespace at its beginning) are replaced.
+func ExpandTabs(line []rune, pos, width int, leading bool) ([]rune, int) {
+	return convertWhitespace(line, pos, width, leading, func(run []rune, bcol, ecol int) []rune {
+		return spaces(ecol - bcol)
+	})
+}
+
+// UnexpandSpaces replaces spaces in line with tabs, wherever they reach a tab
+// stop (every width columns), and returns the new line and the new position of
+// pos in it. Single spaces are never replaced. If leading is true, only the
+// indentation of each line (the whitespace at its beginning) is converted.
+func UnexpandSpaces(line []rune, pos, width int, leading bool) ([]rune, int) {
+	return convertWhitespace(line, pos, width, leading, func(run []rune, bcol, ecol int) []rune {
+		if len(run) == 1 || ecol/width == bcol/width {
+			return run
+		}
+
+		tabs := make([]rune, ecol/width-bcol/width)
+		for i := range tabs {
+			tabs[i] = '\t'
+		}
+
+		return append(tabs, spaces(ecol%width)...)
+	})
+}
+
+// convertWhitespace replaces each run of spaces and tabs in line with the result
+// of convert, called with the run and its begin and end columns. Columns are
+// counted from the beginning of each line, tabs reaching the next tab stop.
+// The position is kept on the same character, or if in a converted run, moved
+// at the end of it (on the following text).
+func convertWhitespace(line []rune, pos, width int, leading bool, convert func(run []rune, bcol, ecol int) []rune) ([]rune, int) {
+	if width <= 0 {
+		return line, pos
+	}
+
+	converted := make([]rune, 0, len(line))
+	newPos := -1
+	col, lineStart := 0, true
+
+	for i := 0; i < len(line); {
+		if line[i] != ' ' && line[i] != '\t' {
+			if i == pos {
+				newPos = len(converted)
+			}
+
+			converted = append(converted, line[i])
+			col++
+			lineStart = line[i] == '\n'
+
+			if lineStart {
+				col = 0
+			}
+
+			i++
+
+			continue
+		}
+
+		// Find the whole run and its columns.
+		bpos, bcol := i, col
+
+		for ; i < len(line) && (line[i] == ' ' || line[i] == '\t'); i++ {
+			if line[i] == '\t' {
+				col += width - col%width
+			} else {
+				col++
+			}
+		}
+
+		run := line[bpos:i]
+		if !leading || lineStart {
+			run = convert(run, bcol, col)
+		}
+
+		switch {
+		case pos == bpos:
+			newPos = len(converted)
+		case pos > bpos && pos < i:
+			newPos = len(converted) + len(run)
+		}
+
+		converted = append(converted, run...)
+		lineStart = false
+	}
+
+	if newPos == -1 {
+		newPos = len(converted)
+	}
+
+	return converted, newPos
+}
+
+func spaces(count int) []rune {
+	runes := make([]rune, count)
+	for i := range runes {
+		runes[i] = ' '
+	}
+
+	return runes
+}
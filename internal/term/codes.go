@@ -16,6 +16,16 @@ const (
 	RestoreCursorPos = "\x1b8"
 	HideCursor       = "\x1b[?25l"
 	ShowCursor       = "\x1b[?25h"
+
+	FocusEventsEnable  = "\x1b[?1004h"
+	FocusEventsDisable = "\x1b[?1004l"
+
+	MouseEventsEnable  = "\x1b[?1000h\x1b[?1006h" // Button events, in SGR mode
+	MouseEventsDisable = "\x1b[?1006l\x1b[?1000l"
+
+	Bell            = "\a"
+	VisualBellStart = "\x1b[?5h" // Reverse video for the whole screen
+	VisualBellEnd   = "\x1b[?5l"
 )
 
 // Some core keys needed by some stuff.
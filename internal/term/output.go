@@ -0,0 +1,112 @@
+package term
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Output is where all display output is written, either directly or when
+// flushing buffered output. It is the current standard output by default,
+// and can be replaced to redirect the display without touching os.Stdout.
+var Output io.Writer = stdout{}
+
+// Display output is buffered between calls to StartBuffer and EndBuffer,
+// so that a refresh is written at once instead of many small writes.
+var (
+	outBuf   bytes.Buffer
+	outDepth int
+	outMutex sync.Mutex
+)
+
+// stdout writes to whatever the standard output is at the time of the write.
+type stdout struct{}
+
+func (stdout) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// Print writes its arguments to the display output, like fmt.Print.
+// The output is buffered if called between StartBuffer and EndBuffer.
+func Print(a ...any) {
+	outMutex.Lock()
+	defer outMutex.Unlock()
+
+	if outDepth > 0 {
+		fmt.Fprint(&outBuf, a...)
+		return
+	}
+
+	fmt.Fprint(Output, a...)
+}
+
+// Printf writes a formatted string to the display output, like fmt.Printf.
+// The output is buffered if called between StartBuffer and EndBuffer.
+func Printf(format string, a ...any) (n int, err error) {
+	outMutex.Lock()
+	defer outMutex.Unlock()
+
+	if outDepth > 0 {
+		return fmt.Fprintf(&outBuf, format, a...)
+	}
+
+	return fmt.Fprintf(Output, format, a...)
+}
+
+// Println writes its arguments and a newline to the display output, like fmt.Println.
+// The output is buffered if called between StartBuffer and EndBuffer.
+func Println(a ...any) {
+	outMutex.Lock()
+	defer outMutex.Unlock()
+
+	if outDepth > 0 {
+		fmt.Fprintln(&outBuf, a...)
+		return
+	}
+
+	fmt.Fprintln(Output, a...)
+}
+
+// StartBuffer starts buffering the display output. Calls can be nested:
+// the output is flushed when the last corresponding EndBuffer is called.
+func StartBuffer() {
+	outMutex.Lock()
+	defer outMutex.Unlock()
+
+	outDepth++
+}
+
+// EndBuffer stops buffering the display output, and flushes it.
+func EndBuffer() {
+	outMutex.Lock()
+	defer outMutex.Unlock()
+
+	if outDepth > 0 {
+		outDepth--
+	}
+
+	if outDepth == 0 {
+		flush()
+	}
+}
+
+// Flush immediately writes any buffered display output, without stopping buffering.
+// This must be called before querying the terminal for something depending on the
+// output, like the cursor position.
+func Flush() {
+	outMutex.Lock()
+	defer outMutex.Unlock()
+
+	flush()
+}
+
+func flush() {
+	if outBuf.Len() == 0 {
+		return
+	}
+
+	Output.Write(outBuf.Bytes())
+	outBuf.Reset()
+}
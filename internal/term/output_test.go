@@ -0,0 +1,48 @@
+package term
+
+import (
+	"bytes"
+	"testing"
+)
+
+// countingBuffer records the writes made to it.
+type countingBuffer struct {
+	bytes.Buffer
+	writes int
+}
+
+func (b *countingBuffer) Write(p []byte) (int, error) {
+	b.writes++
+	return b.Buffer.Write(p)
+}
+
+func TestOutputBuffer(t *testing.T) {
+	out := new(countingBuffer)
+	output := Output
+	Output = out
+
+	defer func() { Output = output }()
+
+	steps := []struct {
+		name       string
+		run        func()
+		want       string
+		wantWrites int
+	}{
+		{name: "Unbuffered", run: func() { Print("a") }, want: "a", wantWrites: 1},
+		{name: "Buffered", run: func() { StartBuffer(); Print("b"); MoveCursorUp(2) }, want: "a", wantWrites: 1},
+		{name: "Nested", run: func() { StartBuffer(); Print("c"); EndBuffer() }, want: "a", wantWrites: 1},
+		{name: "Flush", run: Flush, want: "ab\x1b[2Ac", wantWrites: 2},
+		{name: "Buffered after flush", run: func() { Print("d") }, want: "ab\x1b[2Ac", wantWrites: 2},
+		{name: "End buffering", run: EndBuffer, want: "ab\x1b[2Acd", wantWrites: 3},
+		{name: "Unbalanced end", run: func() { EndBuffer(); Print("e") }, want: "ab\x1b[2Acde", wantWrites: 4},
+	}
+
+	for _, step := range steps {
+		step.run()
+
+		if got := out.String(); got != step.want || out.writes != step.wantWrites {
+			t.Errorf("%s: output = %q (%d writes), want %q (%d writes)", step.name, got, out.writes, step.want, step.wantWrites)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package term
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"golang.org/x/term"
 )
@@ -22,6 +23,9 @@ func init() {
 	stderrTerm = os.Stdin
 }
 
+// visualBellDuration is how long the screen is flashed with a visible bell.
+var visualBellDuration = 100 * time.Millisecond
+
 // fallback terminal width when we can't get it through query.
 var defaultTermWidth = 80
 
@@ -50,7 +54,22 @@ func GetLength() int {
 	return length
 }
 
+// RingBell notifies the user according to the inputrc bell-style value:
+// "audible" rings the terminal bell, "visible" flashes the screen, and
+// "none" does nothing. Other values are considered audible, like readline.
+func RingBell(style string) {
+	switch style {
+	case "none":
+	case "visible":
+		Print(VisualBellStart)
+		Flush()
+		time.Sleep(visualBellDuration)
+		Print(VisualBellEnd)
+	default:
+		Print(Bell)
+	}
+}
+
 func printf(format string, a ...interface{}) {
-	s := fmt.Sprintf(format, a...)
-	fmt.Print(s)
+	Print(fmt.Sprintf(format, a...))
 }
@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/alexj212/readline/internal/color"
@@ -15,6 +14,7 @@ import (
 type Hint struct {
 	text       []rune
 	persistent []rune
+	status     []rune
 	cleanup    bool
 	temp       bool
 	set        bool
@@ -42,11 +42,29 @@ func (h *Hint) Persist(hint string) {
 	h.persistent = []rune(hint)
 }
 
+// SetStatus sets a status message (like the progress of background work),
+// displayed above the other hint sections until set again: unlike persistent
+// hints, it is not reset by commands. An empty status removes it.
+func (h *Hint) SetStatus(status string) {
+	h.cleanup = h.cleanup || (len(h.status) > 0 && status == "")
+	h.status = []rune(status)
+}
+
 // Text returns the current hint text.
 func (h *Hint) Text() string {
 	return string(h.text)
 }
 
+// Persistent returns the current persistent hint.
+func (h *Hint) Persistent() string {
+	return string(h.persistent)
+}
+
+// Status returns the current status message.
+func (h *Hint) Status() string {
+	return string(h.status)
+}
+
 // Len returns the length of the current hint.
 // This is generally used by consumers to know if there already
 // is an active hint, in which case they might want to append to
@@ -76,9 +94,9 @@ func DisplayHint(hint *Hint) {
 		hint.Reset()
 	}
 
-	if len(hint.text) == 0 && len(hint.persistent) == 0 {
+	if len(hint.text) == 0 && len(hint.persistent) == 0 && len(hint.status) == 0 {
 		if hint.cleanup {
-			fmt.Print(term.ClearLineAfter)
+			term.Print(term.ClearLineAfter)
 		}
 
 		hint.cleanup = false
@@ -95,11 +113,15 @@ func DisplayHint(hint *Hint) {
 	text += term.ClearLineAfter + color.Reset
 
 	if len(text) > 0 {
-		fmt.Print(text)
+		term.Print(text)
 	}
 }
 
 func (h *Hint) renderHint() (text string) {
+	if len(h.status) > 0 {
+		text += string(h.status) + term.NewlineReturn
+	}
+
 	if len(h.persistent) > 0 {
 		text += string(h.persistent) + term.NewlineReturn
 	}
@@ -4,20 +4,31 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/color"
 	"github.com/alexj212/readline/internal/core"
 	"github.com/alexj212/readline/internal/keymap"
 	"github.com/alexj212/readline/internal/strutil"
 	"github.com/alexj212/readline/internal/term"
 )
 
+// promptEllipsis replaces the beginning of a prompt too wide for the terminal.
+const promptEllipsis = '…'
+
 // Prompt stores all prompt rendering/generation functions and is
 // in charge of displaying them, as well as computing their offsets.
 type Prompt struct {
 	primaryF    func() string
 	primaryRows int
 	primaryCols int
+	modes       map[keymap.Mode]string
+
+	cachedF    func() string
+	cache      string     // The last string returned by cachedF.
+	cacheValid bool       // The cache is valid until the next prompt.
+	cacheMutex sync.Mutex // The cache can be invalidated from other goroutines.
 
 	secondaryF func() string
 	transientF func() string
@@ -50,6 +61,42 @@ func (p *Prompt) Primary(prompt func() string) {
 	p.primaryF = prompt
 }
 
+// Cached uses a function returning a part of the primary prompt that is costly to
+// compute (eg. the current directory or the status of a repository): it is resolved
+// once per prompt, and cached until the next one or until InvalidateCache is called,
+// while the primary prompt function (or the mode prompt) is called on each redisplay.
+// The resulting prompt is the cached part followed by the primary one.
+func (p *Prompt) Cached(prompt func() string) {
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	p.cachedF = prompt
+	p.cacheValid = false
+}
+
+// InvalidateCache drops the cached part of the primary prompt,
+// so that it is resolved again the next time it is displayed.
+func (p *Prompt) InvalidateCache() {
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	p.cacheValid = false
+}
+
+// Mode sets the primary prompt to use when the given keymap is the main one,
+// in place of the primary prompt function. An empty prompt removes it.
+func (p *Prompt) Mode(mode keymap.Mode, prompt string) {
+	if p.modes == nil {
+		p.modes = make(map[keymap.Mode]string)
+	}
+
+	if prompt == "" {
+		delete(p.modes, mode)
+	} else {
+		p.modes[mode] = prompt
+	}
+}
+
 // Right uses a function returning the string to use as the right prompt.
 func (p *Prompt) Right(prompt func() string) {
 	p.rightF = prompt
@@ -90,12 +137,11 @@ func (p *Prompt) Tooltip(prompt func(word string) string) {
 func (p *Prompt) PrimaryPrint() {
 	p.refreshing = false
 
-	if p.primaryF == nil {
+	prompt, found := p.primary()
+	if !found {
 		return
 	}
 
-	prompt := p.primaryF()
-
 	prompt, lastPrompt := p.formatPrimaryLines(prompt)
 
 	// Format the last line with the editing status.
@@ -103,13 +149,13 @@ func (p *Prompt) PrimaryPrint() {
 
 	// Print the various lines.
 	if prompt != "" {
-		fmt.Print(prompt)
+		term.Print(prompt)
 	}
 
-	fmt.Print(lastPrompt)
+	term.Print(lastPrompt)
 
 	// And compute coordinates
-	p.primaryRows = strings.Count(prompt, "\n")
+	p.primaryRows = promptRows(prompt, term.GetWidth())
 	p.primaryCols = strutil.RealLength(lastPrompt)
 
 	if p.primaryCols > 0 {
@@ -124,18 +170,32 @@ func (p *Prompt) PrimaryUsed() int {
 	return p.primaryRows
 }
 
+// PrimaryString returns the primary prompt string, with all its lines,
+// as printed by PrimaryPrint and LastPrint.
+func (p *Prompt) PrimaryString() string {
+	prompt, found := p.primary()
+	if !found {
+		return ""
+	}
+
+	prompt, lastPrompt := p.formatPrimaryLines(prompt)
+
+	return prompt + p.formatLastPrompt(lastPrompt)
+}
+
 // LastPrint prints the last line of the primary prompt, if the latter
 // spans on several lines. If not, this function will actually print
 // the entire primary prompt, and PrimaryPrint() will not print anything.
 func (p *Prompt) LastPrint() {
-	if p.primaryF == nil {
+	primary, found := p.primary()
+	if !found {
 		return
 	}
 
 	// Only display the last line, but overwrite the number of
 	// rows used since any redisplay of all lines but the last
 	// will trigger their  own recomputation.
-	lines := strings.Split(p.primaryF(), "\n")
+	lines := strings.Split(primary, "\n")
 
 	// Print the prompt and compute columns.
 	if len(lines) == 0 {
@@ -144,7 +204,7 @@ func (p *Prompt) LastPrint() {
 
 	prompt := p.formatLastPrompt(lines[len(lines)-1])
 
-	fmt.Print(prompt)
+	term.Print(prompt)
 
 	p.primaryCols = strutil.RealLength(prompt)
 	if p.primaryCols > 0 {
@@ -157,14 +217,15 @@ func (p *Prompt) LastPrint() {
 // This, in effect, returns the X coordinate at which the input line
 // should be printed, and indentation for subsequent lines if several.
 func (p *Prompt) LastUsed() int {
-	if p.primaryF == nil {
+	primary, found := p.primary()
+	if !found {
 		return 0
 	}
 
 	// Only display the last line, but overwrite the number of
 	// rows used since any redisplay of all lines but the last
 	// will trigger their  own recomputation.
-	lines := strings.Split(p.primaryF(), "\n")
+	lines := strings.Split(primary, "\n")
 	if len(lines) == 0 {
 		return 0
 	}
@@ -199,9 +260,9 @@ func (p *Prompt) RightPrint(startColumn int, force bool) {
 	}
 
 	if prompt, canPrint := p.formatRightPrompt(rprompt, startColumn); canPrint {
-		fmt.Print(prompt)
+		term.Print(prompt)
 	} else {
-		fmt.Print(term.ClearLineAfter)
+		term.Print(term.ClearLineAfter)
 	}
 }
 
@@ -214,10 +275,10 @@ func (p *Prompt) TransientPrint() {
 	// Clean everything below where the prompt will be printed.
 	term.MoveCursorBackwards(term.GetWidth())
 	term.MoveCursorUp(p.primaryRows)
-	fmt.Print(term.ClearScreenBelow)
+	term.Print(term.ClearScreenBelow)
 
 	// And print the prompt
-	fmt.Print(p.transientF())
+	term.Print(p.transientF())
 }
 
 // Refreshing returns true if the prompt is currently redisplaying
@@ -226,9 +287,53 @@ func (p *Prompt) Refreshing() bool {
 	return p.refreshing
 }
 
+// primary returns the primary prompt set for the current main keymap if any,
+// or the one returned by the primary prompt function, after the cached part.
+// The prompt set for the emacs keymap is also used in other emacs-* ones, and
+// the one for vi-command in vi and vi-move.
+func (p *Prompt) primary() (prompt string, found bool) {
+	main := p.keymaps.Main()
+
+	switch {
+	case p.keymaps.IsEmacs():
+		main = keymap.Emacs
+	case main == keymap.Vi, main == keymap.ViMove:
+		main = keymap.ViCommand
+	}
+
+	cached := p.cached()
+
+	if prompt, found = p.modes[main]; found {
+		return cached + prompt, true
+	}
+
+	if p.primaryF == nil {
+		return cached, cached != ""
+	}
+
+	return cached + p.primaryF(), true
+}
+
+// cached returns the cached part of the primary prompt, resolving it if needed.
+func (p *Prompt) cached() string {
+	p.cacheMutex.Lock()
+	defer p.cacheMutex.Unlock()
+
+	if p.cachedF == nil {
+		return ""
+	}
+
+	if !p.cacheValid {
+		p.cache = p.cachedF()
+		p.cacheValid = true
+	}
+
+	return p.cache
+}
+
 func (p *Prompt) formatLastPrompt(prompt string) string {
 	if !p.opts.GetBool("show-mode-in-prompt") {
-		return prompt
+		return fitPrompt(prompt, term.GetWidth())
 	}
 
 	var status string
@@ -254,7 +359,57 @@ func (p *Prompt) formatLastPrompt(prompt string) string {
 	status = end.ReplaceAllString(status, "")
 	status = strings.ReplaceAll(status, "\\e", "\x1b")
 
-	return status + prompt
+	return fitPrompt(status+prompt, term.GetWidth())
+}
+
+// fitPrompt truncates the last line of the prompt if it is as wide as the terminal,
+// or wider: its beginning is replaced by an ellipsis, keeping half of the width for
+// the input line, which always starts on the same row. Colors are all kept.
+func fitPrompt(prompt string, width int) string {
+	length := strutil.RealLength(prompt)
+	if length < width || width < 2 {
+		return prompt
+	}
+
+	// Hide enough columns for the ellipsis too.
+	hidden := length - width/2 + 1
+
+	runes := []rune(prompt)
+	colors := color.Positions(prompt)
+	fitted := []rune{promptEllipsis}
+
+	for i := 0; i < len(runes); i++ {
+		if len(colors) > 0 && colors[0][0] == i {
+			fitted = append(fitted, runes[i:colors[0][1]]...)
+			i = colors[0][1] - 1
+			colors = colors[1:]
+
+			continue
+		}
+
+		if hidden > 0 {
+			hidden -= strutil.RealLength(string(runes[i]))
+			continue
+		}
+
+		fitted = append(fitted, runes[i])
+	}
+
+	return string(fitted)
+}
+
+// promptRows returns the number of terminal rows used by the lines of a prompt
+// before its last one, those wider than the terminal wrapping on several rows.
+func promptRows(lines string, width int) (rows int) {
+	if lines == "" || width < 1 {
+		return strings.Count(lines, "\n")
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(lines, "\n"), "\n") {
+		rows += max(1, (strutil.RealLength(line)+width-1)/width)
+	}
+
+	return rows
 }
 
 func (p *Prompt) formatRightPrompt(rprompt string, startColumn int) (prompt string, canPrint bool) {
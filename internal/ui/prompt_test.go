@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/strutil"
+)
+
+func TestFitPrompt(t *testing.T) {
+	long := color.FgBlue + "user@host:/home/user/src/readline" + color.Reset + " $ "
+
+	tests := []struct {
+		name   string
+		prompt string
+		width  int
+		want   string
+	}{
+		{name: "Fits", prompt: "$ ", width: 20, want: "$ "},
+		{name: "One column left", prompt: strings.Repeat("x", 19), width: 20, want: strings.Repeat("x", 19)},
+		{name: "As wide as terminal", prompt: strings.Repeat("x", 20), width: 20, want: "…" + strings.Repeat("x", 9)},
+		{name: "Twice as wide", prompt: long, width: 20, want: "…" + color.FgBlue + "adline" + color.Reset + " $ "},
+		{name: "Wide characters", prompt: strings.Repeat("世", 20), width: 20, want: "…" + strings.Repeat("世", 4)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := fitPrompt(test.prompt, test.width)
+			if got != test.want {
+				t.Errorf("fitPrompt() = %q, want %q", got, test.want)
+			}
+
+			// The first character typed lands on the prompt row, after it.
+			if column := strutil.RealLength(got); column >= test.width {
+				t.Errorf("input starts at column %d, want less than %d", column, test.width)
+			}
+		})
+	}
+}
+
+func TestPromptRows(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines string
+		want  int
+	}{
+		{name: "No lines", lines: "", want: 0},
+		{name: "Short lines", lines: "one\ntwo\n", want: 2},
+		{name: "Empty line", lines: "\n", want: 1},
+		{name: "Exactly as wide", lines: strings.Repeat("x", 20) + "\n", want: 1},
+		{name: "Wrapping line", lines: color.Bold + strings.Repeat("x", 40) + color.Reset + "\nshort\n", want: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := promptRows(test.lines, 20); got != test.want {
+				t.Errorf("promptRows() = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
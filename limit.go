@@ -0,0 +1,103 @@
+package readline
+
+import (
+	"fmt"
+
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/keymap"
+	"github.com/alexj212/readline/internal/term"
+)
+
+// SetMaxLength sets the maximum number of characters of the input line, like
+// the max-length inputrc variable, which it overwrites: text inserted by any
+// command (typed, yanked, pasted, completed, recalled from history, etc) which
+// would make the line longer is truncated to fit, with a hint and the bell
+// (according to the bell-style) when anything is rejected. With the option
+// max-length-status, the characters left are displayed below the line.
+// A limit of 0 (the default) means no limit.
+func (rl *Shell) SetMaxLength(n int) {
+	rl.Config.Set("max-length", n)
+}
+
+// lengthLimited returns true if the line has a maximum length,
+// unless the line being edited is that of a search.
+func (rl *Shell) lengthLimited() bool {
+	if rl.Config.GetInt("max-length") <= 0 {
+		return false
+	}
+
+	searching, _, _ := rl.completer.NonIncrementallySearching()
+
+	return !searching && rl.Keymap.Local() != keymap.Isearch
+}
+
+// enforceMaxLength truncates the text inserted in the line by the last command,
+// compared with the line before it, if the line has grown past its maximum length,
+// and notifies the user. Positions after the truncated text are moved back with it.
+func (rl *Shell) enforceMaxLength(before []rune) {
+	limit := rl.Config.GetInt("max-length")
+	after := *rl.line
+
+	if len(after) <= max(limit, len(before)) {
+		rl.lengthRejected = false
+		return
+	}
+
+	// The inserted text is between the parts common to both lines.
+	prefix := 0
+	for prefix < len(before) && after[prefix] == before[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(before)-prefix && after[len(after)-1-suffix] == before[len(before)-1-suffix] {
+		suffix++
+	}
+
+	excess := len(after) - max(limit, len(before))
+	end := len(after) - suffix
+	start := end - excess
+
+	// Positions within the truncated text are brought back to its start.
+	shift := func(pos int) int {
+		switch {
+		case pos >= end:
+			return pos - excess
+		case pos > start:
+			return start
+		default:
+			return pos
+		}
+	}
+
+	yankStart, yankEnd := rl.yankedEnd-len(rl.yanked), rl.yankedEnd
+	cursor := rl.cursor.Pos()
+
+	rl.line.Cut(start, end)
+	rl.cursor.Set(shift(cursor))
+
+	if yankStart >= 0 && yankEnd <= len(after) {
+		rl.yankedEnd = shift(yankEnd)
+		rl.yanked = append([]rune{}, (*rl.line)[shift(yankStart):rl.yankedEnd]...)
+	}
+
+	rl.Hint.SetTemporary(color.FgRed + fmt.Sprintf("Maximum length reached (%d characters)", limit))
+
+	// A single bell for consecutive rejections, like the keys of a paste.
+	if !rl.lengthRejected {
+		term.RingBell(rl.Config.GetString("bell-style"))
+	}
+
+	rl.lengthRejected = true
+}
+
+// maxLengthStatus returns the hint giving the number of characters left
+// in the line, if it has a maximum length and max-length-status is on.
+func (rl *Shell) maxLengthStatus() string {
+	limit := rl.Config.GetInt("max-length")
+	if limit <= 0 || !rl.Config.GetBool("max-length-status") {
+		return ""
+	}
+
+	return color.Dim + fmt.Sprintf("(%d characters left)", max(limit-rl.line.Len(), 0))
+}
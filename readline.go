@@ -21,7 +21,9 @@ package readline
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/color"
@@ -38,6 +40,10 @@ import (
 // is pressed on the keyboard. The sequence is usually Ctrl-C.
 var ErrInterrupt = errors.New(os.Interrupt.String())
 
+// ErrEscape is returned by ReadChar when the key read is an escape,
+// or when no key could be read.
+var ErrEscape = errors.New("escape")
+
 // Readline displays the readline prompt and reads user input.
 // It can return from the call because of different things:
 //
@@ -57,17 +63,46 @@ func (rl *Shell) Readline() (string, error) {
 	}
 	defer term.Restore(descriptor, state)
 
-	// Prompts and cursor styles
+	rl.termState = state
+	defer func() { rl.termState = nil }()
+
+	// Prompts and cursor styles, resolving again the cached prompt.
+	rl.Prompt.InvalidateCache()
 	rl.Display.PrintPrimaryPrompt()
 	defer rl.Display.RefreshTransient()
-	defer fmt.Print(keymap.CursorStyle("default"))
+	defer term.Print(keymap.CursorStyle("default"))
 
 	rl.init()
 
+	// Terminal focus events
+	if rl.Config.GetBool("enable-focus-events") {
+		core.SetFocusHandler(rl.Keys, rl.focusChanged)
+		term.Print(term.FocusEventsEnable)
+
+		defer term.Print(term.FocusEventsDisable)
+		defer core.SetFocusHandler(rl.Keys, nil)
+	}
+
+	// Mouse clicks and scrolls
+	if rl.Config.GetBool("enable-mouse") {
+		term.Print(term.MouseEventsEnable)
+		defer term.Print(term.MouseEventsDisable)
+	}
+
 	// Terminal resize events
 	resize := display.WatchResize(rl.Display)
 	defer close(resize)
 
+	// Commands and display run with the shell locked, except when
+	// waiting for input keys, where Refresh() can be used instead.
+	rl.mutex.Lock()
+	rl.reading.Store(true)
+
+	defer func() {
+		rl.reading.Store(false)
+		rl.unlock()
+	}()
+
 	for {
 		// Whether or not the command is resolved, let the macro
 		// engine record the keys if currently recording a macro.
@@ -81,48 +116,272 @@ func (rl *Shell) Readline() (string, error) {
 
 		// Since we always update helpers after being asked to read
 		// for user input again, we do it before actually reading it.
-		rl.Display.Refresh()
+		// Keys already available (pastes, macros) are run at once.
+		if rl.mustRefresh() {
+			rl.Display.Refresh()
+		}
 
 		// Block and wait for available user input keys.
 		// These might be read on stdin, or already available because
 		// the macro engine has fed some keys in bulk when running one.
+		rl.unlock()
 		core.WaitAvailableKeys(rl.Keys, rl.Config)
+		rl.mutex.Lock()
 
-		// 1 - Local keymap (Completion/Isearch/Vim operator pending).
-		bind, command, prefixed := keymap.MatchLocal(rl.Keymap)
-		if prefixed {
+		// Mouse events are not keys: if we only read
+		// those, go back to refreshing and reading.
+		if rl.handleMouse(core.PopMouseEvents(rl.Keys)) {
 			continue
 		}
 
-		accepted, line, err := rl.run(false, bind, command)
+		// Match the keys against binds and run the resulting command.
+		_, accepted, line, err := rl.dispatch()
 		if accepted {
 			return line, err
-		} else if command != nil {
-			continue
 		}
+	}
+}
+
+// ReadlineWithDefault is like Readline, but the input line is prefilled with
+// initial, as if the user had typed it, with the cursor at the given position.
+// The position is a rune index in initial (not a byte or column offset): 0 is
+// before the first character, and the length of initial is at its end; if out
+// of range, the position is brought back to the closest of these two ends.
+// The default line is what revert-line goes back to. To have the default line
+// replaced if the user starts typing, select it first with the select-all
+// command, and set the replace-selection-on-type option.
+func (rl *Shell) ReadlineWithDefault(initial string, cursor int) (string, error) {
+	rl.setInitial(initial, cursor)
+
+	// Don't keep it for the next call if failing early.
+	defer func() { rl.initial, rl.hasInitial = nil, false }()
+
+	return rl.Readline()
+}
+
+// setInitial sets the line and cursor position to start the next read with.
+func (rl *Shell) setInitial(initial string, cursor int) {
+	rl.initial = []rune(initial)
+	rl.initialPos = max(0, min(cursor, len(rl.initial)))
+	rl.hasInitial = true
+}
+
+// ReadChar reads a single key typed by the user and returns it, for instance to
+// implement "press any key to continue" interactions between calls to Readline.
+// The terminal is put in raw mode while reading, if needed. The key is returned
+// along with ErrInterrupt if it is Ctrl-C, io.EOF if it is Ctrl-D, and ErrEscape
+// if it is an escape (or if reading failed). Only the first key of a sequence
+// (like an arrow key) is returned, the rest of it being dropped.
+//
+// ReadChar can be called by commands run by the shell. If it is called while
+// Readline() is waiting for input in another goroutine, the next key typed is
+// returned by ReadChar instead of being processed by Readline().
+func (rl *Shell) ReadChar() (rune, error) {
+	if !rl.reading.Load() && !core.AvailableKeys(rl.Keys) {
+		descriptor := int(os.Stdin.Fd())
+
+		state, err := term.MakeRaw(descriptor)
+		if err != nil {
+			return 0, err
+		}
+		defer term.Restore(descriptor, state)
+	}
 
-		// Past the local keymap, our actions have a direct effect
-		// on the line or on the cursor position, so we must first
-		// "reset" or accept any completion state we're in, if any,
-		// such as a virtually inserted candidate.
-		completion.UpdateInserted(rl.completer)
+	key, isAbort := rl.Keys.ReadKey()
 
-		// 2 - Main keymap (Vim command/insertion, Emacs).
-		bind, command, prefixed = keymap.MatchMain(rl.Keymap)
-		if prefixed {
+	switch {
+	case key == inputrc.Encontrol('c'):
+		return key, ErrInterrupt
+	case key == inputrc.Encontrol('d'):
+		return key, io.EOF
+	case isAbort, key == inputrc.Esc:
+		return key, ErrEscape
+	}
+
+	return key, nil
+}
+
+// Confirm prints a question followed by a [y/n] indicator and reads a single key
+// for the answer, printed after the question, until y or n (in any case) is typed.
+// Enter gives the default answer, which is no unless the confirm-default option
+// is on, and is displayed in uppercase in the indicator. Other keys are ignored.
+// Ctrl-C, Ctrl-D and escape abort with the same errors as ReadChar, and false,
+// the first two being echoed in caret notation if echo-control-characters is on.
+//
+// Like ReadChar, Confirm is meant to be called between calls to Readline, or by
+// commands run by the shell, and puts the terminal in raw mode if needed.
+func (rl *Shell) Confirm(question string) (bool, error) {
+	yes := rl.Config.GetBool("confirm-default")
+
+	indicator := "[y/N]"
+	if yes {
+		indicator = "[Y/n]"
+	}
+
+	term.Printf("%s %s ", question, indicator)
+
+	for {
+		key, err := rl.ReadChar()
+		if err != nil {
+			if !errors.Is(err, ErrEscape) {
+				rl.echoControlKey(key)
+			}
+
+			term.Println()
+			return false, err
+		}
+
+		switch key {
+		case 'y', 'Y':
+			yes = true
+		case 'n', 'N':
+			yes = false
+		case inputrc.Return, inputrc.Newline:
+		default:
 			continue
 		}
 
-		accepted, line, err = rl.run(true, bind, command)
+		if yes {
+			term.Println("y")
+		} else {
+			term.Println("n")
+		}
+
+		return yes, nil
+	}
+}
+
+// Process runs the shell over a fixed sequence of input keys, as if they were
+// typed by the user, and returns the resulting line. No terminal is required:
+// the input line is never displayed, and the process standard streams are not
+// used while running (all output is discarded, and reading keys returns EOF).
+// This is mostly useful for testing keybindings in a deterministic way.
+//
+// Since the shell display output and keys input are shared by all shells, and
+// are replaced while running, Process is not safe for concurrent use, either with
+// other calls to it (even on different shells) or with Readline(). Other writes
+// to the process standard streams, like goroutines printing, are not affected.
+//
+// Processing stops on the first accept-line (or any other accept-* command),
+// or on EOF/interrupt commands, which return their usual errors. If the input
+// is exhausted before the line is accepted, the current line is returned.
+func (rl *Shell) Process(input string) (string, error) {
+	restore := discardTerminal()
+	defer restore()
+
+	rl.Prompt.InvalidateCache()
+	rl.init()
+
+	// Drop any keys still pending if we did not accept.
+	defer func() {
+		for {
+			if _, empty := core.PopForce(rl.Keys); empty {
+				return
+			}
+		}
+	}()
+
+	rl.Keys.Feed(false, []rune(input)...)
+
+	for {
+		macro.RecordKeys(rl.Macros)
+		core.FlushUsed(rl.Keys)
+
+		if _, empty := core.PeekKey(rl.Keys); empty {
+			return string(*rl.line), nil
+		}
+
+		// A prefix match means that we would normally wait
+		// for more keys, but we have none left to read.
+		prefixed, accepted, line, err := rl.dispatch()
 		if accepted {
 			return line, err
+		} else if prefixed {
+			return string(*rl.line), nil
 		}
+	}
+}
+
+// dispatch matches the keys in the stack against the local keymap, then the
+// main one, and runs the resulting command. Returns true if the keys matched
+// by prefix only (more keys are needed), and if the line has been accepted.
+// This is the only path through which keys are run: Readline() and Process()
+// both use it, so that binds and commands behave the same regardless of caller.
+func (rl *Shell) dispatch() (prefixed, accepted bool, line string, err error) {
+	// 0 - User key interceptor, if any.
+	if !rl.interceptKeys() {
+		return
+	}
+
+	// Template placeholders navigation, if any.
+	if rl.jumpTabstop() {
+		return
+	}
 
-		// Reaching this point means the last key/sequence has not
-		// been dispatched down to a command: therefore this key is
-		// undefined for the current local/main keymaps.
-		rl.handleUndefined(bind, command)
+	// Repeated presses of the last key of repeat-last-command.
+	if bind, command, repeat := rl.matchRepeat(); repeat {
+		accepted, line, err = rl.run(true, bind, command)
+		return
+	}
+
+	// Selectable list keys, if any.
+	if handled, done, selectErr := rl.selectKey(); handled {
+		return false, done, "", selectErr
 	}
+
+	// 1 - Local keymap (Completion/Isearch/Vim operator pending).
+	bind, command, prefixed := keymap.MatchLocal(rl.Keymap)
+	if prefixed {
+		return
+	}
+
+	accepted, line, err = rl.run(false, bind, command)
+	if accepted || command != nil {
+		return
+	}
+
+	// Past the local keymap, our actions have a direct effect
+	// on the line or on the cursor position, so we must first
+	// "reset" or accept any completion state we're in, if any,
+	// such as a virtually inserted candidate.
+	completion.UpdateInserted(rl.completer)
+
+	// 2 - Main keymap (Vim command/insertion, Emacs).
+	bind, command, prefixed = keymap.MatchMain(rl.Keymap)
+	if prefixed {
+		return
+	}
+
+	accepted, line, err = rl.run(true, bind, command)
+	if accepted {
+		return
+	}
+
+	// Reaching this point means the last key/sequence has not
+	// been dispatched down to a command: therefore this key is
+	// undefined for the current local/main keymaps.
+	rl.handleUndefined(bind, command)
+
+	return
+}
+
+// interceptKeys passes the pending keys to the user key interceptor, if any,
+// and replaces them as requested. Returns false if no keys are left to match.
+func (rl *Shell) interceptKeys() (pending bool) {
+	if rl.interceptor == nil {
+		return true
+	}
+
+	consumed, replacement := rl.interceptor(core.PendingKeys(rl.Keys))
+	if consumed {
+		core.DropPending(rl.Keys)
+	}
+
+	rl.Keys.Feed(true, replacement...)
+
+	_, empty := core.PeekKey(rl.Keys)
+
+	return !empty
 }
 
 // init gathers all steps to perform at the beginning of readline loop.
@@ -135,17 +394,42 @@ func (rl *Shell) init() {
 	rl.selection.Reset()
 	rl.Buffers.Reset()
 	rl.History.Reset()
+	rl.Iterations.SetSticky(false)
 	rl.Iterations.Reset()
 
 	// Some accept-* commands must fetch a specific
 	// line outright, or keep the accepted one.
 	history.Init(rl.History)
+
+	// A default line, if any, replaces the inferred/held one.
+	if rl.hasInitial {
+		rl.line.Set(rl.initial...)
+		rl.cursor.Set(rl.initialPos)
+		rl.initial, rl.hasInitial = nil, false
+	}
+
 	rl.History.Save()
+	rl.startTemplate()
+
+	// The line kept by accept-and-reopen might be selected,
+	// so that typing replaces it instead of adding to it.
+	if rl.reopen && rl.Config.GetBool("accept-and-reopen-select") {
+		rl.selectLine()
+	}
+
+	rl.reopen = false
 
 	// Reset/initialize user interface components.
 	rl.Hint.Reset()
+	rl.lengthRejected = false
+
+	if status := rl.maxLengthStatus(); status != "" {
+		rl.Hint.Persist(status)
+	}
+
 	rl.completer.ResetForce()
 	display.Init(rl.Display, rl.SyntaxHighlighter)
+	rl.startSelect()
 }
 
 // run wraps the execution of a target command/sequence with various pre/post actions
@@ -169,10 +453,27 @@ func (rl *Shell) run(main bool, bind inputrc.Bind, command func()) (bool, string
 	// so it knows which line and cursor we should work on.
 	rl.line, rl.cursor, rl.selection = rl.completer.GetBuffer()
 
+	// Keep the line as it was, to truncate what the command inserts.
+	var before []rune
+	if rl.lengthLimited() {
+		before = append(before, *rl.line...)
+	}
+
 	// The command might be nil, because the provided key sequence
 	// did not match any. We regardless execute everything related
 	// to the command, like any pending ones, and cursor checks.
+	matched, times := len(rl.Keys.Caller()), rl.Iterations.Times()
+
 	rl.execute(command)
+	rl.recordCommand(bind, command, matched, times)
+
+	// Numeric arguments don't break sequences of kills or yanks.
+	if !rl.Iterations.IsPending() {
+		rl.Buffers.CommandDone()
+	}
+
+	rl.notifyWidget(bind, command)
+	rl.updateTabstops()
 
 	// Either print/clear iterations/active registers hints.
 	rl.updatePosRunHints()
@@ -185,6 +486,10 @@ func (rl *Shell) run(main bool, bind inputrc.Bind, command func()) (bool, string
 	// return the correct input line and cursor.
 	rl.line, rl.cursor, rl.selection = rl.completer.GetBuffer()
 
+	if rl.lengthLimited() {
+		rl.enforceMaxLength(before)
+	}
+
 	// History: save the last action to the line history,
 	// and return with the call to the history system that
 	// checks if the line has been accepted (entered), in
@@ -217,23 +522,100 @@ func (rl *Shell) execute(command func()) {
 	}
 }
 
+// focusChanged passes terminal focus events to the user handler, if any.
+func (rl *Shell) focusChanged(focused bool) {
+	if rl.OnFocus != nil {
+		rl.OnFocus(focused)
+	}
+}
+
+// handleMouse moves the cursor to the position of left clicks on the input line,
+// and feeds history navigation keys on wheel scrolls. Returns true if events were
+// handled only and no keys are available.
+func (rl *Shell) handleMouse(events []core.MouseEvent) bool {
+	if len(events) == 0 {
+		return false
+	}
+
+	for _, event := range events {
+		if !event.Pressed {
+			continue
+		}
+
+		switch event.Button {
+		case core.MouseLeft:
+			pos, onLine := rl.Display.LinePosition(event.X, event.Y)
+			if !onLine {
+				continue
+			}
+
+			completion.UpdateInserted(rl.completer)
+			rl.cursor.Set(pos)
+
+			if rl.Keymap.IsEmacs() || rl.Keymap.Main() == keymap.ViInsert {
+				rl.cursor.CheckAppend()
+			} else {
+				rl.cursor.CheckCommand()
+			}
+		case core.MouseWheelUp:
+			rl.Keys.Feed(false, []rune(term.ArrowUp)...)
+		case core.MouseWheelDown:
+			rl.Keys.Feed(false, []rune(term.ArrowDown)...)
+		}
+	}
+
+	_, empty := core.PeekKey(rl.Keys)
+
+	return empty
+}
+
+// notifyWidget passes the command just run and its keys to the widget observer, if any.
+func (rl *Shell) notifyWidget(bind inputrc.Bind, command func()) {
+	if rl.onWidget == nil || command == nil || bind.Macro {
+		return
+	}
+
+	caller := rl.Keys.Caller()
+	keys := make([]rune, len(caller))
+	copy(keys, caller)
+
+	rl.onWidget(bind.Action, keys)
+}
+
 // Some commands show their current status as a hint (iterations/macro).
 func (rl *Shell) updatePosRunHints() {
 	hint := core.ResetPostRunIterations(rl.Iterations)
 	register, selected := rl.Buffers.IsSelected()
 
-	if hint == "" && !selected && !rl.Macros.Recording() {
+	status := rl.maxLengthStatus()
+
+	if hint == "" && !selected && !rl.Macros.Recording() && status == "" {
 		rl.Hint.ResetPersist()
 		return
 	}
 
-	if hint != "" {
+	switch {
+	case hint != "":
 		rl.Hint.Persist(hint)
-	} else if selected {
+	case selected:
 		rl.Hint.Persist(color.Dim + fmt.Sprintf("(register: %s)", register))
+	case status != "" && !rl.Macros.Recording():
+		rl.Hint.Persist(status)
 	}
 }
 
+// mustRefresh returns false if more keys are already available to be run, so that
+// bulk input (pasted without bracketed-paste, or fed by macros) is displayed once
+// instead of after each key. Autocompletion and incremental search are refreshed
+// after each key, since the next ones might depend on the candidates generated.
+func (rl *Shell) mustRefresh() bool {
+	if !core.AvailableKeys(rl.Keys) {
+		return true
+	}
+
+	return rl.Config.GetBool("autocomplete") || rl.completer.AutoCompleting() || rl.completer.IsActive()
+}
+
 // handleUndefined is in charge of all actions to take when the
 // last key/sequence was not dispatched down to a readline command.
 func (rl *Shell) handleUndefined(bind inputrc.Bind, cmd func()) {
@@ -247,3 +629,16 @@ func (rl *Shell) handleUndefined(bind inputrc.Bind, cmd func()) {
 		rl.completer.Reset()
 	}
 }
+
+// discardTerminal replaces the display output and the keys input, so that
+// commands can run without terminal: everything displayed is discarded, and
+// reading keys returns EOF. The process standard streams are left untouched.
+// Those are package globals: this must not run concurrently.
+func discardTerminal() (restore func()) {
+	output, keys := term.Output, core.Stdin
+	term.Output, core.Stdin = io.Discard, io.NopCloser(strings.NewReader(""))
+
+	return func() {
+		term.Output, core.Stdin = output, keys
+	}
+}
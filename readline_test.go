@@ -0,0 +1,933 @@
+package readline
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/core"
+	"github.com/alexj212/readline/internal/keymap"
+	"github.com/alexj212/readline/internal/macro"
+	"github.com/alexj212/readline/internal/term"
+)
+
+func TestShell_Process(t *testing.T) {
+	tests := []struct {
+		name    string
+		keymap  keymap.Mode
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:   "Accept line",
+			keymap: keymap.Emacs,
+			input:  "hello world\r",
+			want:   "hello world",
+		},
+		{
+			name:   "Input after accept is ignored",
+			keymap: keymap.Emacs,
+			input:  "first\rsecond",
+			want:   "first",
+		},
+		{
+			name:   "Input exhausted without accepting",
+			keymap: keymap.Emacs,
+			input:  "not accepted",
+			want:   "not accepted",
+		},
+		{
+			name:   "Emacs editing commands",
+			keymap: keymap.Emacs,
+			input:  "world\x01hello \x05!\r",
+			want:   "hello world!",
+		},
+		{
+			name:   "Vim editing commands",
+			keymap: keymap.ViCommand,
+			input:  "ihello world\x17vim\r",
+			want:   "hello vim",
+		},
+		{
+			name:   "Pending prefix keys at end of input",
+			keymap: keymap.Emacs,
+			input:  "prefix\x18",
+			want:   "prefix",
+		},
+		{
+			name:    "End of file on empty line",
+			keymap:  keymap.Emacs,
+			input:   "\x04",
+			wantErr: io.EOF,
+		},
+		{
+			name:    "Interrupt",
+			keymap:  keymap.Emacs,
+			input:   "interrupted\x03",
+			want:    "interrupted",
+			wantErr: ErrInterrupt,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, test.keymap, "", 0)
+
+			got, err := rl.Process(test.input)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Process() error = %v, wantErr %v", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("Process() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_ProcessStandardStreams(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	display := new(strings.Builder)
+	output := term.Output
+	term.Output = display
+
+	defer func() { term.Output = output }()
+
+	stdout, stderr := os.Stdout, os.Stderr
+
+	var streamsKept bool
+
+	rl.Keymap.Register(map[string]func(){
+		"test-widget": func() {
+			streamsKept = os.Stdout == stdout && os.Stderr == stderr
+			term.Print("displayed")
+		},
+	})
+	rl.Config.Bind(string(keymap.Emacs), "\x18t", "test-widget", false)
+
+	if _, err := rl.Process("a\x18t\x0c"); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	if !streamsKept {
+		t.Errorf("standard streams replaced while processing")
+	}
+
+	if display.Len() > 0 {
+		t.Errorf("display output = %q, want it discarded", display.String())
+	}
+
+	if term.Output != display {
+		t.Errorf("display output not restored after processing")
+	}
+}
+
+func TestShell_echoControlCharacters(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		input   string
+		echo    bool
+		want    string
+		wantErr error
+	}{
+		{name: "Interrupt echoed", line: "interrupted", input: "\x03", echo: true, want: "^C", wantErr: ErrInterrupt},
+		{name: "Interrupt not echoed", line: "interrupted", input: "\x03", wantErr: ErrInterrupt},
+		{name: "End of file echoed", input: "\x04", echo: true, want: "^D", wantErr: io.EOF},
+		{name: "End of file not echoed", input: "\x04", wantErr: io.EOF},
+		{name: "Delete char not echoed", line: "line", input: "\x01\x04", echo: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, len(test.line))
+			rl.Config.Set("echo-control-characters", test.echo)
+
+			done := captureTerminal(t)
+
+			var accepted bool
+			var err error
+
+			rl.Keys.Feed(false, []rune(test.input)...)
+
+			for !accepted {
+				if _, empty := core.PeekKey(rl.Keys); empty {
+					break
+				}
+
+				_, accepted, _, err = rl.dispatch()
+				core.FlushUsed(rl.Keys)
+			}
+
+			got := done()
+
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("error = %v, want %v", err, test.wantErr)
+			}
+
+			if !strings.Contains(got, test.want) || (test.want == "" && strings.Contains(got, "^")) {
+				t.Errorf("output = %q, want %q echoed", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_OnWidget(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	var names, keys []string
+
+	rl.OnWidget(func(name string, caller []rune) {
+		names = append(names, name)
+		keys = append(keys, string(caller))
+	})
+
+	rl.Keymap.Register(map[string]func(){
+		"test-widget": func() {},
+	})
+	rl.Config.Bind(string(keymap.Emacs), "\x18t", "test-widget", false)
+
+	if _, err := rl.Process("a\x01\x18t"); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	wantNames := []string{"self-insert", "beginning-of-line", "test-widget"}
+	wantKeys := []string{"a", "\x01", "\x18t"}
+
+	if strings.Join(names, ",") != strings.Join(wantNames, ",") {
+		t.Errorf("widgets = %q, want %q", names, wantNames)
+	}
+
+	if strings.Join(keys, ",") != strings.Join(wantKeys, ",") {
+		t.Errorf("keys = %q, want %q", keys, wantKeys)
+	}
+
+	// No more calls once removed.
+	rl.OnWidget(nil)
+	names = nil
+
+	if _, err := rl.Process("b"); err != nil || len(names) > 0 {
+		t.Errorf("Process() error = %v, widgets = %q, want none", err, names)
+	}
+}
+
+func TestShell_SetKeyInterceptor(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		intercept func(keys []rune) (bool, []rune)
+		want      string
+	}{
+		{
+			name:  "Leave keys unchanged",
+			input: "hello\r",
+			intercept: func(keys []rune) (bool, []rune) {
+				return false, nil
+			},
+			want: "hello",
+		},
+		{
+			name:  "Consume a key",
+			input: "a\x03b\r",
+			intercept: func(keys []rune) (bool, []rune) {
+				if keys[0] == '\x03' {
+					return true, keys[1:]
+				}
+
+				return false, nil
+			},
+			want: "ab",
+		},
+		{
+			name:  "Remap a leader key",
+			input: "hello world,w\r",
+			intercept: func(keys []rune) (bool, []rune) {
+				if strings.HasPrefix(string(keys), ",w") {
+					return true, append([]rune("\x17"), keys[2:]...)
+				}
+
+				return false, nil
+			},
+			want: "hello ",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.SetKeyInterceptor(test.intercept)
+
+			got, err := rl.Process(test.input)
+			if err != nil {
+				t.Errorf("Process() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Process() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_editingKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   keymap.Mode
+		seq    string
+		widget string
+	}{
+		{name: "Delete", mode: keymap.Emacs, seq: "\x1b[3~", widget: "delete-char"},
+		{name: "Delete (keypad)", mode: keymap.Emacs, seq: "\x1b[P", widget: "delete-char"},
+		{name: "Shift-Delete", mode: keymap.Emacs, seq: "\x1b[3;2~", widget: "delete-char"},
+		{name: "Alt-Delete", mode: keymap.Emacs, seq: "\x1b[3;3~", widget: "kill-word"},
+		{name: "Control-Delete", mode: keymap.Emacs, seq: "\x1b[3;5~", widget: "kill-word"},
+		{name: "Home", mode: keymap.Emacs, seq: "\x1b[H", widget: "beginning-of-line"},
+		{name: "Home (application)", mode: keymap.Emacs, seq: "\x1bOH", widget: "beginning-of-line"},
+		{name: "Home (vt220)", mode: keymap.Emacs, seq: "\x1b[1~", widget: "beginning-of-line"},
+		{name: "Home (rxvt)", mode: keymap.Emacs, seq: "\x1b[7~", widget: "beginning-of-line"},
+		{name: "Control-Home", mode: keymap.Emacs, seq: "\x1b[1;5H", widget: "beginning-of-buffer-or-history"},
+		{name: "Shift-Home", mode: keymap.Emacs, seq: "\x1b[1;2H", widget: "select-to-beginning-of-line"},
+		{name: "End", mode: keymap.Emacs, seq: "\x1b[F", widget: "end-of-line"},
+		{name: "End (application)", mode: keymap.Emacs, seq: "\x1bOF", widget: "end-of-line"},
+		{name: "End (vt220)", mode: keymap.Emacs, seq: "\x1b[4~", widget: "end-of-line"},
+		{name: "End (rxvt)", mode: keymap.Emacs, seq: "\x1b[8~", widget: "end-of-line"},
+		{name: "Control-End", mode: keymap.Emacs, seq: "\x1b[1;5F", widget: "end-of-buffer-or-history"},
+		{name: "Shift-End", mode: keymap.Emacs, seq: "\x1b[1;2F", widget: "select-to-end-of-line"},
+		{name: "PageUp", mode: keymap.Emacs, seq: "\x1b[5~", widget: "up-line-or-history"},
+		{name: "PageDown", mode: keymap.Emacs, seq: "\x1b[6~", widget: "down-line-or-history"},
+		{name: "Control-Right", mode: keymap.Emacs, seq: "\x1b[1;5C", widget: "forward-word"},
+		{name: "Control-Left", mode: keymap.Emacs, seq: "\x1b[1;5D", widget: "backward-word"},
+		{name: "Alt-Right", mode: keymap.Emacs, seq: "\x1b[1;3C", widget: "forward-word"},
+		{name: "Alt-Left", mode: keymap.Emacs, seq: "\x1b[1;3D", widget: "backward-word"},
+		{name: "Shift-Up", mode: keymap.Emacs, seq: "\x1b[1;2A", widget: "select-up-line"},
+		{name: "Shift-Down", mode: keymap.Emacs, seq: "\x1b[1;2B", widget: "select-down-line"},
+		{name: "Shift-Right", mode: keymap.Emacs, seq: "\x1b[1;2C", widget: "select-forward-char"},
+		{name: "Shift-Left", mode: keymap.Emacs, seq: "\x1b[1;2D", widget: "select-backward-char"},
+		{name: "Control-Shift-Right", mode: keymap.Emacs, seq: "\x1b[1;6C", widget: "select-forward-word"},
+		{name: "Control-Shift-Left", mode: keymap.Emacs, seq: "\x1b[1;6D", widget: "select-backward-word"},
+		{name: "Shift-Right (vi-insert)", mode: keymap.ViInsert, seq: "\x1b[1;2C", widget: "select-forward-char"},
+		{name: "Delete (vi-insert)", mode: keymap.ViInsert, seq: "\x1b[3~", widget: "delete-char"},
+		{name: "Home (vi-insert)", mode: keymap.ViInsert, seq: "\x1b[1~", widget: "beginning-of-line"},
+		{name: "End (vi-command)", mode: keymap.ViCommand, seq: "\x1b[4~", widget: "end-of-line"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, test.mode, "", 0)
+
+			var last string
+
+			rl.OnWidget(func(name string, _ []rune) { last = name })
+
+			if _, err := rl.Process(test.seq); err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if last != test.widget {
+				t.Errorf("widget = %q, want %q", last, test.widget)
+			}
+		})
+	}
+
+	// Default binds are overridden by user ones.
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	var last string
+
+	rl.OnWidget(func(name string, _ []rune) { last = name })
+
+	if err := rl.Keymap.LoadConfig(strings.NewReader(`"\e[3;5~": backward-kill-word`)); err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if _, err := rl.Process("\x1b[3;5~"); err != nil || last != "backward-kill-word" {
+		t.Errorf("Process() error = %v, widget = %q, want %q", err, last, "backward-kill-word")
+	}
+
+	// Modified cursor keys can be bound by name.
+	if err := rl.Keymap.LoadConfig(strings.NewReader("C-Left: beginning-of-line")); err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if _, err := rl.Process("\x1b[1;5D"); err != nil || last != "beginning-of-line" {
+		t.Errorf("Process() error = %v, widget = %q, want %q", err, last, "beginning-of-line")
+	}
+}
+
+func TestShell_acceptAndReopen(t *testing.T) {
+	for _, selectAll := range []bool{false, true} {
+		rl := newTestShell(t, keymap.Emacs, "", 0)
+		rl.Config.Bind(string(keymap.Emacs), "\x18r", "accept-and-reopen", false)
+		rl.Config.Set("accept-and-reopen-select", selectAll)
+
+		if got, err := rl.Process("echo foo\x18r"); err != nil || got != "echo foo" {
+			t.Fatalf("Process() = %q, %v, want %q", got, err, "echo foo")
+		}
+
+		// The next loop starts with the accepted line.
+		if got, _ := rl.Process(""); got != "echo foo" {
+			t.Errorf("Process() = %q, want %q", got, "echo foo")
+		}
+
+		if got := rl.selection.Active(); got != selectAll {
+			t.Errorf("selection active = %v, want %v", got, selectAll)
+		}
+
+		if bpos, epos := rl.selection.Pos(); selectAll && (bpos != 0 || epos != rl.line.Len()) {
+			t.Errorf("selection = %d-%d, want 0-%d", bpos, epos, rl.line.Len())
+		}
+
+		// But not the ones after.
+		if got, _ := rl.Process("baz\r"); got != "baz" {
+			t.Errorf("Process() = %q, want %q", got, "baz")
+		}
+	}
+}
+
+func TestShell_acceptAndPage(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Config.Bind(string(keymap.Emacs), "\x18p", "accept-and-page", false)
+
+	// Without an executor, the line is accepted.
+	if got, err := rl.Process("ls\x18p"); err != nil || got != "ls" {
+		t.Fatalf("Process() = %q, %v, want %q", got, err, "ls")
+	}
+
+	paged := filepath.Join(t.TempDir(), "paged")
+	t.Setenv("PAGER", "cat > "+paged)
+
+	var executed []string
+
+	rl.SetExecutor(func(line string) (string, error) {
+		executed = append(executed, line)
+		return "output of " + line + "\n", nil
+	})
+
+	// With one, the line is run, paged, and a new one is read.
+	got, err := rl.Process("echo foo\x18pnext")
+	if err != nil || got != "next" {
+		t.Fatalf("Process() = %q, %v, want %q", got, err, "next")
+	}
+
+	if len(executed) != 1 || executed[0] != "echo foo" {
+		t.Errorf("executed = %q, want %q", executed, []string{"echo foo"})
+	}
+
+	content, err := os.ReadFile(paged)
+	if err != nil || string(content) != "output of echo foo\n" {
+		t.Errorf("paged = %q, %v, want %q", content, err, "output of echo foo\n")
+	}
+
+	// The line is in the history.
+	if got, _ := rl.Process("\x10"); got != "echo foo" {
+		t.Errorf("recalled entry = %q, want %q", got, "echo foo")
+	}
+}
+
+func TestShell_acceptAndRun(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Config.Bind(string(keymap.Emacs), "\x18r", "accept-and-run", false)
+
+	// Without an executor, the line is accepted.
+	if got, err := rl.Process("ls\x18r"); err != nil || got != "ls" {
+		t.Fatalf("Process() = %q, %v, want %q", got, err, "ls")
+	}
+
+	var executed []string
+
+	rl.SetExecutor(func(line string) (string, error) {
+		executed = append(executed, line)
+
+		if line == "fail" {
+			return "", errors.New("command not found")
+		}
+
+		return "output of " + line, nil
+	})
+
+	// With one, each line is run and a new one is read, without returning.
+	got, err := rl.Process("echo foo\x18rpwd\x18rnext")
+	if err != nil || got != "next" {
+		t.Fatalf("Process() = %q, %v, want %q", got, err, "next")
+	}
+
+	if want := []string{"echo foo", "pwd"}; strings.Join(executed, ",") != strings.Join(want, ",") {
+		t.Errorf("executed = %q, want %q", executed, want)
+	}
+
+	if got, _ := rl.Process("\x10\x10"); got != "echo foo" {
+		t.Errorf("recalled entry = %q, want %q", got, "echo foo")
+	}
+
+	// Errors are shown in the hint.
+	if got, _ := rl.Process("fail\x18r"); got != "" {
+		t.Errorf("Process() = %q, want an empty line", got)
+	}
+
+	if hint := rl.Hint.Text(); !strings.Contains(hint, "command not found") {
+		t.Errorf("hint = %q, want the executor error", hint)
+	}
+
+	// Incomplete lines are continued, like with accept-line.
+	executed = nil
+	rl.AcceptMultiline = func(line []rune) bool { return !strings.HasSuffix(string(line), "\\") }
+
+	if got, _ := rl.Process("echo \\\x18rfoo\x18r"); got != "" || strings.Join(executed, ",") != "echo \\\nfoo" {
+		t.Errorf("executed = %q, want the line run once complete", executed)
+	}
+
+	// The non-incremental search minibuffer is not executed.
+	rl.Config.Bind(string(keymap.Emacs), "\x18s", "non-incremental-reverse-search-history", false)
+	rl.Config.Bind(string(keymap.Emacs), "\r", "accept-and-run", false)
+	executed = nil
+
+	if got, _ := rl.Process("\x18secho\r"); len(executed) != 0 || got != "echo \\\nfoo" {
+		t.Errorf("executed = %q (line %q), want the search concluded instead", executed, got)
+	}
+}
+
+func TestShell_historySearchWordUnderCursor(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Word at the end of the line", input: "cat build", want: "make build"},
+		{name: "Word under the cursor", input: "git status\x02\x02\x02\x02\x02\x02\x02\x02", want: "git push -f"},
+		{name: "Literal word", input: "echo a.b", want: "cp a.b c"},
+		{name: "No word", input: "", want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Config.Bind(string(keymap.Emacs), "\x18s", "history-search-word-under-cursor", false)
+
+			for _, entry := range []string{"cp a.b c", "make build", "git push -f", "cp axb c"} {
+				rl.Process(entry + "\r")
+			}
+
+			if got, _ := rl.Process(test.input + "\x18s\r"); got != test.want {
+				t.Errorf("Process() = %q, want %q", got, test.want)
+			}
+
+			// Cancelling the search restores the line.
+			line := strings.TrimRight(test.input, "\x02")
+			if got, _ := rl.Process(test.input + "\x18s\x07"); got != line {
+				t.Errorf("cancelled: Process() = %q, want %q", got, line)
+			}
+		})
+	}
+}
+
+func TestShell_ReadlineWithDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		initial string
+		cursor  int
+		want    string
+	}{
+		{name: "Cursor at start", initial: "git commit", cursor: 0, want: "_git commit"},
+		{name: "Cursor in the middle", initial: "git commit", cursor: 4, want: "git _commit"},
+		{name: "Cursor at end", initial: "git commit", cursor: 10, want: "git commit_"},
+		{name: "Rune index", initial: "é€ü", cursor: 2, want: "é€_ü"},
+		{name: "Negative cursor", initial: "ls", cursor: -3, want: "_ls"},
+		{name: "Cursor beyond end", initial: "ls", cursor: 10, want: "ls_"},
+		{name: "Empty default", initial: "", cursor: 1, want: "_"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.setInitial(test.initial, test.cursor)
+
+			line, err := rl.Process("_\r")
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if line != test.want {
+				t.Errorf("line = %q, want %q", line, test.want)
+			}
+
+			// The default is only used once.
+			if line, _ = rl.Process("\r"); line != "" {
+				t.Errorf("next line = %q, want empty", line)
+			}
+		})
+	}
+
+	// Replacing the default with typed input.
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Config.Set("replace-selection-on-type", true)
+	rl.Config.Bind(string(keymap.Emacs), "\x18a", "select-all", false)
+	rl.setInitial("default", 3)
+
+	if line, _ := rl.Process("\x18anew\r"); line != "new" {
+		t.Errorf("selected default: line = %q, want %q", line, "new")
+	}
+
+	// Reverting goes back to the default.
+	rl.setInitial("default", 3)
+
+	if line, _ := rl.Process("abc\x1br\r"); line != "default" {
+		t.Errorf("reverted line = %q, want %q", line, "default")
+	}
+}
+
+func TestShell_ReadlineTemplate(t *testing.T) {
+	commit := `git commit -m "${1:message}" ${2:--amend}`
+
+	tests := []struct {
+		name  string
+		tmpl  string
+		input string
+		want  string
+	}{
+		{name: "Accept defaults", tmpl: commit, input: "\r", want: `git commit -m "message" --amend`},
+		{name: "Replace first", tmpl: commit, input: "fix bug\r", want: `git commit -m "fix bug" --amend`},
+		{name: "Replace all", tmpl: commit, input: "fix\t--no-edit\r", want: `git commit -m "fix" --no-edit`},
+		{name: "Jump back", tmpl: commit, input: "fix\t-a\x1b[Zmsg\r", want: `git commit -m "msg" -a`},
+		{name: "Jump back before first", tmpl: commit, input: "\x1b[Z\x1b[Zfix\r", want: `git commit -m "fix" --amend`},
+		{name: "Past the last", tmpl: commit, input: "\t\t!\r", want: `git commit -m "message" --amend!`},
+		{name: "Edit at placeholder end", tmpl: commit, input: "\x7f\x7f\x7fage\t\x1b[Z?\r", want: `git commit -m "?" --amend`},
+		{name: "Numbered order", tmpl: "cp ${2:dst} ${1:src}", input: "a\tb\r", want: "cp b a"},
+		{name: "Final position", tmpl: "${0} | ${1:grep}", input: "\tcat\r", want: "cat | grep"},
+		{name: "Empty placeholders", tmpl: "ssh ${1}@${2}", input: "root\thost\r", want: "ssh root@host"},
+		{name: "Escapes", tmpl: `echo \${HOME} ${1:a\}b}`, input: "\r", want: "echo ${HOME} a}b"},
+		{name: "No placeholders", tmpl: "echo", input: "\t\r", want: "echo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.setTemplate(test.tmpl)
+
+			line, err := rl.Process(test.input)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if line != test.want {
+				t.Errorf("line = %q, want %q", line, test.want)
+			}
+
+			if rl.Process(""); rl.template != nil {
+				t.Errorf("template still active on the next read")
+			}
+		})
+	}
+}
+
+func TestShell_ReadlineTemplateSelection(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Placeholder replaced", input: "x\r", want: "echo x bar"},
+		{name: "Own selection kept", input: "\x05\x1b[1;2D\x1b[1;2Dx\r", want: "echo foo bxar"},
+		{name: "Own selection in the placeholder kept", input: "\x1b[1;2Dx\r", want: "echo foxo bar"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.setTemplate("echo ${1:foo} bar")
+
+			line, err := rl.Process(test.input)
+			if err != nil {
+				t.Fatalf("Process() error = %v", err)
+			}
+
+			if line != test.want {
+				t.Errorf("line = %q, want %q", line, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_ReadChar(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     rune
+		wantErr error
+	}{
+		{name: "Character", key: 'y'},
+		{name: "Unicode", key: 'é'},
+		{name: "Interrupt", key: '\x03', wantErr: ErrInterrupt},
+		{name: "End of file", key: '\x04', wantErr: io.EOF},
+		{name: "Escape", key: '\x1b', wantErr: ErrEscape},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Keys.Feed(false, test.key)
+
+			key, err := rl.ReadChar()
+			if key != test.key {
+				t.Errorf("ReadChar() key = %q, want %q", key, test.key)
+			}
+
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("ReadChar() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestShell_Confirm(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       string
+		defaultYes bool
+		want       bool
+		wantErr    error
+	}{
+		{name: "Yes", keys: "y", want: true},
+		{name: "Yes uppercase", keys: "Y", want: true},
+		{name: "No", keys: "n", defaultYes: true, want: false},
+		{name: "No uppercase", keys: "N", defaultYes: true, want: false},
+		{name: "Other keys ignored", keys: "x y", want: true},
+		{name: "Enter gives default no", keys: "\r", want: false},
+		{name: "Enter gives default yes", keys: "\r", defaultYes: true, want: true},
+		{name: "Interrupt", keys: "\x03", defaultYes: true, wantErr: ErrInterrupt},
+		{name: "End of file", keys: "\x04", wantErr: io.EOF},
+		{name: "Escape", keys: "\x1b", wantErr: ErrEscape},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.Config.Set("confirm-default", test.defaultYes)
+			rl.Keys.Feed(false, []rune(test.keys)...)
+
+			restore := discardTerminal()
+			defer restore()
+
+			got, err := rl.Confirm("Continue?")
+			if got != test.want {
+				t.Errorf("Confirm() = %v, want %v", got, test.want)
+			}
+
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Confirm() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestShell_Select(t *testing.T) {
+	items := []string{"main", "develop", "feature/login", "feature/logout"}
+
+	tests := []struct {
+		name    string
+		initial int
+		keys    string
+		want    int
+		wantErr error
+	}{
+		{name: "First by default", keys: "\r", want: 0},
+		{name: "Initial selection", initial: 2, keys: "\r", want: 2},
+		{name: "Initial out of range", initial: 10, keys: "\r", want: 3},
+		{name: "Move down", keys: "\x1b[B\x1b[B\r", want: 2},
+		{name: "Move with Tab and Shift-Tab", keys: "\t\t\t\x1b[Z\r", want: 2},
+		{name: "Move up", initial: 3, keys: "\x1b[A\r", want: 2},
+		{name: "Filter", keys: "dev\r", want: 1},
+		{name: "Filter by substring", keys: "logou\r", want: 3},
+		{name: "Filter then move", keys: "feature\t\r", want: 3},
+		{name: "No matches", keys: "nothing\r", want: -1},
+		{name: "Interrupt", keys: "\x1b[B\x03", want: -1, wantErr: ErrInterrupt},
+		{name: "Escape", keys: "\x1b", want: -1, wantErr: ErrEscape},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.setSelect("Branch", items, test.initial)
+
+			_, err := rl.Process(test.keys)
+
+			got, err := rl.selectResult(err)
+			if got != test.want {
+				t.Errorf("Select() = %d, want %d", got, test.want)
+			}
+
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Select() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	if _, err := rl.Select("Branch", nil); !errors.Is(err, ErrNoItems) {
+		t.Errorf("Select() error = %v, want %v", err, ErrNoItems)
+	}
+}
+
+func TestShell_pastedLineEndings(t *testing.T) {
+	tests := []struct {
+		name  string
+		paste string
+		want  string
+	}{
+		{name: "CRLF", paste: "echo a\r\necho b\r\n\r\necho c", want: "echo a\necho b\n\necho c"},
+		{name: "CR only", paste: "echo a\recho b\r\recho c", want: "echo a\necho b\n\necho c"},
+		{name: "Mixed", paste: "echo a\r\necho b\n\necho c\recho d", want: "echo a\necho b\n\necho c\necho d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			rl.AcceptMultiline = func([]rune) bool { return false }
+
+			stdin := core.Stdin
+			core.Stdin = io.NopCloser(strings.NewReader(test.paste))
+			core.WaitAvailableKeys(rl.Keys, rl.Config)
+			core.Stdin = stdin
+
+			runKeys(t, rl, "")
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_mustRefresh(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	if !rl.mustRefresh() {
+		t.Error("mustRefresh() = false, want true without available keys")
+	}
+
+	rl.Keys.Feed(false, []rune("echo hello")...)
+
+	if rl.mustRefresh() {
+		t.Error("mustRefresh() = true, want false with available keys")
+	}
+
+	rl.Config.Set("autocomplete", true)
+
+	if !rl.mustRefresh() {
+		t.Error("mustRefresh() = false, want true with autocomplete")
+	}
+}
+
+// writeCounter counts the writes made to the terminal.
+type writeCounter struct {
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return len(p), nil
+}
+
+// BenchmarkShell_paste reports the number of terminal writes needed to run and display
+// a large input given at once (like a paste without bracketed-paste), when refreshing
+// after each key or only once all available keys have been run, like Readline() does.
+func BenchmarkShell_paste(b *testing.B) {
+	paste := []rune(strings.Repeat("echo hello world && ", 50))
+
+	for _, coalesce := range []bool{false, true} {
+		name := "refresh each key"
+		if coalesce {
+			name = "coalesced refresh"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			restore := discardTerminal()
+			defer restore()
+
+			counter := new(writeCounter)
+			output := term.Output
+			term.Output = counter
+
+			defer func() { term.Output = output }()
+
+			rl := NewShell(inputrc.WithName("readline-test"))
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				rl.line.Set()
+				rl.cursor.Set(0)
+				rl.Keys.Feed(false, paste...)
+
+				for {
+					macro.RecordKeys(rl.Macros)
+					core.FlushUsed(rl.Keys)
+
+					if !coalesce || rl.mustRefresh() {
+						rl.Display.Refresh()
+					}
+
+					if _, empty := core.PeekKey(rl.Keys); empty {
+						break
+					}
+
+					rl.dispatch()
+				}
+			}
+
+			b.ReportMetric(float64(counter.writes)/float64(b.N), "writes/op")
+		})
+	}
+}
+
+func TestShell_multilineHistory(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	entry := "for i in 1 2; do\n  echo $i\ndone"
+
+	// Lines are accepted once the loop is closed.
+	multiline := func(line []rune) bool {
+		return !strings.HasPrefix(string(line), "for") || strings.HasSuffix(string(line), "done")
+	}
+
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.AcceptMultiline = multiline
+	rl.History.AddFromFile("file", file)
+
+	if got, _ := rl.Process("for i in 1 2; do\r  echo $i\rdone\r"); got != entry {
+		t.Fatalf("Process() = %q, want %q", got, entry)
+	}
+
+	if got, _ := rl.Process("ls\r"); got != "ls" {
+		t.Fatalf("Process() = %q, want %q", got, "ls")
+	}
+
+	// Recall the entry in the same shell, and from the history file in another one.
+	reloaded := newTestShell(t, keymap.Emacs, "", 0)
+	reloaded.History.AddFromFile("file", file)
+
+	for _, shell := range []*Shell{rl, reloaded} {
+		if got, _ := shell.Process("\x10\x10"); got != entry {
+			t.Errorf("recalled entry = %q, want %q", got, entry)
+		}
+
+		if got := shell.cursor.Pos(); got != len(entry) {
+			t.Errorf("cursor = %d, want %d", got, len(entry))
+		}
+
+		if got := shell.line.Lines(); got != 2 {
+			t.Errorf("recalled entry spans %d newlines, want 2", got)
+		}
+	}
+
+	// Windows line endings are normalized.
+	rl.line.Set([]rune("echo one\r\necho two")...)
+	rl.History.Accept(false, false, nil)
+
+	if got, _ := rl.Process("\x10"); got != "echo one\necho two" {
+		t.Errorf("recalled entry = %q, want %q", got, "echo one\necho two")
+	}
+}
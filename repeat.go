@@ -0,0 +1,66 @@
+package readline
+
+import (
+	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/core"
+)
+
+// repeatedCommand is the last command run, as replayed by repeat-last-command.
+// A repetition is not recorded, so that the command it repeated is still the last
+// one run for commands depending on it (eg. yank-pop after a repeated yank).
+type repeatedCommand struct {
+	name    string // The name of the command, as bound.
+	command func()
+	times   string // The numeric argument it was run with, if any.
+	args    []rune // The keys it read while running (eg. the character searched).
+}
+
+// recordCommand keeps the command just run for repeat-last-command, along with
+// its numeric argument and the keys it read past the matched ones, unless it is
+// a numeric argument itself, a macro (whose keys are run as commands), or a repetition.
+func (rl *Shell) recordCommand(bind inputrc.Bind, command func(), matched int, times string) {
+	if command == nil || bind.Macro || bind.Action == "repeat-last-command" || rl.Iterations.IsPending() {
+		return
+	}
+
+	caller := rl.Keys.Caller()
+	args := append([]rune{}, caller[min(matched, len(caller)):]...)
+
+	rl.lastCommand = &repeatedCommand{name: bind.Action, command: command, times: times, args: args}
+}
+
+// lastCommandWas returns true if the last command run is one of the given ones.
+func (rl *Shell) lastCommandWas(names ...string) bool {
+	if rl.lastCommand == nil {
+		return false
+	}
+
+	for _, name := range names {
+		if rl.lastCommand.name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchRepeat returns repeat-last-command if the next key is the last one of the
+// sequence which has just repeated a command, so that it can be pressed again.
+func (rl *Shell) matchRepeat() (bind inputrc.Bind, command func(), matched bool) {
+	repeatKey := rl.repeatKey
+	rl.repeatKey = 0
+
+	if repeatKey == 0 || rl.Keymap.Local() != "" {
+		return
+	}
+
+	key, empty := core.PeekKey(rl.Keys)
+	if empty || rune(key) != repeatKey {
+		return
+	}
+
+	core.PopKey(rl.Keys)
+	core.MatchedKeys(rl.Keys, []byte{key})
+
+	return inputrc.Bind{Action: "repeat-last-command"}, rl.repeatLastCommand, true
+}
@@ -0,0 +1,172 @@
+package readline
+
+import (
+	"errors"
+	"io"
+
+	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/completion"
+	"github.com/alexj212/readline/internal/core"
+)
+
+// ErrNoItems is returned by Select when it is given no items to choose from.
+var ErrNoItems = errors.New("no items to select from")
+
+// Select displays a list of items with the completion menu, below the prompt,
+// and returns the index of the item chosen by the user. The first item is
+// selected when starting: the arrow keys, Tab and Shift-Tab move the selection,
+// and typing filters the list (as with menu-incremental-search, the text typed
+// is a regular expression, case-insensitive when lowercase). Enter chooses the
+// selected item, or the first one left in the list if none is. The title is
+// displayed in the hint section, along with the filter being typed.
+//
+// Ctrl-C aborts the selection and returns -1 with ErrInterrupt, Ctrl-D with
+// io.EOF, and the escape key with ErrEscape. When several items are identical,
+// the index of the first one is returned.
+func (rl *Shell) Select(title string, items []string) (int, error) {
+	return rl.SelectWithDefault(title, items, 0)
+}
+
+// SelectWithDefault is like Select, but the item at index initial is selected
+// when starting. If out of range, the closest of the first and last items is.
+func (rl *Shell) SelectWithDefault(title string, items []string, initial int) (int, error) {
+	if len(items) == 0 {
+		return -1, ErrNoItems
+	}
+
+	rl.setSelect(title, items, initial)
+
+	// Don't keep it for the next call if failing early.
+	defer func() { rl.pendingSelect = nil }()
+
+	_, err := rl.Readline()
+
+	return rl.selectResult(err)
+}
+
+// selectMenu is the state of a Select() call.
+type selectMenu struct {
+	title   string
+	items   []string
+	initial int
+	chosen  int // The index of the chosen item, or -1 if aborted.
+}
+
+// setSelect sets the list of items to select from in the next read.
+func (rl *Shell) setSelect(title string, items []string, initial int) {
+	rl.pendingSelect = &selectMenu{
+		title:   title,
+		items:   items,
+		initial: max(0, min(initial, len(items)-1)),
+		chosen:  -1,
+	}
+}
+
+// startSelect is called when initializing the shell, to display the items
+// to select from (if any) for this read, and select the initial one.
+func (rl *Shell) startSelect() {
+	rl.selectMenu, rl.pendingSelect = rl.pendingSelect, nil
+
+	if rl.selectMenu == nil {
+		return
+	}
+
+	rl.completer.GenerateWith(rl.selectCompletion)
+	rl.completer.IsearchStart(rl.selectMenu.title, true, true)
+
+	for i := 0; i <= rl.selectMenu.initial; i++ {
+		rl.completer.Select(1, 0)
+	}
+}
+
+// selectCompletion generates the items of the current Select() call.
+func (rl *Shell) selectCompletion() completion.Values {
+	comps := CompleteValues(rl.selectMenu.items...).NoSort().DisplayList()
+	return comps.convert()
+}
+
+// selectKey handles the keys choosing an item or aborting the selection, if
+// the pending keys are one of them, and returns true if it did, and if the read
+// is done. All other keys are left to the incremental search keymap.
+func (rl *Shell) selectKey() (handled, done bool, err error) {
+	if rl.selectMenu == nil {
+		return false, false, nil
+	}
+
+	keys := string(core.PendingKeys(rl.Keys))
+	if keys == "" {
+		return false, false, nil
+	}
+
+	switch key := rune(keys[0]); {
+	case key == inputrc.Return || key == inputrc.Newline:
+		if !rl.completer.IsInserting() {
+			rl.completer.Select(1, 0)
+		}
+
+		// Nothing to choose if no items match the filter.
+		rl.selectMenu.chosen = rl.selectedItem()
+		if rl.selectMenu.chosen == -1 {
+			core.PopForce(rl.Keys)
+			return true, false, nil
+		}
+
+	case key == inputrc.Encontrol('c'):
+		err = ErrInterrupt
+	case key == inputrc.Encontrol('d'):
+		err = io.EOF
+	case keys == string(inputrc.Esc):
+		err = ErrEscape
+	default:
+		return false, false, nil
+	}
+
+	core.PopForce(rl.Keys)
+	rl.stopSelect()
+
+	return true, true, err
+}
+
+// selectedItem returns the index of the item currently selected in the menu.
+func (rl *Shell) selectedItem() int {
+	if !rl.completer.IsInserting() {
+		return -1
+	}
+
+	line, _ := rl.completer.Line()
+
+	for i, item := range rl.selectMenu.items {
+		if item == string(*line) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// stopSelect clears the menu and displays the chosen item (if any) as the line.
+func (rl *Shell) stopSelect() {
+	rl.completer.ResetForce()
+	rl.Hint.Reset()
+
+	rl.line.Set()
+
+	if chosen := rl.selectMenu.chosen; chosen != -1 {
+		rl.line.Set([]rune(rl.selectMenu.items[chosen])...)
+	}
+
+	rl.cursor.Set(rl.line.Len())
+	rl.Display.AcceptLine()
+}
+
+// selectResult returns the chosen item index, once the read is over.
+func (rl *Shell) selectResult(err error) (int, error) {
+	menu := rl.selectMenu
+	rl.selectMenu = nil
+
+	if err != nil || menu == nil {
+		return -1, err
+	}
+
+	return menu.chosen, nil
+}
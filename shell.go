@@ -1,7 +1,14 @@
 package readline
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/alexj212/readline/inputrc"
 	"github.com/alexj212/readline/internal/completion"
@@ -15,6 +22,17 @@ import (
 	"github.com/alexj212/readline/internal/ui"
 )
 
+var (
+	// ErrNoMacro is returned when using a macro that has not been recorded or saved.
+	ErrNoMacro = errors.New("no such macro")
+
+	// ErrUnknownKeymap is returned when binding to a keymap that does not exist.
+	ErrUnknownKeymap = errors.New("unknown keymap")
+
+	// ErrInvalidValue is returned when setting a variable with a value of the wrong type.
+	ErrInvalidValue = errors.New("invalid variable value")
+)
+
 // Shell is the main readline shell instance. It contains all the readline state
 // and methods to run the line editor, manage the inputrc configuration, keymaps
 // and commands.
@@ -43,6 +61,47 @@ type Shell struct {
 	completer *completion.Engine // Completions generation and display.
 	Display   *display.Engine    // Manages display refresh/update/clearing.
 
+	// Concurrency
+	mutex          sync.Mutex  // Locked while running commands and refreshing the display.
+	reading        atomic.Bool // Currently reading user input in the Readline() loop.
+	reopen         bool        // The line was accepted with accept-and-reopen.
+	termState      *term.State // The terminal state before Readline() made it raw.
+	refreshPending atomic.Bool // A Refresh() or print is waiting for the shell.
+	printMutex     sync.Mutex  // Protects the prints waiting for the shell.
+	pendingPrints  []string    // Strings queued by PrintAbovePrompt().
+
+	// Editing
+	rotations  [][]string // Word groups cycled by rotate-word, see AddRotation().
+	initial    []rune     // The line to start the next Readline() call with.
+	initialPos int        // The cursor position in the initial line.
+	hasInitial bool       // The next Readline() call starts with the initial line.
+
+	yanked    []rune // The text inserted by the last yank or yank-pop.
+	yankedEnd int    // The position at which it ends.
+
+	lengthRejected bool // Text was rejected by the last insertion, see SetMaxLength().
+
+	lastCommand *repeatedCommand // The last command run, replayed by repeat-last-command.
+	repeatKey   rune             // The key repeating it again, just after it ran.
+
+	template        *tabstops // Placeholders of the line, see ReadlineTemplate().
+	pendingTemplate *tabstops // The template for the next Readline() call.
+
+	selectMenu    *selectMenu // Items to choose from, see Select().
+	pendingSelect *selectMenu // The items for the next Readline() call.
+
+	async    *asyncCompletion // The last request of the async completer, see SetAsyncCompleter().
+	spinners []*spinner       // Spinners being displayed, see StartSpinner().
+
+	completionBaseDir string // The directory of relative file completions, see SetCompletionBaseDir().
+
+	// Hooks
+	onWidget          func(name string, keys []rune)                        // Observes commands run, see OnWidget().
+	interceptor       func(keys []rune) (consumed bool, replacement []rune) // Remaps keys, see SetKeyInterceptor().
+	commentPrefixFunc func(line []rune) string                              // Comment prefix for a line, see SetCommentPrefixFunc().
+	executor          func(line string) (output string, err error)          // Runs accepted lines, see SetExecutor().
+	selectionSink     func(text string)                                     // Receives exported regions, see SetSelectionSink().
+
 	// User-provided functions
 
 	// AcceptMultiline enables the caller to decide if the shell should keep reading
@@ -54,9 +113,16 @@ type Shell struct {
 	AcceptMultiline func(line []rune) (accept bool)
 
 	// SyntaxHighlighter is a helper function to provide syntax highlighting.
-	// Once enabled, set to nil to disable again.
+	// Once enabled, set to nil to disable again. See also SetTokenizer().
 	SyntaxHighlighter func(line []rune) string
 
+	// OnFocus is called when the terminal window gains (focused is true) or
+	// loses the focus, while reading user input. The enable-focus-events
+	// option must be set (in the inputrc or with SetVar) for the terminal to
+	// report those events: this can be used to dim the prompt when unfocused,
+	// or to update it on focus-in. Call Refresh() to redisplay the prompt.
+	OnFocus func(focused bool)
+
 	// Completer is a function that produces completions.
 	// It takes the readline line ([]rune) and cursor pos as parameters,
 	// and returns completions with their associated metadata/settings.
@@ -81,16 +147,20 @@ func NewShell(opts ...inputrc.Option) *Shell {
 	shell.line = line
 	shell.cursor = cursor
 	shell.selection = selection
-	shell.Buffers = editor.NewBuffers()
 	shell.Iterations = iterations
 
 	// Keymaps and commands
-	keymaps, config := keymap.NewEngine(keys, iterations, opts...)
+	keymaps, config := keymap.NewEngine(keys, iterations)
+	shell.Buffers = editor.NewBuffers(config)
 	keymaps.Register(shell.standardCommands())
 	keymaps.Register(shell.viCommands())
 	keymaps.Register(shell.historyCommands())
 	keymaps.Register(shell.completionCommands())
 
+	// Load the inputrc configurations and set up related things.
+	// Errors are not fatal, and are available with InputrcErrors().
+	keymaps.ReloadConfig(opts...)
+
 	shell.Keymap = keymaps
 	shell.Config = config
 	shell.Opts = opts
@@ -137,6 +207,523 @@ func (rl *Shell) Cursor() *core.Cursor { return rl.cursor }
 // selections used to change/select multiple parts of the line at once.
 func (rl *Shell) Selection() *core.Selection { return rl.selection }
 
+// Checkpoint saves the current line and cursor position as an undo unit.
+// The shell automatically saves the line after each command it runs, so
+// calling this at the beginning of a widget performing several successive
+// edits (eg. multiple calls to rl.Line().Set()) groups them all in a single
+// undo step: the next undo will restore the line as it was at checkpoint.
+func (rl *Shell) Checkpoint() {
+	rl.History.Save()
+}
+
+// RestoreCheckpoint restores the line and cursor position to their state
+// when the last checkpoint was made. If the line has not been modified since
+// then, the state preceding this checkpoint is restored instead, like undo.
+// The restored state is not saved again once the current command returns.
+func (rl *Shell) RestoreCheckpoint() {
+	rl.History.Undo()
+}
+
+// LastMacro returns the last keyboard macro recorded by the user (or set with
+// SetMacro), escaped in the same format as the print-last-kbd-macro command.
+// The returned string can be saved by the caller and reloaded with SetMacro.
+func (rl *Shell) LastMacro() string {
+	return rl.Macros.Last()
+}
+
+// SetMacro sets the keys to be replayed by call-last-kbd-macro. The sequence
+// may use the inputrc escape format (eg. `\C-a`, `\e[A` or `\r`), as returned
+// by LastMacro(). Passing an empty string deletes the last macro.
+func (rl *Shell) SetMacro(keys string) {
+	rl.Macros.SetLast(keys)
+}
+
+// SaveMacro saves the last recorded macro under the given name, so that
+// it can be bound to a key sequence with BindMacro, even after other macros
+// have been recorded. Returns ErrNoMacro if no macro has been recorded yet,
+// or if the name is empty (SaveMacro("") never saves anything).
+func (rl *Shell) SaveMacro(name string) error {
+	if !rl.Macros.SaveNamed(name) {
+		return ErrNoMacro
+	}
+
+	return nil
+}
+
+// BindMacro binds the macro saved under name to a key sequence in the given
+// keymap (eg. "emacs", "vi-insert", etc). The sequence uses the inputrc escape
+// format (eg. `\C-x\C-m`). Like binds in an inputrc file bound to a string,
+// pressing the sequence will replay the macro keys as if typed by the user.
+func (rl *Shell) BindMacro(name, keymap, sequence string) error {
+	macro, found := rl.Macros.Named(name)
+	if !found {
+		return fmt.Errorf("%w: %s", ErrNoMacro, name)
+	}
+
+	if _, found := rl.Config.Binds[keymap]; !found {
+		return fmt.Errorf("%w: %s", ErrUnknownKeymap, keymap)
+	}
+
+	return rl.Config.Bind(keymap, inputrc.Unescape(sequence), inputrc.Unescape(macro), true)
+}
+
+// KeymapInfo describes one of the keymaps of the shell, as returned by Keymaps().
+type KeymapInfo struct {
+	Name     string // The keymap name, as used in inputrc files (eg. "vi-insert").
+	Bindings int    // The number of key sequences bound, to commands or macros.
+	Active   bool   // The keymap is the current main one.
+}
+
+// Keymaps returns all keymaps of the shell, sorted by name, with the number of
+// their bindings, defaults included, and which one is the current main keymap.
+// The list is read-only metadata: keys are bound with Config.Bind or BindMacro.
+func (rl *Shell) Keymaps() []KeymapInfo {
+	keymaps := make([]KeymapInfo, 0, len(rl.Config.Binds))
+
+	for name, binds := range rl.Config.Binds {
+		keymaps = append(keymaps, KeymapInfo{
+			Name:     name,
+			Bindings: len(binds),
+			Active:   name == string(rl.Keymap.Main()),
+		})
+	}
+
+	sort.Slice(keymaps, func(i, j int) bool {
+		return keymaps[i].Name < keymaps[j].Name
+	})
+
+	return keymaps
+}
+
+// KillRing returns a copy of the kill ring, the most recent entry first:
+// this first entry is the one inserted by yank, and yank-pop rotates them.
+// Commands killing or copying text (like copy-region-as-kill, which also
+// clears the selection once copied) push it in front of the ring, unless
+// a register was selected for them, and the oldest entry is evicted when
+// the ring is full (see SetKillRingSize). Consecutive kills are accumulated
+// in the first entry instead, as a single one.
+func (rl *Shell) KillRing() []string {
+	return rl.Buffers.Ring()
+}
+
+// SetKillRing replaces the kill ring with entries, the first one being the
+// most recent one (as returned by KillRing), for instance to restore it from
+// a previous session. Empty entries are ignored, as are those exceeding the
+// size of the ring.
+func (rl *Shell) SetKillRing(entries []string) {
+	rl.Buffers.SetRing(entries)
+}
+
+// SetKillRingSize sets the maximum number of entries of the kill ring, like
+// the kill-ring-size inputrc variable, which it overwrites (60 by default).
+// With a size of 0, killed text is not accumulated: each kill overwrites the
+// previous one. Entries above the size are evicted on the next kill.
+func (rl *Shell) SetKillRingSize(size int) {
+	rl.Config.Set("kill-ring-size", size)
+}
+
+// SetCompletions is a simpler alternative to the Completer field, for when
+// completions are a flat list of strings: the candidates are gathered in a
+// single anonymous group, and the usual completion menu is built from them.
+// The function is given the input line and cursor position, as a byte offset in
+// it (line[:pos] being the text before the cursor, whatever its characters), and
+// should return candidates for the whole word in which the cursor is, eg. "checkout"
+// when the line is "git ch". This word, delimited by blank spaces and ending at the
+// cursor, is the part of the line replaced by candidates: those not starting with
+// it are filtered out, and a unique candidate is directly inserted.
+// Passing nil disables completions, and overwrites any Completer field set.
+func (rl *Shell) SetCompletions(complete func(line string, pos int) []string) {
+	if complete == nil {
+		rl.Completer = nil
+		return
+	}
+
+	rl.Completer = func(line []rune, cursor int) Completions {
+		return CompleteValues(complete(string(line), len(string(line[:cursor])))...)
+	}
+}
+
+// WordCompleter is a structured alternative to the Completer field, for command
+// applications completing their options (flags, like --verbose) differently from
+// their other arguments. Both methods are given the word being completed, that is
+// the part of the line from the last blank space up to the cursor (eg. "--ver"),
+// and return the candidates for it: option candidates are usually given with a
+// description (their help text), in which case they are listed with it.
+type WordCompleter interface {
+	// CompleteOptions returns the candidates for a word starting with a dash.
+	CompleteOptions(word string) []Completion
+
+	// CompleteArguments returns the candidates for any other word (empty included).
+	CompleteArguments(word string) []Completion
+}
+
+// SetWordCompleter uses a WordCompleter to produce completions, calling either
+// of its methods depending on whether the word being completed starts with a
+// dash. Like SetCompletions, it overwrites the Completer field, and passing nil
+// disables completions.
+func (rl *Shell) SetWordCompleter(completer WordCompleter) {
+	if completer == nil {
+		rl.Completer = nil
+		return
+	}
+
+	rl.Completer = func(line []rune, cursor int) Completions {
+		before := string(line[:cursor])
+		word := before[strings.LastIndexAny(before, " \t\n")+1:]
+
+		if strings.HasPrefix(word, "-") {
+			return CompleteRaw(completer.CompleteOptions(word))
+		}
+
+		return CompleteRaw(completer.CompleteArguments(word))
+	}
+}
+
+// CompletionState is a snapshot of the completion menu: its groups of candidates,
+// each laid out in a grid of rows, and the position of the selected candidate.
+type CompletionState = completion.State
+
+// CompletionState returns the candidates currently offered by the completion menu
+// (or the incremental search in it) and the selected one, for applications drawing
+// the menu themselves, or nil if no completion is active. The state is a copy, and
+// is not updated afterwards: call this again after moving the selection.
+func (rl *Shell) CompletionState() *CompletionState {
+	return rl.completer.State()
+}
+
+// MoveCompletion moves the selection in the completion menu by dx columns and dy
+// rows, continuing on the next/previous rows and groups when going past the edges,
+// and inserts the new selected candidate in the line, as menu-complete does. It is
+// a no-op when no completion is active. Like all functions editing the line, it is
+// not safe for concurrent use with Readline(): while reading input, only call it
+// from code run by the shell (OnWidget hooks, the executor, the completer), after
+// which the line is redisplayed.
+func (rl *Shell) MoveCompletion(dx, dy int) {
+	if !rl.completer.IsActive() {
+		return
+	}
+
+	rl.completer.Move(dx, dy)
+	rl.line, rl.cursor, rl.selection = rl.completer.GetBuffer()
+}
+
+// AcceptCompletion inserts the selected candidate for good in the line and closes
+// the completion menu. If no candidate is selected, the menu is closed without
+// changing the line. It is a no-op when no completion is active. As with
+// MoveCompletion, only call it from commands while reading input.
+func (rl *Shell) AcceptCompletion() {
+	if !rl.completer.IsActive() {
+		return
+	}
+
+	rl.History.Save()
+	rl.completer.Reset()
+	rl.line, rl.cursor, rl.selection = rl.completer.GetBuffer()
+}
+
+// Token is a range of the input line to be displayed with a given style.
+// Start and End are rune indexes in the line, the End one being excluded.
+type Token = display.Token
+
+// SetTokenizer is an alternative to the SyntaxHighlighter function: instead
+// of returning the entire line with its color sequences, the tokenizer only
+// returns the ranges of the line to highlight (keywords, strings, numbers...)
+// along with their style, and the shell composes the highlighted line itself.
+// Any visual selection or matching bracket highlighting is applied on top of
+// the tokens, and overlapping parts of tokens are ignored in favor of the first.
+// This overwrites the SyntaxHighlighter, and passing nil disables both.
+func (rl *Shell) SetTokenizer(tokenize func(line []rune) []Token) {
+	if tokenize == nil {
+		rl.SyntaxHighlighter = nil
+		return
+	}
+
+	rl.SyntaxHighlighter = func(line []rune) string {
+		return display.HighlightTokens(line, tokenize(line))
+	}
+}
+
+// SetCommentPrefix sets the prefix inserted or removed by the insert-comment
+// command (and highlighted as a comment with the rest of the line), like the
+// comment-begin inputrc variable, which it overwrites. It is "#" by default.
+func (rl *Shell) SetCommentPrefix(prefix string) {
+	rl.Config.Set("comment-begin", prefix)
+}
+
+// SetCommentPrefixFunc registers a function returning the comment prefix to use
+// for the current line, for instance after detecting its language (such as "--"
+// for SQL or ";" for Lisp): if it returns an empty string, the prefix set with
+// SetCommentPrefix or comment-begin is used. Passing nil removes the function.
+// The comment highlighting of the line always uses comment-begin.
+func (rl *Shell) SetCommentPrefixFunc(prefix func(line []rune) string) {
+	rl.commentPrefixFunc = prefix
+}
+
+// SetTogglePrefix sets the prefix added or removed at the beginning of the line
+// by the toggle-prefix command, like the toggle-prefix inputrc variable, which it
+// overwrites. It is "sudo " by default: include any space to separate it.
+func (rl *Shell) SetTogglePrefix(prefix string) {
+	rl.Config.Set("toggle-prefix", prefix)
+}
+
+// LoadInputrc parses an inputrc configuration from r, on top of the current one,
+// and applies its binds and variables. This allows bundling default binds in an
+// application, without reading them from a file: it is to be used in addition to
+// the user's inputrc files (loaded when creating the shell), or after them.
+// The editing mode is only changed if the configuration sets editing-mode.
+//
+// Invalid lines do not abort parsing: the rest of the configuration is still
+// applied, and all errors are returned (joined), and added to InputrcErrors().
+func (rl *Shell) LoadInputrc(r io.Reader) error {
+	main := rl.Keymap.Main()
+
+	err := rl.Keymap.LoadConfig(r, rl.Opts...)
+
+	rl.updateKeymap(main)
+
+	return err
+}
+
+// OnWidget registers a function called after each command (widget) run by the
+// shell, builtin or user-registered, with its name and the keys that invoked it,
+// including any keys read by the command itself (eg. the character searched by
+// vi-char-search). Binds to macros are not reported, but the commands run by
+// their keys are. The observer is only meant to read this information, and it
+// is called from the Readline() loop: it should not block. Passing nil removes it.
+func (rl *Shell) OnWidget(observer func(name string, keys []rune)) {
+	rl.onWidget = observer
+}
+
+// SetKeyInterceptor registers a function called with the pending input keys each
+// time the shell is about to match them against the keymaps, so as to implement
+// dynamic behavior that static binds cannot (eg. a leader key, or remapping keys
+// depending on the application state). If consumed is true, the pending keys are
+// dropped. A non-nil replacement is then fed back to the key stack (after any keys
+// not consumed), and matched as if typed by the user.
+//
+// The interceptor runs before any bind is matched, including the interrupt and
+// end-of-file ones (eg. Ctrl-C and Ctrl-D), and before the completion/isearch
+// local keymaps: consuming those keys thus prevents them from having any effect.
+// Since pending keys that only matched a bind prefix are kept in the stack, the
+// interceptor might be called again with the same keys followed by newer ones.
+// Keys read by commands themselves (eg. the character searched by vi-char-search)
+// are not passed to the interceptor. Passing nil removes it.
+func (rl *Shell) SetKeyInterceptor(intercept func(keys []rune) (consumed bool, replacement []rune)) {
+	rl.interceptor = intercept
+}
+
+// SetExecutor sets a function running accepted lines, for the commands that
+// execute the line themselves instead of returning it from Readline(), like
+// accept-and-page. These commands write the line to the history, pass it to the
+// executor, display the output it returns, and start reading a new line below,
+// without Readline() returning. Without an executor, they accept the line.
+//
+// The executor is called from the goroutine running Readline(), with the shell
+// locked: it must not call methods of the shell (like PrintAbovePrompt), nor read
+// from the terminal. The terminal is in raw mode when it is called, so the command
+// output should be returned rather than written to it. The terminal is restored to
+// its original state while a pager is running. Passing nil removes the executor.
+func (rl *Shell) SetExecutor(executor func(line string) (output string, err error)) {
+	rl.executor = executor
+}
+
+// SetSelectionSink registers a function receiving the text of the active region
+// when the export-selection command is run, like to append it to a notes file:
+// neither the line nor the kill ring are modified. It is called from the Readline()
+// loop, with the shell locked, and should not block. Passing nil removes it.
+func (rl *Shell) SetSelectionSink(sink func(text string)) {
+	rl.selectionSink = sink
+}
+
+// SetVar sets the value of an inputrc variable, like a "set name value" line
+// in an inputrc file would. The name must be one of the readline variables or
+// of those specific to this library (see the dump-variables command), or one
+// already set by an inputrc file: otherwise inputrc.ErrUnknownVariable is returned.
+//
+// The value must match the type of the variable, but is converted if possible:
+// boolean variables accept "on"/"off" (or "1"/"0") and integers (non-zero is on),
+// integer ones accept numeric strings, and string ones accept integers.
+// Otherwise, ErrInvalidValue is returned and the variable is left unchanged.
+func (rl *Shell) SetVar(name string, value any) error {
+	current := rl.Config.Get(name)
+	if current == nil {
+		return fmt.Errorf("%w: %s", inputrc.ErrUnknownVariable, name)
+	}
+
+	var converted any
+
+	switch current.(type) {
+	case bool:
+		converted = varBool(value)
+	case int:
+		converted = varInt(value)
+	case string:
+		converted = varString(value)
+	}
+
+	if converted == nil {
+		return fmt.Errorf("%w: %v (%T) for %s (%T)", ErrInvalidValue, value, value, name, current)
+	}
+
+	return rl.Config.Set(name, converted)
+}
+
+// GetVar returns the value of an inputrc variable (a bool, an int or a string),
+// and false if the variable is neither a readline/library one nor set by a file.
+func (rl *Shell) GetVar(name string) (any, bool) {
+	value := rl.Config.Get(name)
+	return value, value != nil
+}
+
+// InputrcErrors returns the errors encountered while parsing the inputrc
+// configurations, either when creating the shell, when re-reading the init
+// file or with LoadInputrc(). Each of them is an *inputrc.ParseError giving
+// the file name and line number of the error, with one of the inputrc errors
+// (eg. inputrc.ErrUnknownCommand), unless a file could not be read at all.
+// Unknown variables and commands are reported, but still set and bound.
+func (rl *Shell) InputrcErrors() []error {
+	return rl.Keymap.Errors()
+}
+
+// AddRotation registers a group of words cycled through by the rotate-word command:
+// when the word under the cursor is one of them, it is replaced with the next one
+// in the group, and the last one with the first (eg. GET, POST, PUT, DELETE). The
+// keyword-increase/decrease commands also use the groups, before their builtin
+// switchers (numbers, booleans, operators). Groups with less than two words are
+// ignored, and the first group containing the word is used.
+func (rl *Shell) AddRotation(words []string) {
+	if len(words) < 2 {
+		return
+	}
+
+	group := make([]string, len(words))
+	copy(group, words)
+
+	rl.rotations = append(rl.rotations, group)
+}
+
+// DisableUndo disables the undo history if disable is true, or enables it back.
+// When disabled, no undo states are kept for the input line (or history lines),
+// which saves memory and time when editing very large buffers, and the undo,
+// redo and revert-line commands do nothing. This is also recommended when reading
+// secrets, so that no copies of them are kept. Previously saved states are dropped.
+func (rl *Shell) DisableUndo(disable bool) {
+	rl.History.DisableUndo(disable)
+}
+
+// Autosuggest returns the part of the history line currently suggested after
+// the input line, that is, what autosuggest-accept would insert. It returns an
+// empty string when no suggestion is active (history-autosuggest is off, or no
+// history line starts with the input line). While reading input, it must only
+// be called from commands run by the shell, since it reads the input line.
+func (rl *Shell) Autosuggest() string {
+	if !rl.Config.GetBool("history-autosuggest") {
+		return ""
+	}
+
+	suggested := rl.History.Suggest(rl.line)
+	if suggested.Len() <= rl.line.Len() {
+		return ""
+	}
+
+	return string(suggested[rl.line.Len():])
+}
+
+// AcceptAutosuggest inserts the current autosuggestion in the input line, like
+// the autosuggest-accept command. It is a no-op when no suggestion is active.
+// While reading input, it must only be called from commands run by the shell,
+// which redisplay the line once done.
+func (rl *Shell) AcceptAutosuggest() {
+	if rl.Autosuggest() == "" {
+		return
+	}
+
+	rl.History.Save()
+	rl.autosuggestAccept()
+}
+
+// SetModePrompt sets the primary prompt to display while mode is the main keymap,
+// in place of the one set with Prompt.Primary(), for instance to make vi command
+// mode look different from insert mode. The prompt for emacs is used in all the
+// emacs keymaps, and the one for vi-command also for vi and vi-move. The prompt
+// is redisplayed when the mode changes: if it spans several lines, only its last
+// one is. Passing an empty prompt removes it.
+func (rl *Shell) SetModePrompt(mode string, prompt string) {
+	rl.Prompt.Mode(keymap.Mode(mode), prompt)
+}
+
+// InvalidatePromptCache drops the part of the primary prompt set with Prompt.Cached(),
+// when the state it depends on has changed, so that it is resolved again with the
+// next display of the prompt instead of with the next prompt only. It is safe to
+// call from another goroutine: call Refresh() afterwards to redisplay the prompt.
+func (rl *Shell) InvalidatePromptCache() {
+	rl.Prompt.InvalidateCache()
+}
+
+// RenderedLine returns the primary prompt and the input line as they are displayed
+// for the current state, with their color sequences: the syntax highlighter, the
+// highlighting of the selection and the autosuggestion are all applied. Terminal
+// sequences moving the cursor or clearing the screen are left out, and lines of
+// input after the first are indented with spaces, so that the result can be
+// compared to a golden file in tests, without a terminal.
+//
+// The string is a snapshot, rendered when called: it is not updated afterwards.
+func (rl *Shell) RenderedLine() string {
+	display.Init(rl.Display, rl.SyntaxHighlighter)
+	return rl.Display.RenderedLine()
+}
+
+// Refresh redisplays the prompt, input line and helpers, calling the syntax
+// highlighter again. It is safe to call from another goroutine while Readline()
+// is waiting for input keys, eg. when the highlighter depends on some state that
+// has been updated asynchronously, and from commands run by the shell (widgets,
+// OnWidget hooks, the executor). It never blocks: if a command is being run, or
+// waiting for a key, the refresh is done as soon as it completes. If the shell
+// is not reading input, nothing happens. Refreshes requested while another one
+// is waiting are coalesced with it.
+func (rl *Shell) Refresh() {
+	rl.refreshPending.Store(true)
+
+	if !rl.mutex.TryLock() {
+		return
+	}
+
+	rl.flushPending()
+	rl.unlock()
+}
+
+// unlock unlocks the shell, and then does the refreshes and prints requested
+// by Refresh() or PrintAbovePrompt() while it was locked, if any.
+func (rl *Shell) unlock() {
+	rl.mutex.Unlock()
+
+	for rl.refreshPending.Load() && rl.mutex.TryLock() {
+		rl.flushPending()
+		rl.mutex.Unlock()
+	}
+}
+
+// flushPending does the prints and refresh waiting for the shell, to be called
+// with the shell locked. Prints already redisplay the prompt and line.
+func (rl *Shell) flushPending() {
+	if !rl.refreshPending.Swap(false) {
+		return
+	}
+
+	rl.printMutex.Lock()
+	prints := rl.pendingPrints
+	rl.pendingPrints = nil
+	rl.printMutex.Unlock()
+
+	for _, s := range prints {
+		rl.printAbovePrompt(s)
+	}
+
+	if len(prints) == 0 && rl.reading.Load() {
+		rl.Display.Refresh()
+	}
+}
+
 // Printf prints a formatted string below the current line and redisplays the prompt
 // and input line (and possibly completions/hints if active) below the logged string.
 // A newline is added to the message so that the prompt is correctly refreshed below.
@@ -145,10 +732,10 @@ func (rl *Shell) Printf(msg string, args ...any) (n int, err error) {
 	// and clear everything below (hints and completions).
 	rl.Display.CursorBelowLine()
 	term.MoveCursorBackwards(term.GetWidth())
-	fmt.Print(term.ClearScreenBelow)
+	term.Print(term.ClearScreenBelow)
 
 	// Skip a line, and print the formatted message.
-	n, err = fmt.Printf(msg+"\n", args...)
+	n, err = term.Printf(msg+"\n", args...)
 
 	// Redisplay the prompt, input line and active helpers.
 	rl.Prompt.PrimaryPrint()
@@ -165,10 +752,10 @@ func (rl *Shell) PrintTransientf(msg string, args ...any) (n int, err error) {
 	rl.Display.CursorToLineStart()
 	term.MoveCursorBackwards(term.GetWidth())
 	term.MoveCursorUp(rl.Prompt.PrimaryUsed())
-	fmt.Print(term.ClearScreenBelow)
+	term.Print(term.ClearScreenBelow)
 
 	// Print the logged message.
-	n, err = fmt.Printf(msg+"\n", args...)
+	n, err = term.Printf(msg+"\n", args...)
 
 	// Redisplay the prompt, input line and active helpers.
 	rl.Prompt.PrimaryPrint()
@@ -176,3 +763,88 @@ func (rl *Shell) PrintTransientf(msg string, args ...any) (n int, err error) {
 
 	return
 }
+
+// PrintAbovePrompt prints a string above the prompt while the shell is reading input,
+// and redisplays the prompt, input line and helpers below it, with the cursor and
+// any ongoing edition unchanged. A newline is appended to the string if needed.
+// It is safe to call from another goroutine (eg. for asynchronous notifications),
+// and from commands run by the shell: it never blocks, and if a command is being
+// run, or waiting for a key, the string is printed as soon as it completes, in the
+// order of the calls. If the shell is not reading input, the string is simply printed.
+func (rl *Shell) PrintAbovePrompt(s string) {
+	rl.printMutex.Lock()
+	rl.pendingPrints = append(rl.pendingPrints, s)
+	rl.printMutex.Unlock()
+
+	rl.Refresh()
+}
+
+// printAbovePrompt is PrintAbovePrompt, to be called with the shell locked.
+func (rl *Shell) printAbovePrompt(s string) {
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+
+	if !rl.reading.Load() {
+		term.Print(s)
+		return
+	}
+
+	// Go back to the beginning of the prompt, and clear
+	// everything below (prompt/line/hints/completions).
+	rl.Display.CursorToLineStart()
+	term.MoveCursorBackwards(term.GetWidth())
+	term.MoveCursorUp(rl.Prompt.PrimaryUsed())
+	term.Print(term.ClearScreenBelow)
+
+	term.Print(s)
+
+	// Redisplay the prompt, input line and active helpers.
+	rl.Prompt.PrimaryPrint()
+	rl.Display.Refresh()
+}
+
+// varBool converts a value to an inputrc boolean, or returns nil.
+func varBool(value any) any {
+	switch val := value.(type) {
+	case bool:
+		return val
+	case int:
+		return val != 0
+	case string:
+		switch strings.ToLower(val) {
+		case "on", "1":
+			return true
+		case "off", "0":
+			return false
+		}
+	}
+
+	return nil
+}
+
+// varInt converts a value to an inputrc integer, or returns nil.
+func varInt(value any) any {
+	switch val := value.(type) {
+	case int:
+		return val
+	case string:
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+
+	return nil
+}
+
+// varString converts a value to an inputrc string, or returns nil.
+func varString(value any) any {
+	switch val := value.(type) {
+	case string:
+		return val
+	case int:
+		return strconv.Itoa(val)
+	}
+
+	return nil
+}
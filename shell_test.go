@@ -0,0 +1,1020 @@
+package readline
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexj212/readline/inputrc"
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/core"
+	"github.com/alexj212/readline/internal/keymap"
+	"github.com/alexj212/readline/internal/macro"
+	"github.com/alexj212/readline/internal/term"
+)
+
+// runKeys feeds keys to the shell and dispatches them to their commands,
+// like the Readline() loop does, until no keys are left in the stack.
+// Contrary to Process(), the shell is not reset before running them.
+func runKeys(t *testing.T, rl *Shell, keys string) {
+	t.Helper()
+
+	restore := discardTerminal()
+	defer restore()
+
+	rl.Keys.Feed(false, []rune(keys)...)
+
+	for {
+		macro.RecordKeys(rl.Macros)
+		core.FlushUsed(rl.Keys)
+
+		if _, empty := core.PeekKey(rl.Keys); empty {
+			return
+		}
+
+		if prefixed, _, _, _ := rl.dispatch(); prefixed {
+			return
+		}
+	}
+}
+
+// captureTerminal is like discardTerminal, except that the display output is
+// captured: the returned function restores the display output and keys input,
+// and returns everything displayed in the meantime.
+func captureTerminal(t *testing.T) (done func() string) {
+	t.Helper()
+
+	restore := discardTerminal()
+
+	output := new(bytes.Buffer)
+	term.Output = output
+
+	return func() string {
+		restore()
+		return output.String()
+	}
+}
+
+// newTestShell returns a shell with its line and cursor set, in the given keymap.
+func newTestShell(t *testing.T, main keymap.Mode, line string, cursor int) *Shell {
+	t.Helper()
+
+	restore := discardTerminal()
+	defer restore()
+
+	rl := NewShell(inputrc.WithName("readline-test"))
+	rl.Keymap.SetMain(string(main))
+	rl.line.Set([]rune(line)...)
+	rl.cursor.Set(cursor)
+	rl.History.Save()
+
+	return rl
+}
+
+// widgetTest is a buffer edited by keys calling a widget, and the expected result.
+type widgetTest struct {
+	name       string
+	widget     string // The widget called, if not the one tested by the table.
+	line       string
+	cursor     int
+	setup      func(rl *Shell)        // Run before the keys, eg. to set the region.
+	options    map[string]interface{} // Set before the keys.
+	input      string                 // The keys, C-x w calling the widget. Only C-x w if empty.
+	want       string
+	wantCursor int
+}
+
+// runWidgetTests runs each test on an emacs shell with the widget bound to C-x w,
+// and checks the line and cursor it leaves with no region active. All changes made
+// by the widget must then be undone at once, bringing the line back to the original.
+func runWidgetTests(t *testing.T, widget string, tests []widgetTest) {
+	t.Helper()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, test.cursor)
+
+			if test.widget != "" {
+				rl.Config.Bind(string(keymap.Emacs), "\x18w", test.widget, false)
+			} else {
+				rl.Config.Bind(string(keymap.Emacs), "\x18w", widget, false)
+			}
+
+			for name, value := range test.options {
+				rl.Config.Set(name, value)
+			}
+
+			if test.setup != nil {
+				test.setup(rl)
+			}
+
+			if test.input == "" {
+				test.input = "\x18w"
+			}
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want || rl.cursor.Pos() != test.wantCursor {
+				t.Errorf("line = %q (cursor %d), want %q (cursor %d)", got, rl.cursor.Pos(), test.want, test.wantCursor)
+			}
+
+			if rl.selection.Active() {
+				t.Errorf("region still active after %s", widget)
+			}
+
+			runKeys(t, rl, "\x1f")
+
+			if got := string(*rl.line); got != test.line {
+				t.Errorf("undo: line = %q, want %q", got, test.line)
+			}
+		})
+	}
+}
+
+// markAt returns a widget test setup setting the mark at pos,
+// so that the region spans from there to the cursor.
+func markAt(pos int) func(rl *Shell) {
+	return func(rl *Shell) {
+		rl.selection.Mark(pos)
+	}
+}
+
+func TestShell_LoadInputrc(t *testing.T) {
+	tests := []struct {
+		name     string
+		main     keymap.Mode
+		inputrc  string
+		input    string
+		want     string
+		wantMain keymap.Mode
+	}{
+		{
+			name:    "Bind a macro",
+			main:    keymap.Emacs,
+			inputrc: "\"\\C-xh\": \"hello world\"\n",
+			input:   "\x18h",
+			want:    "hello world", wantMain: keymap.Emacs,
+		},
+		{
+			name:    "Bind a command in a keymap",
+			main:    keymap.Emacs,
+			inputrc: "set keymap emacs\n\"\\C-xk\": backward-kill-word\n",
+			input:   "hello world\x18k",
+			want:    "hello ", wantMain: keymap.Emacs,
+		},
+		{
+			name:    "Conditional on the mode",
+			main:    keymap.Emacs,
+			inputrc: "$if mode=emacs\n\"\\C-xh\": \"yes\"\n$else\n\"\\C-xh\": \"no\"\n$endif\n",
+			input:   "\x18h",
+			want:    "yes", wantMain: keymap.Emacs,
+		},
+		{
+			name:    "Set the editing mode",
+			main:    keymap.Emacs,
+			inputrc: "set editing-mode vi\n",
+			input:   "hello",
+			want:    "hello", wantMain: keymap.ViInsert,
+		},
+		{
+			name:    "Keep the editing mode",
+			main:    keymap.ViCommand,
+			inputrc: "set bell-style none\n",
+			input:   "ihello",
+			want:    "hello", wantMain: keymap.ViInsert,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, test.main, "", 0)
+
+			if err := rl.LoadInputrc(strings.NewReader(test.inputrc)); err != nil {
+				t.Fatalf("LoadInputrc() error = %v", err)
+			}
+
+			runKeys(t, rl, test.input)
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			if got := rl.Keymap.Main(); got != test.wantMain {
+				t.Errorf("main keymap = %q, want %q", got, test.wantMain)
+			}
+		})
+	}
+}
+
+func TestShell_InputrcErrors(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	config := "set no-such-variable on\n" +
+		"\"\\C-xa\": no-such-command\n" +
+		"\"\\C-xb\": \"macro\"\n" +
+		"set editing-mode vim\n" +
+		"\"\\C-xc\": backward-kill-word\n"
+
+	restore := discardTerminal()
+	err := rl.LoadInputrc(strings.NewReader(config))
+	restore()
+
+	if err == nil {
+		t.Fatal("LoadInputrc() error = nil, want errors")
+	}
+
+	want := []struct {
+		line int
+		err  error
+	}{
+		{1, inputrc.ErrUnknownVariable},
+		{2, inputrc.ErrUnknownCommand},
+		{4, inputrc.ErrInvalidEditingMode},
+	}
+
+	errs := rl.InputrcErrors()
+	if len(errs) < len(want) {
+		t.Fatalf("InputrcErrors() = %v, want %d errors", errs, len(want))
+	}
+
+	// Errors from the user inputrc files, if any, come first.
+	errs = errs[len(errs)-len(want):]
+
+	for i, test := range want {
+		var perr *inputrc.ParseError
+		if !errors.As(errs[i], &perr) || perr.Line != test.line || !errors.Is(perr, test.err) {
+			t.Errorf("InputrcErrors()[%d] = %v, want line %d: %v", i, errs[i], test.line, test.err)
+		}
+	}
+
+	// The rest of the configuration is still applied.
+	runKeys(t, rl, "hello world\x18c")
+
+	if got := string(*rl.line); got != "hello " {
+		t.Errorf("line = %q, want %q", got, "hello ")
+	}
+}
+
+func TestShell_SetVar(t *testing.T) {
+	tests := []struct {
+		name    string
+		varName string
+		value   any
+		want    any
+		wantErr error
+	}{
+		{name: "Bool", varName: "history-autosuggest", value: true, want: true},
+		{name: "Bool from on", varName: "history-autosuggest", value: "On", want: true},
+		{name: "Bool from off", varName: "echo-control-characters", value: "off", want: false},
+		{name: "Bool from int", varName: "history-autosuggest", value: 1, want: true},
+		{name: "Int", varName: "completion-query-items", value: 50, want: 50},
+		{name: "Int from string", varName: "completion-query-items", value: "20", want: 20},
+		{name: "String", varName: "comment-begin", value: "//", want: "//"},
+		{name: "String from int", varName: "comment-begin", value: 0, want: "0"},
+		{name: "Unknown variable", varName: "no-such-variable", value: true, wantErr: inputrc.ErrUnknownVariable},
+		{name: "Invalid bool", varName: "history-autosuggest", value: "maybe", wantErr: ErrInvalidValue},
+		{name: "Invalid int", varName: "completion-query-items", value: "many", wantErr: ErrInvalidValue},
+		{name: "Invalid string", varName: "comment-begin", value: true, wantErr: ErrInvalidValue},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, "", 0)
+			before, _ := rl.GetVar(test.varName)
+
+			err := rl.SetVar(test.varName, test.value)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("SetVar() error = %v, want %v", err, test.wantErr)
+			}
+
+			got, found := rl.GetVar(test.varName)
+
+			switch {
+			case test.wantErr == nil && got != test.want:
+				t.Errorf("GetVar() = %v (%T), want %v (%T)", got, got, test.want, test.want)
+			case test.wantErr != nil && got != before:
+				t.Errorf("GetVar() = %v, want unchanged %v", got, before)
+			case errors.Is(test.wantErr, inputrc.ErrUnknownVariable) && found:
+				t.Errorf("GetVar() found unknown variable %q", test.varName)
+			}
+		})
+	}
+}
+
+func TestShell_SaveMacro(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	if err := rl.SaveMacro("greet"); !errors.Is(err, ErrNoMacro) {
+		t.Fatalf("SaveMacro() with no macro recorded: error = %v, want %v", err, ErrNoMacro)
+	}
+
+	runKeys(t, rl, "\x18(hi\x18)")
+
+	if err := rl.SaveMacro(""); !errors.Is(err, ErrNoMacro) {
+		t.Errorf("SaveMacro(\"\"): error = %v, want %v", err, ErrNoMacro)
+	}
+
+	if err := rl.SaveMacro("greet"); err != nil {
+		t.Fatalf("SaveMacro() error = %v", err)
+	}
+
+	// The saved macro is kept when another one is recorded.
+	runKeys(t, rl, "\x18(x\x18)")
+
+	if err := rl.BindMacro("greet", "emacs", `\C-xm`); err != nil {
+		t.Fatalf("BindMacro() error = %v", err)
+	}
+
+	rl.line.Set()
+	rl.cursor.Set(0)
+	runKeys(t, rl, "\x18m\x18m")
+
+	if got := string(*rl.line); got != "hihi" {
+		t.Errorf("line = %q after replaying the bound macro twice, want %q", got, "hihi")
+	}
+
+	if err := rl.BindMacro("other", "emacs", `\C-xo`); !errors.Is(err, ErrNoMacro) {
+		t.Errorf("BindMacro() with an unknown macro: error = %v, want %v", err, ErrNoMacro)
+	}
+
+	// An unknown keymap is not created.
+	if err := rl.BindMacro("greet", "nokeymap", `\C-xm`); !errors.Is(err, ErrUnknownKeymap) {
+		t.Errorf("BindMacro() with an unknown keymap: error = %v, want %v", err, ErrUnknownKeymap)
+	}
+
+	if _, found := rl.Config.Binds["nokeymap"]; found {
+		t.Errorf("BindMacro() created the unknown keymap")
+	}
+}
+
+func TestShell_Keymaps(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	keymaps := make(map[string]KeymapInfo)
+	previous := ""
+
+	for _, info := range rl.Keymaps() {
+		if info.Name < previous {
+			t.Errorf("keymap %q listed after %q, want sorted names", info.Name, previous)
+		}
+
+		keymaps[info.Name], previous = info, info.Name
+	}
+
+	for _, name := range []keymap.Mode{keymap.Emacs, keymap.ViInsert, keymap.ViCommand} {
+		info, found := keymaps[string(name)]
+		if !found || info.Bindings == 0 {
+			t.Errorf("keymap %q = %+v, want it listed with bindings", name, info)
+		}
+
+		if info.Active != (name == keymap.Emacs) {
+			t.Errorf("keymap %q active = %v, want only emacs active", name, info.Active)
+		}
+	}
+
+	// New bindings are counted.
+	before := keymaps[string(keymap.Emacs)].Bindings
+	rl.Config.Bind(string(keymap.Emacs), "\x18\x18q", "abort", false)
+
+	for _, info := range rl.Keymaps() {
+		if info.Name == string(keymap.Emacs) && info.Bindings != before+1 {
+			t.Errorf("emacs bindings = %d, want %d", info.Bindings, before+1)
+		}
+	}
+}
+
+func TestShell_KillRing(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.SetKillRing([]string{"one", "", "two"})
+
+	ring := rl.KillRing()
+	if strings.Join(ring, ",") != "one,two" {
+		t.Fatalf("KillRing() = %q, want %q", ring, []string{"one", "two"})
+	}
+
+	// The snapshot is a copy.
+	ring[0] = "changed"
+
+	if got, _ := rl.Process("\x19"); got != "one" {
+		t.Errorf("yank = %q, want %q", got, "one")
+	}
+
+	// Copying a region pushes it in front of the ring.
+	rl = newTestShell(t, keymap.Emacs, "hello", 5)
+	rl.SetKillRing([]string{"one", "two"})
+	rl.Config.Bind(string(keymap.Emacs), "\x18c", "copy-region-as-kill", false)
+	rl.selection.Mark(0)
+
+	runKeys(t, rl, "\x18c")
+
+	if ring := rl.KillRing(); strings.Join(ring, ",") != "hello,one,two" {
+		t.Errorf("KillRing() = %q, want %q", ring, []string{"hello", "one", "two"})
+	}
+
+	if rl.selection.Active() {
+		t.Errorf("copy-region-as-kill should reset the selection")
+	}
+
+	// No more entries than the size of the ring are kept.
+	rl.SetKillRingSize(3)
+	rl.SetKillRing([]string{"0", "1", "2", "3", "4"})
+
+	if ring := rl.KillRing(); strings.Join(ring, ",") != "0,1,2" {
+		t.Errorf("KillRing() = %q, want the 3 first entries", ring)
+	}
+}
+
+func TestShell_SetMaxLength(t *testing.T) {
+	bindTabInsert := func(rl *Shell) { rl.Config.Bind(string(keymap.Emacs), "\x18\t", "tab-insert", false) }
+	completeHello := func(rl *Shell) {
+		rl.SetCompletions(func(string, int) []string { return []string{"hello"} })
+	}
+
+	tests := []struct {
+		name   string
+		max    int
+		line   string
+		kill   string
+		input  string
+		setup  func(rl *Shell)
+		want   string
+		reject bool
+	}{
+		{name: "Typing under the limit", max: 5, input: "abc", want: "abc"},
+		{name: "Typing over the limit", max: 5, input: "abcdefg", want: "abcde", reject: true},
+		{name: "Typing in a full line", max: 5, line: "abcde", input: "\x01x", want: "abcde", reject: true},
+		{name: "Paste truncated", max: 8, line: "echo ", input: "hello world", want: "echo hel", reject: true},
+		{name: "No limit", max: 0, input: "abcdefg", want: "abcdefg"},
+		{name: "Yank truncated", max: 8, line: "echo ", kill: "hello", input: "\x19", want: "echo hel", reject: true},
+		{name: "Yank fits", max: 10, line: "echo ", kill: "hello", input: "\x19", want: "echo hello"},
+		{name: "Room made by deleting", max: 5, line: "abcde", input: "\x7fx", want: "abcdx"},
+		{name: "Autopair truncated", max: 5, line: "abcd", setup: func(rl *Shell) { rl.Config.Set("auto-pair", true) }, input: "(", want: "abcd(", reject: true},
+		{name: "Autopair closer jumped over", max: 5, line: "abc()", setup: func(rl *Shell) { rl.Config.Set("auto-pair", true) }, input: "\x02)", want: "abc()"},
+		{name: "Quoted control character truncated", max: 5, line: "abcd", input: "\x16\x01", want: "abcd^", reject: true},
+		{name: "Tab inserted in a full line", max: 5, line: "abcde", input: "\x01\x18\t", setup: bindTabInsert, want: "abcde", reject: true},
+		{name: "Completion truncated", max: 8, line: "echo h", setup: completeHello, input: "\t", want: "echo hel", reject: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rl := newTestShell(t, keymap.Emacs, test.line, len(test.line))
+			rl.SetMaxLength(test.max)
+
+			if test.setup != nil {
+				test.setup(rl)
+			}
+
+			if test.kill != "" {
+				rl.Buffers.Write([]rune(test.kill)...)
+			}
+
+			done := captureTerminal(t)
+
+			rl.Keys.Feed(false, []rune(test.input)...)
+
+			for {
+				core.FlushUsed(rl.Keys)
+
+				if _, empty := core.PeekKey(rl.Keys); empty {
+					break
+				}
+
+				rl.dispatch()
+			}
+
+			output := done()
+
+			if got := string(*rl.line); got != test.want {
+				t.Errorf("line = %q, want %q", got, test.want)
+			}
+
+			wantBells := 0
+			if test.reject {
+				wantBells = 1
+			}
+
+			if bells := strings.Count(output, term.Bell); bells != wantBells {
+				t.Errorf("bells = %d, want %d", bells, wantBells)
+			}
+
+			if hint := rl.Hint.Text(); strings.Contains(hint, "Maximum length") != test.reject {
+				t.Errorf("hint = %q, want a hint if rejected: %v", hint, test.reject)
+			}
+		})
+	}
+
+	// The characters left are displayed with max-length-status.
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.SetMaxLength(10)
+	rl.Config.Set("max-length-status", true)
+
+	if _, err := rl.Process(""); err != nil || !strings.Contains(rl.Hint.Persistent(), "(10 characters left)") {
+		t.Errorf("persistent hint = %q (error %v), want 10 characters left", rl.Hint.Persistent(), err)
+	}
+
+	runKeys(t, rl, "abc")
+
+	if hint := rl.Hint.Persistent(); !strings.Contains(hint, "(7 characters left)") {
+		t.Errorf("persistent hint = %q, want 7 characters left", hint)
+	}
+}
+
+func TestShell_SetModePrompt(t *testing.T) {
+	rl := newTestShell(t, keymap.ViInsert, "", 0)
+	rl.Prompt.Primary(func() string { return "> " })
+	rl.Config.Bind(string(keymap.ViCommand), "\x05", "emacs-editing-mode", false)
+
+	rl.SetModePrompt(keymap.ViCommand, "[N] $ ")
+	rl.SetModePrompt(keymap.Emacs, "emacs> ")
+
+	steps := []struct {
+		name  string
+		input string
+		want  int
+	}{
+		{name: "Base prompt", input: "", want: len("> ") - 1},
+		{name: "Vi command mode", input: "\x1b", want: len("[N] $ ") - 1},
+		{name: "Back to insert mode", input: "i", want: len("> ") - 1},
+		{name: "Vi command mode again", input: "\x1b", want: len("[N] $ ") - 1},
+		{name: "Emacs mode", input: "\x05", want: len("emacs> ") - 1},
+	}
+
+	for _, step := range steps {
+		runKeys(t, rl, step.input)
+
+		if got := rl.Prompt.LastUsed(); got != step.want {
+			t.Errorf("%s: prompt columns = %d, want %d", step.name, got, step.want)
+		}
+	}
+
+	rl.SetModePrompt(keymap.Emacs, "")
+
+	if got := rl.Prompt.LastUsed(); got != len("> ")-1 {
+		t.Errorf("removed mode prompt: prompt columns = %d, want %d", got, len("> ")-1)
+	}
+}
+
+func TestShell_InvalidatePromptCache(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	dir, resolved := "~/src", 0
+
+	rl.Prompt.Cached(func() string {
+		resolved++
+		return color.FgBlue + "[" + dir + "]" + color.Reset + " "
+	})
+	rl.Prompt.Primary(func() string { return "> " })
+
+	// The cached part is resolved once, its length counted with the dynamic one.
+	for i := 0; i < 3; i++ {
+		if got := rl.Prompt.LastUsed(); got != len("[~/src] > ")-1 {
+			t.Errorf("prompt columns = %d, want %d", got, len("[~/src] > ")-1)
+		}
+	}
+
+	if resolved != 1 {
+		t.Errorf("cached prompt resolved %d times, want once", resolved)
+	}
+
+	// Invalidated: resolved again with the new state.
+	dir = "~/src/readline"
+	rl.InvalidatePromptCache()
+
+	if got := rl.Prompt.LastUsed(); got != len("[~/src/readline] > ")-1 || resolved != 2 {
+		t.Errorf("prompt columns = %d (resolved %d times), want %d", got, resolved, len("[~/src/readline] > ")-1)
+	}
+
+	// A new prompt resolves it again, and mode prompts follow it too.
+	rl.SetModePrompt(keymap.Emacs, "emacs> ")
+
+	if _, err := rl.Process(""); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	if got := rl.Prompt.LastUsed(); got != len("[~/src/readline] emacs> ")-1 || resolved != 3 {
+		t.Errorf("prompt columns = %d (resolved %d times), want %d", got, resolved, len("[~/src/readline] emacs> ")-1)
+	}
+
+	// Only the last line of a multiline prompt is counted.
+	rl.SetModePrompt(keymap.Emacs, "")
+	rl.Prompt.Cached(func() string { return dir + "\n" })
+
+	if got := rl.Prompt.LastUsed(); got != len("> ")-1 {
+		t.Errorf("prompt columns = %d, want %d", got, len("> ")-1)
+	}
+
+	if got := color.Strip(rl.RenderedLine()); got != "~/src/readline\n> " {
+		t.Errorf("RenderedLine() = %q, want the cached and dynamic prompt", got)
+	}
+}
+
+func TestShell_longPrompt(t *testing.T) {
+	width := term.GetWidth()
+
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Prompt.Primary(func() string { return "first\n" + strings.Repeat("p", width*2) + "> " })
+
+	runKeys(t, rl, "a")
+
+	// The prompt is truncated so that the input starts on its row.
+	startCols := rl.Prompt.LastUsed()
+	if x, y := core.CoordinatesCursor(rl.cursor, startCols); y != 0 || x != startCols+1 || x >= width {
+		t.Errorf("cursor at column %d, row %d, want column %d on the prompt row", x, y, startCols+1)
+	}
+
+	got := rl.RenderedLine()
+	if last := got[strings.LastIndex(got, "\n")+1:]; !strings.HasPrefix(last, "…") || !strings.Contains(last, "p> a") {
+		t.Errorf("RenderedLine() = %q, want the prompt truncated before the input", got)
+	}
+}
+
+func TestShell_Autosuggest(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	if _, err := rl.Process("echo hello\r"); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	rl.line.Set([]rune("echo h")...)
+	rl.cursor.Set(rl.line.Len())
+
+	// No suggestion without history-autosuggest.
+	if got := rl.Autosuggest(); got != "" {
+		t.Errorf("Autosuggest() = %q, want none when disabled", got)
+	}
+
+	if rl.AcceptAutosuggest(); string(*rl.line) != "echo h" {
+		t.Errorf("AcceptAutosuggest() changed line to %q when disabled", string(*rl.line))
+	}
+
+	rl.Config.Set("history-autosuggest", true)
+
+	if got := rl.Autosuggest(); got != "ello" {
+		t.Errorf("Autosuggest() = %q, want %q", got, "ello")
+	}
+
+	rl.AcceptAutosuggest()
+
+	if got := string(*rl.line); got != "echo hello" || rl.cursor.Pos() != rl.line.Len() {
+		t.Errorf("line = %q (cursor %d), want %q at end", got, rl.cursor.Pos(), "echo hello")
+	}
+
+	// Nothing is left to suggest.
+	if got := rl.Autosuggest(); got != "" {
+		t.Errorf("Autosuggest() = %q, want none on a complete line", got)
+	}
+
+	rl.line.Set([]rune("ls")...)
+
+	if got := rl.Autosuggest(); got != "" {
+		t.Errorf("Autosuggest() = %q, want none without a match", got)
+	}
+}
+
+func TestShell_PrintAbovePrompt(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "echo hello", 4)
+	rl.Prompt.Primary(func() string { return "prompt> " })
+
+	done := captureTerminal(t)
+
+	// Not reading input: the string is only printed.
+	rl.PrintAbovePrompt("first")
+
+	// Reading input: the prompt and line are redisplayed, but the print
+	// is only done once the running command is, without blocking.
+	rl.mutex.Lock()
+	rl.reading.Store(true)
+	printed := make(chan bool)
+
+	go func() {
+		rl.PrintAbovePrompt("second\n")
+		close(printed)
+	}()
+
+	select {
+	case <-printed:
+	case <-time.After(time.Second):
+		t.Fatal("PrintAbovePrompt() blocked while the shell was locked")
+	}
+
+	if len(rl.pendingPrints) != 1 {
+		t.Errorf("pending prints = %q, want the message queued while locked", rl.pendingPrints)
+	}
+
+	rl.unlock()
+
+	got := color.Strip(done())
+
+	first, second := strings.Index(got, "first\n"), strings.Index(got, "second\n")
+	line := strings.LastIndex(got, "prompt> echo hello")
+
+	if first != 0 || second < first || line < second {
+		t.Errorf("output = %q, want messages printed above the prompt and line", got)
+	}
+
+	if strings.Contains(got, "second\n\n") {
+		t.Errorf("output = %q, want no newline added to the message", got)
+	}
+
+	if got := string(*rl.line); got != "echo hello" || rl.cursor.Pos() != 4 {
+		t.Errorf("line = %q (cursor %d), want unchanged", got, rl.cursor.Pos())
+	}
+}
+
+// TestShell_refreshFromCommand checks that Refresh() and PrintAbovePrompt(),
+// called by commands (which run with the shell locked), don't deadlock.
+func TestShell_refreshFromCommand(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Prompt.Primary(func() string { return "prompt> " })
+
+	rl.OnWidget(func(name string, _ []rune) {
+		if name == "self-insert" {
+			rl.Refresh()
+			rl.PrintAbovePrompt("notified")
+		}
+	})
+
+	done := captureTerminal(t)
+
+	rl.mutex.Lock()
+	rl.reading.Store(true)
+	ran := make(chan bool)
+
+	go func() {
+		rl.Keys.Feed(false, 'a')
+		rl.dispatch()
+		rl.unlock()
+		close(ran)
+	}()
+
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("Refresh() or PrintAbovePrompt() called by a command blocked the shell")
+	}
+
+	got := color.Strip(done())
+
+	if notified, line := strings.Index(got, "notified\n"), strings.LastIndex(got, "prompt> a"); notified == -1 || line < notified {
+		t.Errorf("output = %q, want the message printed once the command is done", got)
+	}
+}
+
+func TestShell_RenderedLine(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.Prompt.Primary(func() string { return "first\n" + color.FgRed + "prompt>" + color.Reset + " " })
+
+	if _, err := rl.Process("echo hello\r"); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	rl.line.Set([]rune("echo h\nworld")...)
+	rl.cursor.Set(4)
+
+	// Continuation lines are aligned after the prompt.
+	want := "first\n" + color.FgRed + "prompt>" + color.Reset + " echo h" + color.BgDefault +
+		"\n        world" + color.Reset + color.BgDefault
+	if got := rl.RenderedLine(); got != want {
+		t.Errorf("RenderedLine() = %q, want %q", got, want)
+	}
+
+	// The highlighter and the selection are applied.
+	rl.SyntaxHighlighter = func(line []rune) string {
+		return color.Bold + string(line[:4]) + color.Reset + string(line[4:])
+	}
+
+	rl.selection.MarkRange(5, 6)
+	rl.selection.Visual(false)
+
+	got := rl.RenderedLine()
+	if !strings.Contains(got, color.Bold+"echo"+color.Reset) || !strings.Contains(got, color.Reverse+"h") {
+		t.Errorf("RenderedLine() = %q, want the highlighted line and selection", got)
+	}
+
+	if stripped := color.Strip(got); stripped != "first\nprompt> echo h\n        world" {
+		t.Errorf("RenderedLine() = %q once stripped, want the prompt and line", stripped)
+	}
+
+	// The autosuggestion follows the line.
+	rl.selection.Reset()
+	rl.SyntaxHighlighter = nil
+	rl.line.Set([]rune("echo h")...)
+	rl.cursor.Set(rl.line.Len())
+	rl.Config.Set("history-autosuggest", true)
+
+	if got := color.Strip(rl.RenderedLine()); got != "first\nprompt> echo hello" {
+		t.Errorf("RenderedLine() = %q, want the autosuggestion", got)
+	}
+}
+
+func TestShell_SetTokenizer(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "echo hello", 10)
+	rl.Prompt.Primary(func() string { return "> " })
+
+	rl.SetTokenizer(func(line []rune) []Token {
+		return []Token{{Start: 0, End: 4, Style: color.FgRed}, {Start: 5, End: len(line), Style: color.Bold}}
+	})
+
+	want := "> " + color.FgRed + "echo" + color.FgDefault + " " + color.Bold + "hello" + color.BoldReset + color.Reset + color.BgDefault
+	if got := rl.RenderedLine(); got != want {
+		t.Errorf("RenderedLine() = %q, want %q", got, want)
+	}
+
+	// The selection is kept highlighted over the tokens it spans.
+	rl.selection.MarkRange(3, 7)
+	rl.selection.Visual(false)
+
+	want = "> " + color.FgRed + "ech" + color.Reverse + "o" + color.FgDefault + color.Reverse + " " + color.Bold +
+		color.Reverse + "h" + color.Reverse + "e" + color.Reverse + "l" + color.ReverseReset + "lo" + color.BoldReset + color.Reset + color.BgDefault
+	if got := rl.RenderedLine(); got != want {
+		t.Errorf("RenderedLine() = %q, want %q", got, want)
+	}
+
+	// Passing nil disables the tokenizer.
+	rl.selection.Reset()
+	rl.SetTokenizer(nil)
+
+	if got := rl.RenderedLine(); got != "> echo hello"+color.Reset+color.BgDefault {
+		t.Errorf("RenderedLine() = %q, want the line without highlighting", got)
+	}
+}
+
+func TestShell_StartSpinner(t *testing.T) {
+	restore := discardTerminal()
+	defer restore()
+
+	rl := newTestShell(t, keymap.Emacs, "echo hello", 4)
+	rl.Config.Set("spinner-frames", "ab")
+	rl.Config.Set("spinner-interval", 5)
+
+	status := func() string {
+		rl.mutex.Lock()
+		defer rl.mutex.Unlock()
+
+		return color.Strip(rl.Hint.Status())
+	}
+
+	waitFor := func(what string, done func(status string) bool) {
+		t.Helper()
+
+		for deadline := time.Now().Add(time.Second); !done(status()); {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s, status = %q", what, status())
+			}
+
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	rl.mutex.Lock()
+	rl.reading.Store(true)
+	rl.mutex.Unlock()
+
+	stopFetch := rl.StartSpinner("fetching")
+	stopIndex := rl.StartSpinner("indexing")
+
+	waitFor("both spinners", func(status string) bool {
+		return strings.Contains(status, "fetching") && strings.Contains(status, "indexing")
+	})
+	waitFor("the next frame", func(status string) bool { return strings.Contains(status, "b fetching") })
+
+	stopFetch()
+	stopFetch()
+
+	waitFor("the stopped spinner to be removed", func(status string) bool {
+		return !strings.Contains(status, "fetching") && strings.Contains(status, "indexing")
+	})
+
+	stopIndex()
+	waitFor("all spinners to be removed", func(status string) bool { return status == "" })
+
+	if line := string(*rl.line); line != "echo hello" || rl.cursor.Pos() != 4 {
+		t.Errorf("line = %q, cursor = %d, want them unchanged", line, rl.cursor.Pos())
+	}
+
+	rl.mutex.Lock()
+	rl.reading.Store(false)
+	rl.mutex.Unlock()
+}
+
+func TestShell_Checkpoint(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "echo hello", 4)
+
+	// Several edits after a checkpoint are undone at once.
+	rl.Checkpoint()
+	rl.Line().Set([]rune("echo")...)
+	rl.Line().Set([]rune("echo world")...)
+	rl.Cursor().Set(10)
+
+	runKeys(t, rl, "\x1f")
+
+	if got := string(*rl.line); got != "echo hello" || rl.cursor.Pos() != 4 {
+		t.Errorf("undo: line = %q (cursor %d), want %q (cursor 4)", got, rl.cursor.Pos(), "echo hello")
+	}
+
+	// Restoring goes back to the checkpoint without undo.
+	rl.cursor.Set(10)
+	rl.Checkpoint()
+	rl.Line().Set([]rune("ls")...)
+	rl.Cursor().Set(2)
+	rl.RestoreCheckpoint()
+
+	if got := string(*rl.line); got != "echo hello" || rl.cursor.Pos() != 10 {
+		t.Errorf("RestoreCheckpoint(): line = %q (cursor %d), want %q (cursor 10)", got, rl.cursor.Pos(), "echo hello")
+	}
+}
+
+func TestShell_DisableUndo(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+
+	if _, err := rl.Process("echo previous\r"); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	rl.line.Set([]rune("start")...)
+	rl.cursor.Set(rl.line.Len())
+	rl.History.Save()
+
+	rl.DisableUndo(true)
+	runKeys(t, rl, " hello world\x17")
+
+	// Undoing does nothing, even to saved states before disabling.
+	runKeys(t, rl, "\x1f\x1f\x1f")
+
+	if got := string(*rl.line); got != "start hello " {
+		t.Errorf("undo while disabled: line = %q, want %q", got, "start hello ")
+	}
+
+	// The input line is still restored after walking the history.
+	runKeys(t, rl, "\x10\x0e")
+
+	if got := string(*rl.line); got != "start hello " {
+		t.Errorf("history walk: line = %q, want %q", got, "start hello ")
+	}
+
+	// No states accumulated while disabled: once enabled back,
+	// there is nothing to undo apart from the new changes.
+	rl.DisableUndo(false)
+	runKeys(t, rl, "there\x1f\x1f\x1f")
+
+	if got := string(*rl.line); got != "start hello " {
+		t.Errorf("undo after enabling: line = %q, want %q", got, "start hello ")
+	}
+}
+
+func TestShell_SetCommentPrefix(t *testing.T) {
+	rl := newTestShell(t, keymap.Emacs, "", 0)
+	rl.SetCommentPrefix("--")
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Insert", input: "select 1\x1b#", want: "--select 1"},
+		{name: "Toggle on", input: "select 1\x1b1\x1b#", want: "--select 1"},
+		{name: "Toggle off", input: "--select 1\x1b1\x1b#", want: "select 1"},
+		{name: "Toggle off a bare prefix", input: "--\x1b1\x1b#", want: ""},
+		{name: "Partial prefix", input: "-1\x1b1\x1b#", want: "---1"},
+	}
+
+	for _, test := range tests {
+		line, err := rl.Process(test.input)
+		if err != nil {
+			t.Fatalf("%s: Process() error = %v", test.name, err)
+		}
+
+		if line != test.want {
+			t.Errorf("%s: line = %q, want %q", test.name, line, test.want)
+		}
+	}
+
+	if got, _ := rl.GetVar("comment-begin"); got != "--" {
+		t.Errorf("comment-begin = %v, want %q", got, "--")
+	}
+
+	// Per-language prefixes, falling back to the default one.
+	rl.SetCommentPrefixFunc(func(line []rune) string {
+		if strings.HasPrefix(string(line), "(") {
+			return ";"
+		}
+
+		return ""
+	})
+
+	if line, _ := rl.Process("(car x)\x1b#"); line != ";(car x)" {
+		t.Errorf("line = %q, want %q", line, ";(car x)")
+	}
+
+	if line, _ := rl.Process("select 1\x1b#"); line != "--select 1" {
+		t.Errorf("fallback: line = %q, want %q", line, "--select 1")
+	}
+
+	rl.SetCommentPrefixFunc(nil)
+
+	if line, _ := rl.Process("(car x)\x1b#"); line != "--(car x)" {
+		t.Errorf("without function: line = %q, want %q", line, "--(car x)")
+	}
+}
@@ -0,0 +1,121 @@
+package readline
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexj212/readline/internal/color"
+	"github.com/alexj212/readline/internal/term"
+)
+
+// Spinner animation used when the spinner options are not valid.
+const (
+	defaultSpinnerFrames   = "⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏"
+	defaultSpinnerInterval = 100 * time.Millisecond
+)
+
+// spinner is an animation displayed by StartSpinner.
+type spinner struct {
+	msg    string
+	frames []rune
+	frame  int
+}
+
+// StartSpinner displays an animated spinner followed by msg above the other hints,
+// below the input line, to show that some work is going on in the background (eg.
+// fetching suggestions), and returns a function removing it. The animation runs on
+// its own, using the spinner-frames option (each character being a frame) and the
+// spinner-interval one (in milliseconds). Several spinners can run at once, each on
+// its own line, and they are kept across calls to Readline until stopped.
+//
+// It is safe to call from another goroutine, or from a command run by the shell,
+// as is the stop function (which can be called more than once): the spinner is
+// drawn or cleared as soon as no command is being run, with the cursor unchanged.
+func (rl *Shell) StartSpinner(msg string) (stop func()) {
+	sp := &spinner{msg: msg, frames: rl.spinnerFrames()}
+	interval := rl.spinnerInterval()
+
+	done := make(chan struct{})
+	var once sync.Once
+
+	go rl.runSpinner(sp, interval, done)
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// runSpinner updates the spinner at each interval, until done is closed.
+func (rl *Shell) runSpinner(sp *spinner, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	rl.updateSpinner(sp, true)
+
+	for {
+		select {
+		case <-done:
+			rl.updateSpinner(sp, false)
+			return
+		case <-ticker.C:
+			rl.updateSpinner(sp, true)
+		}
+	}
+}
+
+// updateSpinner adds the spinner, moves it to its next frame, or removes it if
+// it has stopped running, and redisplays all spinners if reading input.
+func (rl *Shell) updateSpinner(sp *spinner, running bool) {
+	rl.mutex.Lock()
+	defer rl.unlock()
+
+	index := -1
+
+	for i, other := range rl.spinners {
+		if other == sp {
+			index = i
+		}
+	}
+
+	switch {
+	case !running && index != -1:
+		rl.spinners = append(rl.spinners[:index], rl.spinners[index+1:]...)
+	case running && index == -1:
+		rl.spinners = append(rl.spinners, sp)
+	case running:
+		sp.frame = (sp.frame + 1) % len(sp.frames)
+	}
+
+	lines := make([]string, 0, len(rl.spinners))
+
+	for _, other := range rl.spinners {
+		lines = append(lines, color.Dim+string(other.frames[other.frame])+color.Reset+" "+other.msg)
+	}
+
+	rl.Hint.SetStatus(strings.Join(lines, term.NewlineReturn))
+
+	if rl.reading.Load() {
+		rl.Display.Refresh()
+	}
+}
+
+// spinnerFrames returns the frames of the spinner-frames option.
+func (rl *Shell) spinnerFrames() []rune {
+	frames := []rune(rl.Config.GetString("spinner-frames"))
+	if len(frames) == 0 {
+		frames = []rune(defaultSpinnerFrames)
+	}
+
+	return frames
+}
+
+// spinnerInterval returns the delay between spinner frames.
+func (rl *Shell) spinnerInterval() time.Duration {
+	interval := time.Duration(rl.Config.GetInt("spinner-interval")) * time.Millisecond
+	if interval <= 0 {
+		interval = defaultSpinnerInterval
+	}
+
+	return interval
+}
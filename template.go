@@ -0,0 +1,276 @@
+package readline
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexj212/readline/internal/core"
+)
+
+// Keys jumping to the next and previous template placeholders.
+const (
+	tabstopNext     = "\t"
+	tabstopPrevious = "\x1b[Z"
+)
+
+// ReadlineTemplate is like Readline, but the input line is prefilled with a
+// template containing placeholders (tabstops), as with editor snippets:
+//
+//	git commit -m "${1:message}" ${2:--amend}
+//
+// Placeholders are written ${n:text}, or ${n} when empty, and are visited in
+// the order of their number (${0}, if any, being the last one). The first one
+// is selected when starting, and Tab and Shift-Tab jump to the next/previous
+// ones, selecting their text so that typing replaces it. Tab on the last one
+// places the cursor at the end of the line, and Tab works normally again.
+// A backslash before a dollar sign or before a closing brace escapes them.
+func (rl *Shell) ReadlineTemplate(tmpl string) (string, error) {
+	rl.setTemplate(tmpl)
+
+	// Don't keep it for the next call if failing early.
+	defer func() {
+		rl.initial, rl.hasInitial = nil, false
+		rl.pendingTemplate = nil
+	}()
+
+	return rl.Readline()
+}
+
+// tabstop is a template placeholder, with its position in the line.
+type tabstop struct {
+	num  int // The placeholder number, determining the navigation order.
+	bpos int // Beginning of the placeholder text.
+	epos int // End of the placeholder text (excluded).
+}
+
+// tabstops keeps the placeholders of a template line up to date with edits.
+type tabstops struct {
+	stops    []tabstop
+	current  int    // The index of the currently selected placeholder.
+	selected bool   // The current placeholder text is the visual selection.
+	line     []rune // The line when last updated.
+}
+
+// setTemplate parses a template and sets it to start the next read with.
+func (rl *Shell) setTemplate(tmpl string) {
+	line, stops := parseTemplate(tmpl)
+	rl.setInitial(string(line), len(line))
+
+	rl.pendingTemplate = &tabstops{stops: stops}
+}
+
+// startTemplate is called when initializing the shell, to use the template (if
+// any) set for this read, and select its first placeholder.
+func (rl *Shell) startTemplate() {
+	rl.template, rl.pendingTemplate = rl.pendingTemplate, nil
+
+	if rl.template == nil {
+		return
+	}
+
+	if len(rl.template.stops) == 0 {
+		rl.template = nil
+		return
+	}
+
+	rl.template.line = append([]rune{}, *rl.line...)
+	rl.selectTabstop(0)
+}
+
+// jumpTabstop selects the next or previous placeholder if the pending keys
+// are Tab or Shift-Tab, and returns true if it did. Local keymaps, like the
+// completion menu, keep their own binds for these keys.
+func (rl *Shell) jumpTabstop() bool {
+	if rl.template == nil || rl.Keymap.Local() != "" {
+		return false
+	}
+
+	keys := string(core.PendingKeys(rl.Keys))
+	move := 1
+
+	switch {
+	case strings.HasPrefix(keys, tabstopNext):
+		keys = tabstopNext
+	case strings.HasPrefix(keys, tabstopPrevious):
+		keys = tabstopPrevious
+		move = -1
+	default:
+		return false
+	}
+
+	for range keys {
+		core.PopForce(rl.Keys)
+	}
+
+	rl.selectTabstop(rl.template.current + move)
+
+	return true
+}
+
+// selectTabstop selects the text of a placeholder, with the cursor at its end.
+// Past the last placeholder, the cursor is moved at the end of the line, and
+// the template navigation is over.
+func (rl *Shell) selectTabstop(index int) {
+	rl.selection.Reset()
+
+	if index >= len(rl.template.stops) {
+		rl.cursor.Set(rl.line.Len())
+		rl.template = nil
+
+		return
+	}
+
+	rl.template.current = max(index, 0)
+	stop := rl.template.stops[rl.template.current]
+
+	rl.cursor.Set(stop.epos)
+
+	rl.template.selected = stop.epos > stop.bpos
+
+	if rl.template.selected {
+		rl.selection.MarkRange(stop.bpos, stop.epos-1)
+		rl.selection.Visual(false)
+	}
+}
+
+// placeholderSelected returns true if the visual selection is still the text
+// of the current placeholder, as selected when jumping to it: it is replaced by
+// the text typed, while a selection made by the user is not.
+func (rl *Shell) placeholderSelected() bool {
+	if rl.template == nil || !rl.template.selected {
+		return false
+	}
+
+	stop := rl.template.stops[rl.template.current]
+	bpos, epos := rl.selection.Pos()
+
+	return bpos == stop.bpos && epos == stop.epos
+}
+
+// updateTabstops moves the placeholders according to the changes made to the
+// line since the last update: text inserted at the end of the current one, or
+// replacing it, becomes its new text. Once edited, it is not selected anymore.
+func (rl *Shell) updateTabstops() {
+	tmpl := rl.template
+	if tmpl == nil || string(*rl.line) == string(tmpl.line) {
+		return
+	}
+
+	if tmpl.selected {
+		rl.selection.Reset()
+		tmpl.selected = false
+	}
+
+	line := *rl.line
+
+	// Find the part of the line that has been changed.
+	prefix := 0
+	for prefix < len(line) && prefix < len(tmpl.line) && line[prefix] == tmpl.line[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < min(len(line), len(tmpl.line))-prefix &&
+		line[len(line)-1-suffix] == tmpl.line[len(tmpl.line)-1-suffix] {
+		suffix++
+	}
+
+	oldEnd, newEnd := len(tmpl.line)-suffix, len(line)-suffix
+	delta := len(line) - len(tmpl.line)
+
+	for i, stop := range tmpl.stops {
+		switch {
+		case stop.bpos < prefix, stop.bpos == prefix && i == tmpl.current:
+		case stop.bpos >= oldEnd:
+			stop.bpos += delta
+		default:
+			stop.bpos = prefix
+		}
+
+		switch {
+		case stop.epos < prefix:
+		case stop.epos >= oldEnd:
+			stop.epos += delta
+		default:
+			stop.epos = newEnd
+		}
+
+		tmpl.stops[i] = stop
+	}
+
+	tmpl.line = append(tmpl.line[:0], line...)
+}
+
+// parseTemplate returns the line of a template, without its placeholder
+// markers, and the placeholders sorted in the order they are visited.
+func parseTemplate(tmpl string) (line []rune, stops []tabstop) {
+	runes := []rune(tmpl)
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '$' {
+			line = append(line, '$')
+			i++
+
+			continue
+		}
+
+		stop, text, length, found := parseTabstop(runes[i:])
+		if !found {
+			line = append(line, runes[i])
+			continue
+		}
+
+		stop.bpos = len(line)
+		line = append(line, text...)
+		stop.epos = len(line)
+
+		stops = append(stops, stop)
+		i += length - 1
+	}
+
+	// ${0} is always the last one.
+	sort.SliceStable(stops, func(i, j int) bool {
+		if stops[i].num == 0 || stops[j].num == 0 {
+			return stops[j].num == 0 && stops[i].num != 0
+		}
+
+		return stops[i].num < stops[j].num
+	})
+
+	return line, stops
+}
+
+// parseTabstop parses a ${n:text} or ${n} placeholder at the start of runes,
+// returning its text (unescaped) and the length of the placeholder.
+func parseTabstop(runes []rune) (stop tabstop, text []rune, length int, found bool) {
+	if len(runes) < 4 || runes[0] != '$' || runes[1] != '{' {
+		return
+	}
+
+	pos := 2
+	for pos < len(runes) && runes[pos] >= '0' && runes[pos] <= '9' {
+		pos++
+	}
+
+	num, err := strconv.Atoi(string(runes[2:pos]))
+	if err != nil || pos == len(runes) {
+		return
+	}
+
+	if runes[pos] == ':' {
+		for pos++; pos < len(runes) && runes[pos] != '}'; pos++ {
+			if runes[pos] == '\\' && pos+1 < len(runes) && runes[pos+1] == '}' {
+				pos++
+			}
+
+			text = append(text, runes[pos])
+		}
+	}
+
+	if pos == len(runes) || runes[pos] != '}' {
+		return tabstop{}, nil, 0, false
+	}
+
+	return tabstop{num: num}, text, pos + 1, true
+}
@@ -1437,23 +1437,36 @@ func (rl *Shell) viFindChar(forward, skip bool) {
 		return
 	}
 
-	times := rl.Iterations.Get()
+	if pos, found := rl.findChar(char, rl.Iterations.Get(), forward, skip); found {
+		rl.cursor.Set(pos)
+	}
+}
 
-	for i := 1; i <= times; i++ {
-		pos := rl.line.Find(char, rl.cursor.Pos(), forward)
+// findChar returns the position of the nth occurrence of char from the cursor,
+// or of the last one found if there are less. If skip is true, the position is
+// the one right before the character (or right after it when going backward).
+func (rl *Shell) findChar(char rune, times int, forward, skip bool) (target int, found bool) {
+	target = rl.cursor.Pos()
 
-		if pos == rl.cursor.Pos() || pos == -1 {
+	for i := 1; i <= times; i++ {
+		pos := rl.line.Find(char, target, forward)
+		if pos == -1 {
 			break
 		}
 
-		if forward && skip {
-			pos--
-		} else if !forward && skip {
-			pos++
-		}
+		target = pos
+		found = true
+	}
 
-		rl.cursor.Set(pos)
+	switch {
+	case !found || !skip:
+	case forward:
+		target--
+	default:
+		target++
 	}
+
+	return target, found
 }
 
 // Start a non-incremental search buffer, finds the first forward